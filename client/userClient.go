@@ -0,0 +1,455 @@
+// Package client is a Go client for the user API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// RequestIDHeader carries the ID that correlates a request with the log
+	// lines of the server.
+	RequestIDHeader = "X-Request-ID"
+	// NextPageTokenHeader carries the token of the next page of a list.
+	NextPageTokenHeader = "X-Next-Page-Token"
+
+	mergePatchContentType = "application/merge-patch+json"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	maxBackoff        = 10 * time.Second
+)
+
+type User struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Address   string     `json:"address"`
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Address  string `json:"address"`
+	Password string `json:"password"`
+}
+
+// UpdateRequest changes the non-empty fields of a user.
+type UpdateRequest struct {
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// PatchRequest sets the fields of a user that are not nil. An empty address
+// clears it.
+type PatchRequest struct {
+	Name    *string `json:"name,omitempty"`
+	Email   *string `json:"email,omitempty"`
+	Address *string `json:"address,omitempty"`
+}
+
+// Change is a user that was created, updated or deleted. Old and New hold
+// the changed columns by column name.
+type Change struct {
+	Type            string         `json:"type"`
+	UserID          string         `json:"userId"`
+	CommitTimestamp time.Time      `json:"commitTimestamp"`
+	Old             map[string]any `json:"old"`
+	New             map[string]any `json:"new"`
+}
+
+// ChangeBatch holds the changes after a timestamp. Pass Next to the
+// following Changes call to continue without gaps.
+type ChangeBatch struct {
+	Changes []Change  `json:"changes"`
+	Next    time.Time `json:"next"`
+}
+
+type UserClient struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	header     http.Header
+}
+
+type Option func(*UserClient)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *UserClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how often a request answered with 429 or 503 is retried
+// and the initial backoff, which doubles after every attempt.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *UserClient) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+func WithHeader(key, value string) Option {
+	return func(c *UserClient) {
+		c.header.Set(key, value)
+	}
+}
+
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+func WithBasicAuth(username, password string) Option {
+	return func(c *UserClient) {
+		request := http.Request{Header: http.Header{}}
+		request.SetBasicAuth(username, password)
+		c.header.Set("Authorization", request.Header.Get("Authorization"))
+	}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID makes the requests sent with ctx carry id, such as the ID of
+// the request being served, so that the server logs them under the same ID.
+// Without one the server generates an ID for every request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// NewUserClient creates a client for the server at baseURL, e.g.
+// "http://localhost:8080".
+func NewUserClient(baseURL string, options ...Option) *UserClient {
+	c := &UserClient{
+		baseURL:    strings.TrimRight(baseURL, "/") + "/api/v1/users",
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+		header:     http.Header{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func (c *UserClient) Register(ctx context.Context, request RegisterRequest) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, "", nil, request, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *UserClient) Get(ctx context.Context, id string) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, userPath(id), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// List returns every user in a single response. Use ListAll for large
+// numbers of users.
+func (c *UserClient) List(ctx context.Context) ([]User, error) {
+	var users []User
+	if err := c.do(ctx, http.MethodGet, "", nil, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ListPage returns up to pageSize users following the page of pageToken,
+// which is empty for the first page, and the token of the next page, which
+// is empty after the last one.
+func (c *UserClient) ListPage(ctx context.Context, pageSize int, pageToken string) ([]User, string, error) {
+	query := url.Values{"page_size": {strconv.Itoa(pageSize)}}
+	if pageToken != "" {
+		query.Set("page_token", pageToken)
+	}
+	var users []User
+	header, err := c.request(ctx, http.MethodGet, "", query, nil, "", &users)
+	if err != nil {
+		return nil, "", err
+	}
+	return users, header.Get(NextPageTokenHeader), nil
+}
+
+// ListAll iterates over every user, fetching pageSize users at a time. The
+// iteration stops after the first error.
+func (c *UserClient) ListAll(ctx context.Context, pageSize int) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		pageToken := ""
+		for {
+			users, next, err := c.ListPage(ctx, pageSize, pageToken)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, user := range users {
+				if !yield(user, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			pageToken = next
+		}
+	}
+}
+
+func (c *UserClient) Update(ctx context.Context, id string, request UpdateRequest) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPut, userPath(id), nil, request, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Patch sends request as a JSON merge patch.
+func (c *UserClient) Patch(ctx context.Context, id string, request PatchRequest) (*User, error) {
+	var user User
+	if _, err := c.request(ctx, http.MethodPatch, userPath(id), nil, request, mergePatchContentType, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Delete soft-deletes a user; it can be brought back with Restore.
+func (c *UserClient) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, userPath(id), nil, nil, nil)
+}
+
+func (c *UserClient) DeletePermanently(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, userPath(id), url.Values{"hard": {"true"}}, nil, nil)
+}
+
+func (c *UserClient) Restore(ctx context.Context, id string) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, userPath(id)+":restore", nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Changes waits up to wait for changes committed after since.
+func (c *UserClient) Changes(ctx context.Context, since time.Time, wait time.Duration) (*ChangeBatch, error) {
+	query := url.Values{
+		"since": {since.Format(time.RFC3339Nano)},
+		"wait":  {wait.String()},
+	}
+	var batch ChangeBatch
+	if err := c.do(ctx, http.MethodGet, "/changes", query, nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func userPath(id string) string {
+	return "/" + url.PathEscape(id)
+}
+
+func (c *UserClient) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	_, err := c.request(ctx, method, path, query, body, "", result)
+	return err
+}
+
+// request sends body as JSON, or as contentType if it is set, decodes the
+// answer into result and returns its header.
+func (c *UserClient) request(ctx context.Context, method, path string, query url.Values, body any, contentType string, result any) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := c.send(ctx, method, endpoint, payload, contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		if retryable(response.StatusCode) && attempt < c.maxRetries {
+			wait := c.retryDelay(response, attempt)
+			drain(response)
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		defer drain(response)
+		if response.StatusCode >= http.StatusBadRequest {
+			return nil, decodeError(response)
+		}
+		if result == nil {
+			return response.Header, nil
+		}
+		if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+			return nil, fmt.Errorf("decode %s %s response: %w", method, path, err)
+		}
+		return response.Header, nil
+	}
+}
+
+func (c *UserClient) send(ctx context.Context, method, endpoint string, payload []byte, contentType string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		request.Header[key] = values
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		request.Header.Set(RequestIDHeader, id)
+	}
+	request.Header.Set("Accept", "application/json, application/problem+json")
+	if payload != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	return c.httpClient.Do(request)
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// retryDelay honours Retry-After when the server sends it and otherwise
+// backs off exponentially.
+func (c *UserClient) retryDelay(response *http.Response, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	delay := c.backoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func drain(response *http.Response) {
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+}
+
+// Errors matched by errors.Is against an *APIError.
+var (
+	ErrBadRequest  = errors.New("bad request")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// APIError is returned for every response with a 4xx or 5xx status. The
+// fields are decoded from the problem details the server answers with and
+// are empty if the body is not JSON, e.g. when a proxy answered.
+type APIError struct {
+	StatusCode int
+	// Type, Title and Detail are the members of the problem details.
+	Type   string
+	Title  string
+	Detail string
+	// Err is the error member, which servers before problem details sent
+	// instead of Detail; Message says which operation failed.
+	Err     string
+	Message string
+	// RequestID identifies the request in the logs of the server.
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	title := e.Title
+	if title == "" {
+		title = http.StatusText(e.StatusCode)
+	}
+	text := fmt.Sprintf("user api: %d %s", e.StatusCode, title)
+	if e.Detail != "" {
+		text += ": " + e.Detail
+	}
+	if e.Message != "" {
+		text += " (" + e.Message + ")"
+	}
+	if e.RequestID != "" {
+		text += " [request " + e.RequestID + "]"
+	}
+	return text
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+func decodeError(response *http.Response) error {
+	var body struct {
+		Type    string `json:"type"`
+		Title   string `json:"title"`
+		Detail  string `json:"detail"`
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(response.Body).Decode(&body)
+	if body.Detail == "" {
+		body.Detail = body.Error
+	}
+	return &APIError{
+		StatusCode: response.StatusCode,
+		Type:       body.Type,
+		Title:      body.Title,
+		Detail:     body.Detail,
+		Err:        body.Error,
+		Message:    body.Message,
+		RequestID:  response.Header.Get(RequestIDHeader),
+	}
+}
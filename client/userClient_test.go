@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"crudspanner/controller"
+	"crudspanner/middleware"
+	"crudspanner/model"
+	"crudspanner/repositories"
+	"crudspanner/routes"
+	"crudspanner/services"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeChangeSource serves a fixed list of changes.
+type fakeChangeSource []model.UserChange
+
+func (s fakeChangeSource) Changes(ctx context.Context, since, until time.Time) ([]model.UserChange, error) {
+	var changes []model.UserChange
+	for _, change := range s {
+		if change.CommitTimestamp.After(since) && !change.CommitTimestamp.After(until) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func newTestServer(t *testing.T, changes ...model.UserChange) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestInfo(nil, zap.NewNop()))
+	userController := controller.NewUserController(services.NewUserService(repositories.NewMemoryUserRepository(model.NewID), zap.NewNop()), zap.NewNop())
+	userController.SetChangeFeed(services.NewChangeFeed(fakeChangeSource(changes), time.Millisecond))
+	routes.NewAPIRegistry(userController, controller.NewWebhookController(nil, zap.NewNop())).Mount(router)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUserClientAgainstRealRoutes(t *testing.T) {
+	server := newTestServer(t)
+	userClient := NewUserClient(server.URL)
+	ctx := context.Background()
+
+	registered, err := userClient.Register(ctx, RegisterRequest{Name: "John", Email: "John@Example.com", Password: "secret"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, registered.ID)
+	assert.Equal(t, "john@example.com", registered.Email)
+
+	_, err = userClient.Register(ctx, RegisterRequest{Name: "John", Email: "john@example.com", Password: "secret"})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	user, err := userClient.Get(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "John", user.Name)
+
+	updated, err := userClient.Update(ctx, registered.ID, UpdateRequest{Address: "Main Street 1"})
+	require.NoError(t, err)
+	assert.Equal(t, "John", updated.Name)
+	assert.Equal(t, "Main Street 1", updated.Address)
+
+	users, err := userClient.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	require.NoError(t, userClient.Delete(ctx, registered.ID))
+	_, err = userClient.Get(ctx, registered.ID)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "User not found", apiErr.Err)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	other, err := userClient.Register(ctx, RegisterRequest{Name: "Other", Email: registered.Email, Password: "secret"})
+	require.NoError(t, err)
+	_, err = userClient.Restore(ctx, registered.ID)
+	assert.ErrorIs(t, err, ErrConflict)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Conflict", apiErr.Title, "the conflict is answered with problem details")
+	assert.Equal(t, "Could not restore user", apiErr.Message)
+	require.NoError(t, userClient.DeletePermanently(ctx, other.ID))
+
+	restored, err := userClient.Restore(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+
+	require.NoError(t, userClient.DeletePermanently(ctx, registered.ID))
+	_, err = userClient.Restore(ctx, registered.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUserClientRetriesUnavailableResponses(t *testing.T) {
+	backend := newTestServer(t)
+	registered, err := NewUserClient(backend.URL).Register(context.Background(), RegisterRequest{Name: "John", Email: "john@example.com", Password: "secret"})
+	require.NoError(t, err)
+
+	var attempts atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			backend.Config.Handler.ServeHTTP(w, r)
+		}
+	}))
+	defer flaky.Close()
+
+	user, err := NewUserClient(flaky.URL, WithRetries(3, time.Millisecond)).Get(context.Background(), registered.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "John", user.Name)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestUserClientGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"Spanner is unavailable"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewUserClient(server.URL, WithRetries(2, time.Millisecond)).List(context.Background())
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.EqualError(t, err, "user api: 503 Service Unavailable: Spanner is unavailable")
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestUserClientStopsRetryingWhenContextIsDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := NewUserClient(server.URL, WithRetries(5, time.Second)).List(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestUserClientSendsAuthorization(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	_, err := NewUserClient(server.URL, WithBearerToken("token")).List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token", authorization)
+
+	_, err = NewUserClient(server.URL, WithBasicAuth("admin", "secret")).List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Basic YWRtaW46c2VjcmV0", authorization)
+}
+
+func TestUserClientChanges(t *testing.T) {
+	since := time.Now().Add(-time.Minute).UTC()
+	server := newTestServer(t, model.UserChange{
+		Type: model.ChangeUpdate, UserID: "user-1", CommitTimestamp: since.Add(time.Second),
+		Old: map[string]any{"name": "John"}, New: map[string]any{"name": "Johnny"},
+	})
+	userClient := NewUserClient(server.URL)
+
+	batch, err := userClient.Changes(context.Background(), since, time.Second)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 1)
+	assert.Equal(t, "update", batch.Changes[0].Type)
+	assert.Equal(t, "Johnny", batch.Changes[0].New["name"])
+
+	batch, err = userClient.Changes(context.Background(), batch.Next, 0)
+	require.NoError(t, err)
+	assert.Empty(t, batch.Changes)
+
+	_, err = userClient.Changes(context.Background(), since.Add(-30*24*time.Hour), 0)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusGone, apiErr.StatusCode)
+}
+
+func TestUserClientSendsRequestID(t *testing.T) {
+	server := newTestServer(t)
+	userClient := NewUserClient(server.URL)
+
+	_, err := userClient.Get(WithRequestID(context.Background(), "trace-1"), "missing")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "trace-1", apiErr.RequestID)
+	assert.Contains(t, err.Error(), "[request trace-1]")
+
+	_, err = userClient.Get(context.Background(), "missing")
+	require.ErrorAs(t, err, &apiErr)
+	assert.NotEmpty(t, apiErr.RequestID, "the server generates an ID")
+}
+
+func TestUserClientPatch(t *testing.T) {
+	server := newTestServer(t)
+	userClient := NewUserClient(server.URL)
+	ctx := context.Background()
+
+	registered, err := userClient.Register(ctx, RegisterRequest{Name: "John", Email: "john@example.com", Address: "Main Street 1", Password: "secret"})
+	require.NoError(t, err)
+
+	name, empty := "Johnny", ""
+	patched, err := userClient.Patch(ctx, registered.ID, PatchRequest{Name: &name, Address: &empty})
+	require.NoError(t, err)
+	assert.Equal(t, "Johnny", patched.Name)
+	assert.Equal(t, "john@example.com", patched.Email)
+	assert.Empty(t, patched.Address, "an empty address clears it")
+
+	_, err = userClient.Patch(ctx, registered.ID, PatchRequest{Email: &empty})
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = userClient.Patch(ctx, "missing", PatchRequest{Name: &name})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUserClientListAllFollowsPages(t *testing.T) {
+	server := newTestServer(t)
+	userClient := NewUserClient(server.URL)
+	ctx := context.Background()
+
+	var want []string
+	for i := range 5 {
+		user, err := userClient.Register(ctx, RegisterRequest{Name: "John", Email: fmt.Sprintf("john%d@example.com", i), Password: "secret"})
+		require.NoError(t, err)
+		want = append(want, user.ID)
+	}
+
+	users, next, err := userClient.ListPage(ctx, 2, "")
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.NotEmpty(t, next)
+
+	var got []string
+	for user, err := range userClient.ListAll(ctx, 2) {
+		require.NoError(t, err)
+		got = append(got, user.ID)
+	}
+	assert.ElementsMatch(t, want, got)
+	assert.Len(t, got, len(want), "no user is listed twice")
+
+	for _, err := range userClient.ListAll(ctx, 0) {
+		assert.ErrorIs(t, err, ErrBadRequest)
+	}
+}
+
+func TestUserClientDecodesProblemDetails(t *testing.T) {
+	server := newTestServer(t)
+
+	_, err := NewUserClient(server.URL).Get(context.Background(), "missing")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "about:blank", apiErr.Type)
+	assert.Equal(t, "Not Found", apiErr.Title)
+	assert.Equal(t, "User not found", apiErr.Detail)
+	assert.True(t, strings.HasPrefix(err.Error(), "user api: 404 Not Found: User not found"))
+}
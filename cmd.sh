@@ -9,6 +9,8 @@ go get -u github.com/googleapis/go-gorm-spanner
 go get -u golang.org/x/crypto/bcrypt
 go get github.com/DATA-DOG/go-sqlmock
 go get gorm.io/driver/mysql
+go get gorm.io/driver/postgres
+go get gorm.io/driver/sqlite
 go get github.com/stretchr/testify/assert
 go test -coverprofile=coverage.out
 go tool cover -html=coverage.out
@@ -0,0 +1,91 @@
+// Command migrate manages the schema of the database selected by DB_DRIVER.
+//
+//	migrate up                 apply all pending migrations
+//	migrate down [-steps n]    revert the last n applied migrations (default 1)
+//	migrate status             list migrations and whether they are applied
+//	migrate baseline -version v
+//	                           mark migrations up to v as applied without
+//	                           running them, for schemas that already match
+//	                           them, e.g. changes made by hand
+//	migrate unlock             remove the lock left behind by a killed run
+//
+// Up, down and baseline wait while another run holds the lock.
+//
+// Databases whose users table was created by AutoMigrate, with numeric IDs,
+// are not baselined: rename that table and run up, then copy the users
+// with cmd/migrateids.
+package main
+
+import (
+	"context"
+	"crudspanner/config"
+	"crudspanner/migrations"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	version := flags.Int64("version", 0, "last migration to mark as applied with baseline")
+	if len(os.Args) < 2 {
+		log.Fatal("usage: migrate up|down|status|baseline|unlock [flags]")
+	}
+	command := os.Args[1]
+	flags.Parse(os.Args[2:])
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := config.OpenDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	schema, err := migrations.ForDialect(db.Dialector.Name())
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	migrator := migrations.New(db, schema)
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("applied %d migrations before failing: %v", applied, err)
+		}
+		log.Printf("applied %d migrations", applied)
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			log.Fatalf("reverted %d migrations before failing: %v", reverted, err)
+		}
+		log.Printf("reverted %d migrations", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", status.Version, status.Name, state)
+		}
+	case "baseline":
+		if err := migrator.Baseline(ctx, *version); err != nil {
+			log.Fatalf("failed to record baseline: %v", err)
+		}
+	case "unlock":
+		if err := migrator.Unlock(ctx); err != nil {
+			log.Fatalf("failed to remove the lock: %v", err)
+		}
+	default:
+		log.Fatalf("unknown command %q, expected up, down, status, baseline or unlock", command)
+	}
+}
@@ -0,0 +1,40 @@
+// Command migrateids copies users with sequential numeric IDs into the users
+// table with string keys. To migrate an existing database:
+//
+//  1. Drop the indexes of the old table and rename it, e.g.
+//     DROP INDEX idx_users_deleted_at; ALTER TABLE users RENAME TO users_legacy.
+//  2. Create the new users table with go run ./cmd/migrate up.
+//  3. Run this command. Copied users keep their old ID as legacy_id, so
+//     requests for /users/<numeric id> still find them.
+//  4. Drop users_legacy once all clients use the new keys.
+//
+// The copy can be re-run; users that were already copied are skipped.
+package main
+
+import (
+	"context"
+	"crudspanner/config"
+	"crudspanner/repositories"
+	"flag"
+	"log"
+)
+
+func main() {
+	table := flag.String("table", "users_legacy", "table holding the users with numeric IDs")
+	batchSize := flag.Int("batch", 500, "number of users copied per insert")
+	flag.Parse()
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := config.OpenDB(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	copied, err := repositories.CopyLegacyUsers(context.Background(), db, *table, *batchSize, cfg.KeyStrategy.Generator())
+	if err != nil {
+		log.Fatalf("copied %d users before failing: %v", copied, err)
+	}
+	log.Printf("copied %d users from %s", copied, *table)
+}
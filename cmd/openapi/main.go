@@ -0,0 +1,33 @@
+// Command openapi writes the OpenAPI document of the current API version.
+package main
+
+import (
+	"crudspanner/controller"
+	"crudspanner/openapi"
+	"crudspanner/routes"
+	"flag"
+	"log"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	output := flag.String("o", "", "file to write the document to (default stdout)")
+	version := flag.String("version", routes.CurrentVersion, "API version to describe")
+	flag.Parse()
+
+	registry := routes.NewAPIRegistry(controller.NewUserController(nil, zap.NewNop()), controller.NewWebhookController(nil, zap.NewNop()))
+	document, err := openapi.Marshal(registry.Document(*version, routes.APIInfo))
+	if err != nil {
+		log.Fatalf("failed to render OpenAPI document: %v", err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(document)
+		return
+	}
+	if err := os.WriteFile(*output, document, 0o644); err != nil {
+		log.Fatalf("failed to write %s: %v", *output, err)
+	}
+}
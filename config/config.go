@@ -0,0 +1,522 @@
+package config
+
+import (
+	"crudspanner/migrations"
+	"crudspanner/model"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config is the configuration of the server. Load reads every setting from
+// a command-line flag, the environment or an optional env file, in that
+// order of precedence, and falls back to a default.
+type Config struct {
+	// Profile selects a set of defaults; ProfileDev or empty.
+	Profile  string
+	HTTP     HTTPServer
+	Database Database
+	Log      Log
+
+	KeyStrategy       model.KeyStrategy
+	ReadStaleness     model.Staleness
+	ListReadStaleness model.Staleness
+
+	RequestTimeout    time.Duration
+	MaxRequestTimeout time.Duration
+	// IAPAudience is the audience of the Identity-Aware Proxy assertions
+	// that identify the actor of a request. With one, requests without a
+	// valid assertion are rejected; without one every request is anonymous.
+	IAPAudience string
+	// TrustedProxies are the addresses and CIDR ranges whose
+	// X-Forwarded-For headers are used for the client IP.
+	TrustedProxies []string
+
+	ChangePollInterval      time.Duration
+	WebhookDeliveryInterval time.Duration
+	OutboxRelayInterval     time.Duration
+	OutboxWebhookURL        string
+	// OutboxRetention is how long published outbox events are kept; 0 keeps
+	// them forever.
+	OutboxRetention time.Duration
+
+	// SoftDeleteRetention is how long soft-deleted users can be restored
+	// before they are permanently deleted. It defaults to 0, which keeps them
+	// forever; purging has to be turned on explicitly.
+	SoftDeleteRetention time.Duration
+	PurgeInterval       time.Duration
+
+	LegacyRoutesEnabled bool
+	// LegacyRoutesSunset is zero when no removal date is announced.
+	LegacyRoutesSunset time.Time
+}
+
+// HTTPServer configures the HTTP server. Timeouts of 0 are unlimited.
+type HTTPServer struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout is how long running requests may take to finish when
+	// the server stops.
+	ShutdownTimeout time.Duration
+	// ShutdownDelay is how long /readyz reports not ready before the server
+	// stops accepting connections, so load balancers stop sending requests
+	// first.
+	ShutdownDelay time.Duration
+	// HealthCheckTimeout bounds every dependency check of /readyz.
+	HealthCheckTimeout time.Duration
+}
+
+// Log configures the logger.
+type Log struct {
+	Level zapcore.Level
+	// Format is LogFormatJSON or LogFormatConsole.
+	Format string
+}
+
+// Database selects and locates the database.
+type Database struct {
+	Driver string
+	// ProjectID, InstanceID and DatabaseID name the Spanner database. They
+	// are optional on the emulator.
+	ProjectID    string
+	InstanceID   string
+	DatabaseID   string
+	EmulatorHost string
+	// URL is the connection string of DriverPostgres.
+	URL           string
+	SQLitePath    string
+	MigrationMode migrations.StartupMode
+	Spanner       SpannerClient
+
+	// The settings of the database/sql connection pool; 0 keeps the
+	// database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout bounds every statement; 0 leaves statements to the
+	// request deadline.
+	StatementTimeout time.Duration
+}
+
+// SpannerClient tunes the Spanner client. Zero values keep the defaults of
+// the client library.
+type SpannerClient struct {
+	MinSessions uint64
+	MaxSessions uint64
+	NumChannels int
+	// DatabaseRole is the fine-grained access control role the server acts
+	// as.
+	DatabaseRole string
+	// RetryAbortsInternally lets the driver replay aborted transactions
+	// before the repository retries them.
+	RetryAbortsInternally bool
+}
+
+// SpannerName is the full name of the Spanner database.
+func (d Database) SpannerName() string {
+	project, instance, database := d.ProjectID, d.InstanceID, d.DatabaseID
+	if d.EmulatorHost != "" {
+		project = valueOr(project, "emulator-project")
+		instance = valueOr(instance, "emulator-instance")
+		database = valueOr(database, "crudspanner")
+	}
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, database)
+}
+
+// SpannerDSN is the go-sql-spanner connection string: the database name
+// followed by the client options that are set.
+func (d Database) SpannerDSN() string {
+	dsn := d.SpannerName()
+	if d.Spanner.MinSessions > 0 {
+		dsn += fmt.Sprintf(";minSessions=%d", d.Spanner.MinSessions)
+	}
+	if d.Spanner.MaxSessions > 0 {
+		dsn += fmt.Sprintf(";maxSessions=%d", d.Spanner.MaxSessions)
+	}
+	if d.Spanner.NumChannels > 0 {
+		dsn += fmt.Sprintf(";numChannels=%d", d.Spanner.NumChannels)
+	}
+	if d.Spanner.DatabaseRole != "" {
+		dsn += ";databaseRole=" + d.Spanner.DatabaseRole
+	}
+	if !d.Spanner.RetryAbortsInternally {
+		dsn += ";retryAbortsInternally=false"
+	}
+	return dsn
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// ValidationError lists every invalid setting, so that they can all be
+// fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+const defaultEnvFile = ".env"
+
+// setting is a value read from the environment variable key or from the
+// flag of the same name in lower case with dashes, such as -db-driver for
+// DB_DRIVER.
+type setting struct {
+	key          string
+	defaultValue string
+	// devValue replaces defaultValue in the dev profile.
+	devValue string
+	usage    string
+	parse    func(value string) error
+}
+
+func (s setting) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(s.key, "_", "-"))
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "APP_PROFILE", usage: "profile with a set of defaults: dev, or empty in production", parse: c.parseProfile},
+		{key: "HTTP_ADDR", defaultValue: ":8080", usage: "address the HTTP server listens on", parse: stringValue(&c.HTTP.Addr)},
+		{key: "HTTP_READ_TIMEOUT", defaultValue: "30s", usage: "time to read a whole request including its body; 0 is unlimited", parse: durationValue(&c.HTTP.ReadTimeout)},
+		{key: "HTTP_READ_HEADER_TIMEOUT", defaultValue: "10s", usage: "time to read the request headers; 0 is unlimited", parse: durationValue(&c.HTTP.ReadHeaderTimeout)},
+		{key: "HTTP_WRITE_TIMEOUT", defaultValue: "3m", usage: "time to handle a request and write the response, longer than MAX_REQUEST_TIMEOUT; 0 is unlimited", parse: durationValue(&c.HTTP.WriteTimeout)},
+		{key: "HTTP_IDLE_TIMEOUT", defaultValue: "2m", usage: "how long keep-alive connections wait for the next request; 0 is unlimited", parse: durationValue(&c.HTTP.IdleTimeout)},
+		{key: "HTTP_MAX_HEADER_BYTES", defaultValue: "1048576", usage: "largest size of the request headers", parse: intValue(&c.HTTP.MaxHeaderBytes)},
+		{key: "SHUTDOWN_TIMEOUT", defaultValue: "30s", usage: "how long running requests may take to finish on SIGTERM or SIGINT", parse: positiveDuration(&c.HTTP.ShutdownTimeout)},
+		{key: "SHUTDOWN_DELAY", defaultValue: "0s", usage: "how long /readyz reports not ready on SIGTERM or SIGINT before the server stops accepting connections", parse: durationValue(&c.HTTP.ShutdownDelay)},
+		{key: "HEALTH_CHECK_TIMEOUT", defaultValue: "2s", usage: "deadline of every dependency check of /readyz", parse: positiveDuration(&c.HTTP.HealthCheckTimeout)},
+		{key: "DB_DRIVER", defaultValue: DriverSpanner, usage: "database: spanner, postgres, sqlite or memory", parse: c.parseDriver},
+		{key: "PROJECT_ID", usage: "Google Cloud project of the Spanner instance", parse: stringValue(&c.Database.ProjectID)},
+		{key: "INSTANCE_ID", usage: "Spanner instance", parse: stringValue(&c.Database.InstanceID)},
+		{key: "DATABASE_ID", usage: "Spanner database", parse: stringValue(&c.Database.DatabaseID)},
+		{key: "SPANNER_EMULATOR_HOST", devValue: "localhost:9010", usage: "address of the Spanner emulator, which needs no credentials", parse: stringValue(&c.Database.EmulatorHost)},
+		{key: "DATABASE_URL", usage: "connection string of the postgres driver", parse: stringValue(&c.Database.URL)},
+		{key: "SQLITE_PATH", defaultValue: "crudspanner.db", usage: "database file of the sqlite driver", parse: stringValue(&c.Database.SQLitePath)},
+		{key: "SPANNER_MIN_SESSIONS", defaultValue: "0", usage: "sessions the Spanner session pool keeps open; 0 keeps the library default", parse: uintValue(&c.Database.Spanner.MinSessions)},
+		{key: "SPANNER_MAX_SESSIONS", defaultValue: "0", usage: "most sessions the Spanner session pool opens; 0 keeps the library default", parse: uintValue(&c.Database.Spanner.MaxSessions)},
+		{key: "SPANNER_NUM_CHANNELS", defaultValue: "0", usage: "gRPC channels to Spanner; 0 keeps the library default", parse: intValue(&c.Database.Spanner.NumChannels)},
+		{key: "SPANNER_DATABASE_ROLE", usage: "fine-grained access control role used for Spanner requests", parse: stringValue(&c.Database.Spanner.DatabaseRole)},
+		{key: "SPANNER_RETRY_ABORTS_INTERNALLY", defaultValue: "true", usage: "whether the Spanner driver replays aborted transactions itself before they are retried from scratch", parse: boolValue(&c.Database.Spanner.RetryAbortsInternally)},
+		{key: "DB_MAX_OPEN_CONNS", defaultValue: "0", usage: "most open database connections; 0 is unlimited", parse: intValue(&c.Database.MaxOpenConns)},
+		{key: "DB_MAX_IDLE_CONNS", defaultValue: "0", usage: "most idle database connections; 0 keeps the database/sql default of 2", parse: intValue(&c.Database.MaxIdleConns)},
+		{key: "DB_CONN_MAX_LIFETIME", defaultValue: "0s", usage: "how long a database connection is reused; 0 is forever", parse: durationValue(&c.Database.ConnMaxLifetime)},
+		{key: "DB_CONN_MAX_IDLE_TIME", defaultValue: "0s", usage: "how long a database connection may stay idle; 0 is forever", parse: durationValue(&c.Database.ConnMaxIdleTime)},
+		{key: "DB_STATEMENT_TIMEOUT", defaultValue: "0s", usage: "deadline of every database statement; 0 leaves statements to the request deadline", parse: durationValue(&c.Database.StatementTimeout)},
+		{key: "MIGRATE_ON_STARTUP", defaultValue: string(migrations.StartupCheck), devValue: string(migrations.StartupUp), usage: "pending migrations on startup: check refuses to start, up applies them, off ignores them", parse: c.parseMigrationMode},
+		{key: "KEY_STRATEGY", defaultValue: string(model.KeyStrategyUUIDv4), usage: "key generation of new users: uuidv4 or uuidv7", parse: c.parseKeyStrategy},
+		{key: "READ_STALENESS", defaultValue: "strong", usage: "default staleness of single user reads: strong, 15s or max:15s", parse: stalenessValue(&c.ReadStaleness)},
+		{key: "LIST_READ_STALENESS", defaultValue: "strong", usage: "default staleness of the user list", parse: stalenessValue(&c.ListReadStaleness)},
+		{key: "REQUEST_TIMEOUT", defaultValue: "30s", usage: "deadline of every request", parse: positiveDuration(&c.RequestTimeout)},
+		{key: "MAX_REQUEST_TIMEOUT", defaultValue: "2m", usage: "longest deadline a client may ask for with X-Request-Timeout", parse: positiveDuration(&c.MaxRequestTimeout)},
+		{key: "IAP_AUDIENCE", usage: "audience of the Identity-Aware Proxy JWT that identifies the user recorded in the audit log, such as /projects/NUMBER/global/backendServices/ID; requests without a valid assertion are rejected, except health checks; unset records every user as anonymous", parse: stringValue(&c.IAPAudience)},
+		{key: "TRUSTED_PROXIES", usage: "comma-separated addresses and CIDR ranges of the proxies whose X-Forwarded-For header gives the client IP; unset uses the address of the connection", parse: c.parseTrustedProxies},
+		{key: "CHANGE_POLL_INTERVAL", defaultValue: "1s", usage: "how often the change feed polls the change stream", parse: positiveDuration(&c.ChangePollInterval)},
+		{key: "WEBHOOK_DELIVERY_INTERVAL", defaultValue: "1s", usage: "how often due webhook deliveries are sent", parse: positiveDuration(&c.WebhookDeliveryInterval)},
+		{key: "OUTBOX_RELAY_INTERVAL", defaultValue: "1s", usage: "how often pending outbox events are delivered", parse: positiveDuration(&c.OutboxRelayInterval)},
+		{key: "OUTBOX_WEBHOOK_URL", usage: "URL every event is posted to in addition to the webhook subscriptions", parse: c.parseOutboxWebhookURL},
+		{key: "OUTBOX_RETENTION", defaultValue: "168h", usage: "how long published outbox events are kept before they are deleted; 0 keeps them forever", parse: durationValue(&c.OutboxRetention)},
+		{key: "SOFT_DELETE_RETENTION", defaultValue: "0", usage: "how long soft-deleted users can be restored before they are permanently deleted, such as 720h; 0 keeps them forever", parse: durationValue(&c.SoftDeleteRetention)},
+		{key: "PURGE_INTERVAL", defaultValue: "1h", usage: "how often soft-deleted users and published outbox events are purged", parse: positiveDuration(&c.PurgeInterval)},
+		{key: "LEGACY_ROUTES_ENABLED", defaultValue: "true", usage: "whether the unversioned routes are still served", parse: boolValue(&c.LegacyRoutesEnabled)},
+		{key: "LEGACY_ROUTES_SUNSET", usage: "date the unversioned routes will be removed, as 2006-01-02", parse: c.parseLegacyRoutesSunset},
+		{key: "LOG_LEVEL", defaultValue: "info", devValue: "debug", usage: "lowest level that is logged: debug, info, warn or error", parse: c.parseLogLevel},
+		{key: "LOG_FORMAT", defaultValue: LogFormatJSON, devValue: LogFormatConsole, usage: "log format: json, or console for people", parse: c.parseLogFormat},
+	}
+}
+
+// Load reads the configuration from the flags in args, the environment and
+// the env file named by -env-file or ENV_FILE. The default env file .env is
+// optional; variables already in the environment take precedence over it.
+// Load returns flag.ErrHelp when args ask for help and a ValidationError
+// when settings are invalid.
+func Load(args []string) (*Config, error) {
+	c := &Config{}
+	settings := c.settings()
+
+	flags := flag.NewFlagSet("crudspanner", flag.ContinueOnError)
+	envFile := flags.String("env-file", "", "env file to read (ENV_FILE, default "+defaultEnvFile+")")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		usage := s.usage + " (" + s.key + ")"
+		if s.defaultValue != "" {
+			usage += `, default "` + s.defaultValue + `"`
+		}
+		values[s.key] = flags.String(s.flagName(), "", usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	flagged := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { flagged[f.Name] = true })
+
+	if err := loadEnvFile(valueOr(*envFile, os.Getenv("ENV_FILE"))); err != nil {
+		return nil, err
+	}
+
+	lookup := func(s setting) string {
+		if flagged[s.flagName()] {
+			return *values[s.key]
+		}
+		return os.Getenv(s.key)
+	}
+	profile := lookup(settings[0])
+
+	var problems []string
+	for _, s := range settings {
+		value := lookup(s)
+		if value == "" {
+			value = s.defaultValue
+			if profile == ProfileDev && s.devValue != "" {
+				value = s.devValue
+			}
+		}
+		if err := s.parse(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.key, err))
+		}
+	}
+	problems = append(problems, c.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	// The Spanner clients only learn about the emulator from the environment.
+	if c.Database.EmulatorHost != "" {
+		os.Setenv("SPANNER_EMULATOR_HOST", c.Database.EmulatorHost)
+	}
+	return c, nil
+}
+
+func loadEnvFile(path string) error {
+	if path == "" {
+		err := godotenv.Load(defaultEnvFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("reading env file: %w", err)
+	}
+	return nil
+}
+
+// validate checks settings that depend on each other.
+func (c *Config) validate() []string {
+	var problems []string
+	switch c.Database.Driver {
+	case DriverSpanner:
+		if c.Database.EmulatorHost == "" {
+			required := []struct{ key, value string }{
+				{"PROJECT_ID", c.Database.ProjectID},
+				{"INSTANCE_ID", c.Database.InstanceID},
+				{"DATABASE_ID", c.Database.DatabaseID},
+			}
+			for _, setting := range required {
+				if setting.value == "" {
+					problems = append(problems, setting.key+": required for DB_DRIVER=spanner unless SPANNER_EMULATOR_HOST is set")
+				}
+			}
+		}
+	case DriverPostgres:
+		if c.Database.URL == "" {
+			problems = append(problems, "DATABASE_URL: required for DB_DRIVER=postgres")
+		}
+	case DriverSQLite:
+		if !sqliteSupported {
+			problems = append(problems, "DB_DRIVER: sqlite needs a server built with CGO_ENABLED=1")
+		}
+	}
+	if c.Database.Spanner.MaxSessions > 0 && c.Database.Spanner.MaxSessions < c.Database.Spanner.MinSessions {
+		problems = append(problems, fmt.Sprintf("SPANNER_MAX_SESSIONS: %d is less than SPANNER_MIN_SESSIONS %d", c.Database.Spanner.MaxSessions, c.Database.Spanner.MinSessions))
+	}
+	if c.Database.Driver == DriverSQLite && c.Database.MaxOpenConns > 1 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS: SQLite allows a single connection")
+	}
+	if c.MaxRequestTimeout != 0 && c.MaxRequestTimeout < c.RequestTimeout {
+		problems = append(problems, fmt.Sprintf("MAX_REQUEST_TIMEOUT: %v is shorter than REQUEST_TIMEOUT %v", c.MaxRequestTimeout, c.RequestTimeout))
+	}
+	// A response that is still being handled when the write timeout passes
+	// is cut off without the 504 of the request timeout.
+	if c.HTTP.WriteTimeout != 0 && c.HTTP.WriteTimeout <= c.MaxRequestTimeout {
+		problems = append(problems, fmt.Sprintf("HTTP_WRITE_TIMEOUT: %v is not longer than MAX_REQUEST_TIMEOUT %v", c.HTTP.WriteTimeout, c.MaxRequestTimeout))
+	}
+	return problems
+}
+
+func (c *Config) parseProfile(value string) error {
+	if value != "" && value != ProfileDev {
+		return fmt.Errorf("unknown profile %q, use %s or leave it empty", value, ProfileDev)
+	}
+	c.Profile = value
+	return nil
+}
+
+func (c *Config) parseDriver(value string) error {
+	drivers := []string{DriverSpanner, DriverPostgres, DriverSQLite, DriverMemory}
+	if !slices.Contains(drivers, value) {
+		return fmt.Errorf("unknown driver %q, use %s", value, strings.Join(drivers, ", "))
+	}
+	c.Database.Driver = value
+	return nil
+}
+
+func (c *Config) parseMigrationMode(value string) (err error) {
+	c.Database.MigrationMode, err = migrations.ParseStartupMode(value)
+	return err
+}
+
+func (c *Config) parseLogLevel(value string) error {
+	level, err := zapcore.ParseLevel(value)
+	if err != nil || level > zapcore.ErrorLevel {
+		return fmt.Errorf("unknown level %q, use debug, info, warn or error", value)
+	}
+	c.Log.Level = level
+	return nil
+}
+
+func (c *Config) parseLogFormat(value string) error {
+	if value != LogFormatJSON && value != LogFormatConsole {
+		return fmt.Errorf("unknown format %q, use %s or %s", value, LogFormatJSON, LogFormatConsole)
+	}
+	c.Log.Format = value
+	return nil
+}
+
+func (c *Config) parseKeyStrategy(value string) (err error) {
+	c.KeyStrategy, err = model.ParseKeyStrategy(value)
+	return err
+}
+
+func (c *Config) parseOutboxWebhookURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", value)
+	}
+	c.OutboxWebhookURL = value
+	return nil
+}
+
+func (c *Config) parseTrustedProxies(value string) error {
+	c.TrustedProxies = nil
+	for _, proxy := range strings.Split(value, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+			}
+		}
+		c.TrustedProxies = append(c.TrustedProxies, proxy)
+	}
+	return nil
+}
+
+func (c *Config) parseLegacyRoutesSunset(value string) error {
+	if value == "" {
+		return nil
+	}
+	sunset, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return fmt.Errorf("%q is not a date like 2006-01-02", value)
+	}
+	c.LegacyRoutesSunset = sunset
+	return nil
+}
+
+func stringValue(field *string) func(string) error {
+	return func(value string) error {
+		*field = value
+		return nil
+	}
+}
+
+func boolValue(field *bool) func(string) error {
+	return func(value string) (err error) {
+		*field, err = strconv.ParseBool(value)
+		return err
+	}
+}
+
+func intValue(field *int) func(string) error {
+	return func(value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return fmt.Errorf("%q is not a number of at least 0", value)
+		}
+		*field = number
+		return nil
+	}
+}
+
+func uintValue(field *uint64) func(string) error {
+	return func(value string) error {
+		number, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number of at least 0", value)
+		}
+		*field = number
+		return nil
+	}
+}
+
+func stalenessValue(field *model.Staleness) func(string) error {
+	return func(value string) (err error) {
+		*field, err = model.ParseStaleness(value)
+		return err
+	}
+}
+
+func durationValue(field *time.Duration) func(string) error {
+	return func(value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 30s", value)
+		}
+		if duration < 0 {
+			return fmt.Errorf("%v is negative", duration)
+		}
+		*field = duration
+		return nil
+	}
+}
+
+func positiveDuration(field *time.Duration) func(string) error {
+	parse := durationValue(field)
+	return func(value string) error {
+		if err := parse(value); err != nil {
+			return err
+		}
+		if *field == 0 {
+			return errors.New("must be longer than 0s")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"crudspanner/migrations"
+	"crudspanner/model"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// clearEnv isolates a test from the environment it runs in. Variables an
+// env file sets are removed again when the test ends.
+func clearEnv(t *testing.T) {
+	t.Setenv("ENV_FILE", "")
+	for _, s := range (&Config{}).settings() {
+		t.Setenv(s.key, "")
+		os.Unsetenv(s.key)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := Load([]string{"-db-driver", "memory"})
+	require.NoError(t, err)
+	assert.Equal(t, DriverMemory, cfg.Database.Driver)
+	assert.Equal(t, Log{Level: zapcore.InfoLevel, Format: LogFormatJSON}, cfg.Log)
+	assert.Equal(t, ":8080", cfg.HTTP.Addr)
+	assert.Equal(t, 3*time.Minute, cfg.HTTP.WriteTimeout)
+	assert.Equal(t, 1<<20, cfg.HTTP.MaxHeaderBytes)
+	assert.Equal(t, 30*time.Second, cfg.HTTP.ShutdownTimeout)
+	assert.Zero(t, cfg.HTTP.ShutdownDelay)
+	assert.Equal(t, 2*time.Second, cfg.HTTP.HealthCheckTimeout)
+	assert.Equal(t, migrations.StartupCheck, cfg.Database.MigrationMode)
+	assert.Equal(t, model.KeyStrategyUUIDv4, cfg.KeyStrategy)
+	assert.True(t, cfg.ReadStaleness.IsStrong())
+	assert.Equal(t, 30*time.Second, cfg.RequestTimeout)
+	assert.Equal(t, 2*time.Minute, cfg.MaxRequestTimeout)
+	assert.Empty(t, cfg.IAPAudience)
+	assert.Empty(t, cfg.TrustedProxies)
+	assert.Zero(t, cfg.SoftDeleteRetention)
+	assert.Equal(t, 7*24*time.Hour, cfg.OutboxRetention)
+	assert.True(t, cfg.LegacyRoutesEnabled)
+	assert.True(t, cfg.LegacyRoutesSunset.IsZero())
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	envFile := filepath.Join(t.TempDir(), "test.env")
+	require.NoError(t, os.WriteFile(envFile, []byte("DB_DRIVER=memory\nHTTP_ADDR=:1000\nREQUEST_TIMEOUT=10s\nKEY_STRATEGY=uuidv7\n"), 0o600))
+	t.Setenv("HTTP_ADDR", ":2000")
+	t.Setenv("REQUEST_TIMEOUT", "20s")
+
+	cfg, err := Load([]string{"-env-file", envFile, "-request-timeout", "40s"})
+	require.NoError(t, err)
+	assert.Equal(t, model.KeyStrategyUUIDv7, cfg.KeyStrategy, "the env file is read")
+	assert.Equal(t, ":2000", cfg.HTTP.Addr, "the environment wins over the env file")
+	assert.Equal(t, 40*time.Second, cfg.RequestTimeout, "flags win over the environment")
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("TRUSTED_PROXIES", "35.191.0.0/16, 130.211.0.0/22,::1")
+
+	cfg, err := Load([]string{"-db-driver", "memory"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"35.191.0.0/16", "130.211.0.0/22", "::1"}, cfg.TrustedProxies)
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DB_DRIVER", "mysql")
+	t.Setenv("REQUEST_TIMEOUT", "soon")
+	t.Setenv("PURGE_INTERVAL", "0s")
+	t.Setenv("LEGACY_ROUTES_SUNSET", "next year")
+	t.Setenv("OUTBOX_WEBHOOK_URL", "ftp://example.com")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, proxy.internal")
+	t.Setenv("LOG_LEVEL", "fatal")
+	t.Setenv("LOG_FORMAT", "xml")
+
+	_, err := Load(nil)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`DB_DRIVER: unknown driver "mysql", use spanner, postgres, sqlite, memory`,
+		`REQUEST_TIMEOUT: "soon" is not a duration like 30s`,
+		`TRUSTED_PROXIES: "proxy.internal" is not an IP address or CIDR range`,
+		`OUTBOX_WEBHOOK_URL: "ftp://example.com" is not an http or https URL`,
+		`PURGE_INTERVAL: must be longer than 0s`,
+		`LEGACY_ROUTES_SUNSET: "next year" is not a date like 2006-01-02`,
+		`LOG_LEVEL: unknown level "fatal", use debug, info, warn or error`,
+		`LOG_FORMAT: unknown format "xml", use json or console`,
+	}, validationErr.Problems)
+	assert.Contains(t, err.Error(), "invalid configuration:\n  DB_DRIVER")
+}
+
+func TestLoadChecksRelatedSettings(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("MAX_REQUEST_TIMEOUT", "10s")
+
+	_, err := Load(nil)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		"PROJECT_ID: required for DB_DRIVER=spanner unless SPANNER_EMULATOR_HOST is set",
+		"INSTANCE_ID: required for DB_DRIVER=spanner unless SPANNER_EMULATOR_HOST is set",
+		"DATABASE_ID: required for DB_DRIVER=spanner unless SPANNER_EMULATOR_HOST is set",
+		"MAX_REQUEST_TIMEOUT: 10s is shorter than REQUEST_TIMEOUT 30s",
+	}, validationErr.Problems)
+
+	clearEnv(t)
+	t.Setenv("DB_DRIVER", "postgres")
+	_, err = Load(nil)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"DATABASE_URL: required for DB_DRIVER=postgres"}, validationErr.Problems)
+
+	clearEnv(t)
+	_, err = Load([]string{"-db-driver", "memory", "-http-write-timeout", "2m"})
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"HTTP_WRITE_TIMEOUT: 2m0s is not longer than MAX_REQUEST_TIMEOUT 2m0s"}, validationErr.Problems)
+}
+
+func TestLoadDevProfile(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := Load([]string{"-app-profile", "dev"})
+	require.NoError(t, err)
+	assert.Equal(t, ProfileDev, cfg.Profile)
+	assert.Equal(t, Log{Level: zapcore.DebugLevel, Format: LogFormatConsole}, cfg.Log)
+	assert.Equal(t, "localhost:9010", cfg.Database.EmulatorHost)
+	assert.Equal(t, migrations.StartupUp, cfg.Database.MigrationMode)
+	assert.Equal(t, "projects/emulator-project/instances/emulator-instance/databases/crudspanner", cfg.Database.SpannerName())
+	assert.Equal(t, "localhost:9010", os.Getenv("SPANNER_EMULATOR_HOST"), "the Spanner clients read the emulator from the environment")
+
+	t.Setenv("MIGRATE_ON_STARTUP", "check")
+	cfg, err = Load([]string{"-app-profile", "dev"})
+	require.NoError(t, err)
+	assert.Equal(t, migrations.StartupCheck, cfg.Database.MigrationMode, "explicit settings win over the profile")
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	clearEnv(t)
+
+	_, err := Load([]string{"-db-driver", "memory"})
+	assert.NoError(t, err, "the default env file is optional")
+
+	_, err = Load([]string{"-db-driver", "memory", "-env-file", filepath.Join(t.TempDir(), "missing.env")})
+	assert.ErrorIs(t, err, os.ErrNotExist, "a named env file is required")
+}
+
+func TestLoadHelp(t *testing.T) {
+	clearEnv(t)
+
+	_, err := Load([]string{"-h"})
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+}
+
+func TestSpannerDSN(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SPANNER_EMULATOR_HOST", "localhost:9010")
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "projects/emulator-project/instances/emulator-instance/databases/crudspanner", cfg.Database.SpannerDSN(), "library defaults add no options")
+
+	cfg, err = Load([]string{
+		"-spanner-min-sessions", "10", "-spanner-max-sessions", "200", "-spanner-num-channels", "8",
+		"-spanner-database-role", "api_reader", "-spanner-retry-aborts-internally=false",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "projects/emulator-project/instances/emulator-instance/databases/crudspanner"+
+		";minSessions=10;maxSessions=200;numChannels=8;databaseRole=api_reader;retryAbortsInternally=false", cfg.Database.SpannerDSN())
+}
+
+func TestLoadChecksPoolSettings(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SPANNER_EMULATOR_HOST", "localhost:9010")
+	t.Setenv("SPANNER_MIN_SESSIONS", "100")
+	t.Setenv("SPANNER_MAX_SESSIONS", "10")
+	t.Setenv("SPANNER_NUM_CHANNELS", "-1")
+
+	_, err := Load(nil)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`SPANNER_NUM_CHANNELS: "-1" is not a number of at least 0`,
+		"SPANNER_MAX_SESSIONS: 10 is less than SPANNER_MIN_SESSIONS 100",
+	}, validationErr.Problems)
+
+	clearEnv(t)
+	_, err = Load([]string{"-db-driver", "sqlite", "-db-max-open-conns", "4"})
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Problems, "DB_MAX_OPEN_CONNS: SQLite allows a single connection")
+}
+
+func TestLoadChecksSQLiteSupport(t *testing.T) {
+	clearEnv(t)
+	_, err := Load([]string{"-db-driver", "sqlite"})
+	if sqliteSupported {
+		require.NoError(t, err)
+		return
+	}
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{"DB_DRIVER: sqlite needs a server built with CGO_ENABLED=1"}, validationErr.Problems)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"crudspanner/repositories"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DriverSpanner = "spanner"
+	// DriverPostgres connects to DATABASE_URL, either a PostgreSQL server or
+	// PGAdapter in front of a Spanner database with the PostgreSQL dialect.
+	DriverPostgres = "postgres"
+	// DriverSQLite stores the data in the file at SQLITE_PATH.
+	DriverSQLite = "sqlite"
+	// DriverMemory keeps all data in memory and needs no database, for local
+	// development. Nothing survives a restart.
+	DriverMemory = "memory"
+)
+
+// ConnectRepositories connects to the database of cfg and returns its
+// repositories. The change feed is only available on Spanner.
+func ConnectRepositories(cfg *Config, logger *zap.Logger) (repositories.Repositories, error) {
+	if cfg.Database.Driver == DriverMemory {
+		logger.Warn("Using the in-memory database; data is lost on restart")
+		return repositories.NewMemoryRepositories(cfg.KeyStrategy.Generator()), nil
+	}
+
+	db, err := ConnectDB(cfg)
+	if err != nil {
+		return repositories.Repositories{}, err
+	}
+	if cfg.Database.Driver == DriverSpanner {
+		client, err := NewSpannerClient(context.Background(), cfg)
+		if err != nil {
+			closeDB(db)
+			return repositories.Repositories{}, fmt.Errorf("connecting the Spanner client: %w", err)
+		}
+		return repositories.NewSpannerRepositories(db, client, logger, cfg.KeyStrategy.Generator()), nil
+	}
+	return repositories.NewRepositories(db, logger, cfg.KeyStrategy.Generator()), nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+
+	redacted = "[REDACTED]"
+)
+
+// sensitiveKeys are the parts of field and query parameter names whose
+// values are never logged.
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "cookie", "signature", "api_key", "apikey"}
+
+// NewLogger builds the logger of the server. It writes to stderr without
+// sampling, so that no request goes unlogged, and redacts the values of
+// fields with sensitive names as well as credentials in URL fields.
+func NewLogger(cfg Log) (*zap.Logger, error) {
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = zap.NewAtomicLevelAt(cfg.Level)
+	zapConfig.Sampling = nil
+	zapConfig.Encoding = cfg.Format
+	if cfg.Format == LogFormatConsole {
+		zapConfig.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	} else {
+		zapConfig.EncoderConfig.TimeKey = "time"
+		zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	}
+	return zapConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return redactingCore{core}
+	}))
+}
+
+// redactingCore replaces sensitive field values before they reach the
+// encoder. Values nested in objects are not inspected, so structs that
+// hold secrets must not be logged as a whole.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	var result []zapcore.Field
+	for i, field := range fields {
+		replacement, ok := redactField(field)
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = append(make([]zapcore.Field, 0, len(fields)), fields...)
+		}
+		result[i] = replacement
+	}
+	if result == nil {
+		return fields
+	}
+	return result
+}
+
+func redactField(field zapcore.Field) (zapcore.Field, bool) {
+	if isSensitive(field.Key) {
+		return zap.String(field.Key, redacted), true
+	}
+	key := strings.ToLower(field.Key)
+	if field.Type == zapcore.StringType && (key == "url" || strings.HasSuffix(key, "_url")) {
+		if clean := redactURL(field.String); clean != field.String {
+			return zap.String(field.Key, clean), true
+		}
+	}
+	return field, false
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL hides the password and the sensitive query parameters of
+// rawURL. Values that do not parse are hidden completely.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	query := parsed.Query()
+	changed := false
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, "REDACTED")
+			changed = true
+		}
+	}
+	if changed {
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.Redacted()
+}
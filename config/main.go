@@ -1,26 +1,119 @@
 package config
 
 import (
-	"crudspanner/model"
+	"context"
+	"crudspanner/emulator"
+	"crudspanner/migrations"
 	"fmt"
-	"os"
 
+	"cloud.google.com/go/spanner"
 	spannergorm "github.com/googleapis/go-gorm-spanner"
 	_ "github.com/googleapis/go-sql-spanner"
+	"google.golang.org/api/option"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func getDatabaseString() string {
-	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", os.Getenv("PROJECT_ID"), os.Getenv("INSTANCE_ID"), os.Getenv("DATABASE_ID"))
+// OpenDB connects to the database of cfg without touching its schema. On
+// the Spanner emulator the instance and the database are created first if
+// they do not exist.
+func OpenDB(cfg *Config) (*gorm.DB, error) {
+	db, err := openDialect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	if cfg.Database.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+	if cfg.Database.Driver == DriverSQLite {
+		// SQLite allows a single writer; more connections only produce
+		// "database is locked" errors.
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if cfg.Database.StatementTimeout > 0 {
+		if err := db.Use(statementTimeout(cfg.Database.StatementTimeout)); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func openDialect(cfg *Config) (*gorm.DB, error) {
+	switch driver := cfg.Database.Driver; driver {
+	case DriverSpanner:
+		if cfg.Database.EmulatorHost != "" {
+			if err := emulator.EnsureDatabase(context.Background(), cfg.Database.SpannerName()); err != nil {
+				return nil, err
+			}
+		}
+		return gorm.Open(spannergorm.New(spannergorm.Config{DriverName: "spanner", DSN: cfg.Database.SpannerDSN()}), &gorm.Config{})
+	case DriverPostgres:
+		return gorm.Open(postgres.Open(cfg.Database.URL), &gorm.Config{})
+	case DriverSQLite:
+		dialector, err := openSQLite(cfg.Database.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		return gorm.Open(dialector, &gorm.Config{})
+	default:
+		return nil, fmt.Errorf("DB_DRIVER %s has no SQL database", driver)
+	}
 }
 
-func ConnectDB() *gorm.DB {
-	db, err := gorm.Open(spannergorm.New(spannergorm.Config{DriverName: "spanner", DSN: getDatabaseString()}), &gorm.Config{})
+// NewSpannerClient connects a Spanner client to the database, for features
+// the database/sql driver does not offer such as change streams. It is
+// tuned like the client of the driver.
+func NewSpannerClient(ctx context.Context, cfg *Config) (*spanner.Client, error) {
+	settings := cfg.Database.Spanner
+	clientConfig := spanner.ClientConfig{
+		SessionPoolConfig: spanner.DefaultSessionPoolConfig,
+		DatabaseRole:      settings.DatabaseRole,
+	}
+	if settings.MinSessions > 0 {
+		clientConfig.SessionPoolConfig.MinOpened = settings.MinSessions
+	}
+	if settings.MaxSessions > 0 {
+		clientConfig.SessionPoolConfig.MaxOpened = settings.MaxSessions
+	}
+	var opts []option.ClientOption
+	if settings.NumChannels > 0 {
+		opts = append(opts, option.WithGRPCConnectionPool(settings.NumChannels))
+	}
+	return spanner.NewClientWithConfig(ctx, cfg.Database.SpannerName(), clientConfig, opts...)
+}
 
+// ConnectDB connects to the database and prepares its schema as selected by
+// the migration mode of cfg.
+func ConnectDB(cfg *Config) (*gorm.DB, error) {
+	db, err := OpenDB(cfg)
 	if err != nil {
-		panic("Failed to connect to database")
+		return nil, fmt.Errorf("connecting to the %s database: %w", cfg.Database.Driver, err)
 	}
 
-	db.AutoMigrate(&model.User{})
-	return db
+	schema, err := migrations.ForDialect(db.Dialector.Name())
+	if err != nil {
+		closeDB(db)
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	if err := migrations.New(db, schema).Startup(context.Background(), cfg.Database.MigrationMode); err != nil {
+		closeDB(db)
+		return nil, fmt.Errorf("database schema is not ready: %w", err)
+	}
+	return db, nil
+}
+
+// closeDB closes the connections of a database that could not be set up.
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
 }
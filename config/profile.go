@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+)
+
+// ProfileDev runs the server against a local Spanner emulator on its
+// default port with migrated and seeded data, so it needs no cloud
+// credentials.
+const ProfileDev = "dev"
+
+// devUsers are created by SeedDevData. Their password is "password".
+var devUsers = []model.User{
+	{Name: "Ada Lovelace", Email: "ada@example.com", Address: "12 St James's Square, London"},
+	{Name: "Grace Hopper", Email: "grace@example.com", Address: "1 Navy Yard, Arlington"},
+	{Name: "Alan Turing", Email: "alan@example.com", Address: "78 High Street, Hampton"},
+	{Name: "Katherine Johnson", Email: "katherine@example.com", Address: "1 NASA Drive, Hampton"},
+}
+
+// SeedDevData creates sample users in an empty database and returns how
+// many it created. Databases that hold users are left alone, so it is safe
+// on every start.
+func SeedDevData(ctx context.Context, users interfaces.UserRepository) (int, error) {
+	existing, err := users.GetAll(ctx)
+	if err != nil || len(existing) > 0 {
+		return 0, err
+	}
+	password := GeneratePassword("password")
+	err = users.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		for _, user := range devUsers {
+			user.Password = password
+			if _, err := repo.Create(ctx, &user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(devUsers), nil
+}
@@ -0,0 +1,16 @@
+//go:build cgo
+
+package config
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteSupported reports whether the sqlite driver is built in. The driver
+// wraps the C library of SQLite and needs cgo.
+const sqliteSupported = true
+
+func openSQLite(path string) (gorm.Dialector, error) {
+	return sqlite.Open(path), nil
+}
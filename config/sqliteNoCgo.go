@@ -0,0 +1,19 @@
+//go:build !cgo
+
+package config
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// sqliteSupported reports whether the sqlite driver is built in. Without
+// cgo the driver would only fail on the first statement.
+const sqliteSupported = false
+
+var errSQLiteUnsupported = errors.New("DB_DRIVER=sqlite needs a server built with CGO_ENABLED=1")
+
+func openSQLite(string) (gorm.Dialector, error) {
+	return nil, errSQLiteUnsupported
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const statementTimeoutKey = "crudspanner:statement_timeout"
+
+// statementTimeout is a gorm plugin that gives every statement a deadline,
+// as the Spanner driver has no timeout option. Statements whose context
+// ends sooner keep the earlier deadline. Rows() hands open rows to the
+// caller, so row queries are left alone.
+type statementTimeout time.Duration
+
+type statementTimeoutState struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+func (statementTimeout) Name() string {
+	return statementTimeoutKey
+}
+
+func (t statementTimeout) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	type register func(name string, fn func(*gorm.DB)) error
+	for _, processor := range []struct{ first, last register }{
+		{callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		{callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		{callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		{callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		{callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	} {
+		if err := processor.first(statementTimeoutKey+":start", t.start); err != nil {
+			return err
+		}
+		if err := processor.last(statementTimeoutKey+":finish", finishStatementTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t statementTimeout) start(db *gorm.DB) {
+	ctx, cancel := context.WithTimeout(db.Statement.Context, time.Duration(t))
+	db.Statement.Settings.Store(statementTimeoutKey, statementTimeoutState{parent: db.Statement.Context, cancel: cancel})
+	db.Statement.Context = ctx
+}
+
+// finishStatementTimeout restores the context of the statement, which gorm
+// reuses for the next statement on the same session.
+func finishStatementTimeout(db *gorm.DB) {
+	if value, ok := db.Statement.Settings.LoadAndDelete(statementTimeoutKey); ok {
+		state := value.(statementTimeoutState)
+		state.cancel()
+		db.Statement.Context = state.parent
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"context"
+	"crudspanner/model"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T, args ...string) *gorm.DB {
+	if !sqliteSupported {
+		t.Skip("SQLite needs a build with CGO_ENABLED=1")
+	}
+	clearEnv(t)
+	cfg, err := Load(append([]string{"-db-driver", "sqlite", "-sqlite-path", filepath.Join(t.TempDir(), "test.db")}, args...))
+	require.NoError(t, err)
+	db, err := OpenDB(cfg)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.User{}))
+	return db
+}
+
+func TestOpenDBAppliesPoolSettings(t *testing.T) {
+	db := openTestDB(t, "-db-conn-max-idle-time", "1m")
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections, "SQLite uses a single connection")
+}
+
+func TestStatementTimeout(t *testing.T) {
+	db := openTestDB(t, "-db-statement-timeout", "1m")
+
+	var deadlines []time.Time
+	err := db.Callback().Query().After(statementTimeoutKey+":start").Register("test:deadline", func(db *gorm.DB) {
+		deadline, _ := db.Statement.Context.Deadline()
+		deadlines = append(deadlines, deadline)
+	})
+	require.NoError(t, err)
+
+	var users []model.User
+	require.NoError(t, db.Find(&users).Error)
+	shortCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, db.WithContext(shortCtx).Find(&users).Error)
+
+	require.Len(t, deadlines, 2)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadlines[0], 5*time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadlines[1], time.Second, "an earlier deadline is kept")
+
+	session := db.WithContext(context.Background())
+	require.NoError(t, session.Find(&users).Error)
+	_, ok := session.Statement.Context.Deadline()
+	assert.False(t, ok, "the context of the session is restored")
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"crudspanner/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	healthService *services.HealthService
+}
+
+func NewHealthController(healthService *services.HealthService) *HealthController {
+	return &HealthController{healthService: healthService}
+}
+
+// Healthz answers as long as the process serves requests.
+func (ctrl *HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports the result of every dependency check, with 503 when the
+// server should not receive requests.
+func (ctrl *HealthController) Readyz(c *gin.Context) {
+	report := ctrl.healthService.Ready(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
@@ -0,0 +1,39 @@
+package controller
+
+import "crudspanner/model"
+
+// RegisterUserRequest is the body of a registration.
+type RegisterUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Address  string `json:"address"`
+	Password string `json:"password"`
+}
+
+func (r RegisterUserRequest) user() *model.User {
+	return &model.User{Name: r.Name, Email: r.Email, Address: r.Address, Password: r.Password}
+}
+
+// UpdateUserRequest is the body of an update. Empty fields are left as they
+// are.
+type UpdateUserRequest struct {
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+func (r UpdateUserRequest) user() *model.User {
+	return &model.User{Name: r.Name, Email: r.Email, Address: r.Address}
+}
+
+// PatchUserRequest is the body of a JSON merge patch of a user. Fields that
+// are missing are left as they are.
+type PatchUserRequest struct {
+	Name    *string `json:"name,omitempty"`
+	Email   *string `json:"email,omitempty"`
+	Address *string `json:"address,omitempty"`
+}
+
+func (r PatchUserRequest) patch() model.UserPatch {
+	return model.UserPatch{Name: r.Name, Email: r.Email, Address: r.Address}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"crudspanner/middleware"
+	"crudspanner/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every failed request, an RFC 9457 problem
+// details object served as application/problem+json. Error repeats Detail
+// for clients written before problem details were introduced.
+type ErrorResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Error  string `json:"error"`
+	// Message says which operation failed, where Detail says why.
+	Message string `json:"message,omitempty"`
+	// RequestID is the ID the request is logged with; it is also returned
+	// in the X-Request-ID header.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// MessageResponse is the body of requests that have nothing to return.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// errorJSON answers with problem details that carry the ID of the request.
+func errorJSON(c *gin.Context, status int, body gin.H) {
+	middleware.WriteProblem(c, status, body)
+}
+
+// legacyKey marks requests of the unversioned routes in the gin context.
+const legacyKey = "legacyResponses"
+
+// LegacyResponses makes the handlers of a route group render users the way
+// the unversioned routes always have, before model.User had JSON tags.
+func LegacyResponses(c *gin.Context) {
+	c.Set(legacyKey, true)
+	c.Next()
+}
+
+// LegacyUser is a user with the field names of the unversioned routes. The
+// password hash is not rendered any more.
+type LegacyUser struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+	Name      string
+	Email     string
+	Address   string
+}
+
+func newLegacyUser(user *model.User) LegacyUser {
+	legacy := LegacyUser{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Name:      user.Name,
+		Email:     user.Email,
+		Address:   user.Address,
+	}
+	if user.DeletedAt.Valid {
+		legacy.DeletedAt = &user.DeletedAt.Time
+	}
+	return legacy
+}
+
+// userJSON answers with user, in the legacy shape on the unversioned routes.
+func userJSON(c *gin.Context, status int, user *model.User) {
+	if c.GetBool(legacyKey) {
+		c.JSON(status, newLegacyUser(user))
+		return
+	}
+	c.JSON(status, user)
+}
+
+// usersJSON answers with a list of users like userJSON.
+func usersJSON(c *gin.Context, status int, users []model.User) {
+	if !c.GetBool(legacyKey) {
+		c.JSON(status, users)
+		return
+	}
+	legacy := make([]LegacyUser, len(users))
+	for i := range users {
+		legacy[i] = newLegacyUser(&users[i])
+	}
+	c.JSON(status, legacy)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"crudspanner/model"
+	"crudspanner/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetAuditService enables GET /users/:id/audit.
+func (ctrl *UserController) SetAuditService(auditService services.AuditService) {
+	ctrl.auditService = auditService
+}
+
+// GetUserAudit returns the audit entries of a user, newest first, filtered
+// by the actor, action, field, since, until and limit query parameters.
+func (ctrl *UserController) GetUserAudit(c *gin.Context) {
+	if ctrl.auditService == nil {
+		errorJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Audit log is not available"})
+		return
+	}
+
+	filter := model.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Field:  c.Query("field"),
+	}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value, ok := c.GetQuery(name); ok {
+			var err error
+			if *target, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				errorJSON(c, http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp"})
+				return
+			}
+		}
+	}
+	if value, ok := c.GetQuery("limit"); ok {
+		var err error
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit < 1 || filter.Limit > services.MaxAuditLimit {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxAuditLimit)})
+			return
+		}
+	}
+
+	entries, err := ctrl.auditService.GetUserAudit(c.Request.Context(), c.Param("id"), filter)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAuditFilter) {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if timedOut(c, err) {
+			return
+		}
+		internalError(c, ctrl.logger, err, "Could not retrieve audit log")
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
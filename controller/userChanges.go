@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"crudspanner/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultChangeWait = 20 * time.Second
+	maxChangeWait     = time.Minute
+)
+
+// SetChangeFeed enables GET /users/changes.
+func (ctrl *UserController) SetChangeFeed(changeFeed *services.ChangeFeed) {
+	ctrl.changeFeed = changeFeed
+}
+
+// GetUserChanges long-polls for changes committed after the since
+// timestamp, which defaults to now. It answers as soon as there are changes
+// or after wait.
+func (ctrl *UserController) GetUserChanges(c *gin.Context) {
+	if ctrl.changeFeed == nil {
+		errorJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Change feed is not available"})
+		return
+	}
+
+	since := time.Now()
+	if value, ok := c.GetQuery("since"); ok {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+
+	wait := defaultChangeWait
+	if value, ok := c.GetQuery("wait"); ok {
+		var err error
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 || wait > maxChangeWait {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": "wait must be a duration between 0s and 1m"})
+			return
+		}
+	}
+	// Answer before the request deadline rather than timing out
+	if deadline, ok := c.Request.Context().Deadline(); ok {
+		wait = max(min(wait, time.Until(deadline)-time.Second), 0)
+	}
+
+	batch, err := ctrl.changeFeed.Wait(c.Request.Context(), since, wait)
+	if err != nil {
+		if errors.Is(err, services.ErrChangesExpired) {
+			errorJSON(c, http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		if timedOut(c, err) {
+			return
+		}
+		internalError(c, ctrl.logger, err, "Could not read changes")
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
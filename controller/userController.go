@@ -1,144 +1,393 @@
 package controller
 
 import (
+	"context"
 	"crudspanner/model"
 	"crudspanner/services"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// ReadTimestampHeader carries the timestamp a stale read was served at.
+	ReadTimestampHeader = "X-Read-Timestamp"
+	// NextPageTokenHeader carries the token of the next page of a list. It
+	// is missing on the last page.
+	NextPageTokenHeader = "X-Next-Page-Token"
 )
 
 type UserController struct {
-	userService services.UserService
+	userService       services.UserService
+	readStaleness     model.Staleness
+	listReadStaleness model.Staleness
+	changeFeed        *services.ChangeFeed
+	auditService      services.AuditService
+	logger            *zap.Logger
+}
+
+func NewUserController(userService services.UserService, logger *zap.Logger) *UserController {
+	return &UserController{userService: userService, logger: logger}
+}
+
+// SetReadStaleness sets the staleness of reads that do not ask for one with
+// the staleness query parameter.
+func (ctrl *UserController) SetReadStaleness(get, list model.Staleness) {
+	ctrl.readStaleness = get
+	ctrl.listReadStaleness = list
+}
+
+// staleness reads the staleness query parameter, answering 400 if it is
+// invalid.
+func staleness(c *gin.Context, fallback model.Staleness) (model.Staleness, bool) {
+	value, ok := c.GetQuery("staleness")
+	if !ok {
+		return fallback, true
+	}
+	staleness, err := model.ParseStaleness(value)
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return model.Staleness{}, false
+	}
+	return staleness, true
+}
+
+// page reads the page_size and page_token query parameters, answering 400
+// if they are invalid. Without page_size the whole list is returned.
+func page(c *gin.Context) (model.Page, bool) {
+	var page model.Page
+	if size := c.Query("page_size"); size != "" {
+		var err error
+		page.Size, err = strconv.Atoi(size)
+		if err != nil || page.Size < 1 || page.Size > model.MaxPageSize {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": "page_size must be between 1 and " + strconv.Itoa(model.MaxPageSize)})
+			return model.Page{}, false
+		}
+	}
+	if token := c.Query("page_token"); token != "" {
+		var err error
+		page.After, err = model.ParsePageToken(token)
+		if err != nil {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return model.Page{}, false
+		}
+	}
+	return page, true
+}
+
+func setReadTimestamp(c *gin.Context, readTimestamp time.Time) {
+	if !readTimestamp.IsZero() {
+		c.Header(ReadTimestampHeader, readTimestamp.Format(time.RFC3339Nano))
+	}
+}
+
+// timedOut answers 504 when err was caused by the request deadline expiring.
+func timedOut(c *gin.Context, err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		errorJSON(c, http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		return true
+	}
+	return false
+}
+
+// internalError logs the cause of a failed request, which the answer does
+// not reveal, and answers 500 with message.
+func internalError(c *gin.Context, logger *zap.Logger, err error, message string) {
+	model.RequestLogger(c.Request.Context(), logger).Error(message, zap.String("route", c.FullPath()), zap.Error(err))
+	errorJSON(c, http.StatusInternalServerError, gin.H{"error": message})
 }
 
-func NewUserController(userService services.UserService) *UserController {
-	return &UserController{userService: userService}
+// serviceError answers for a failed call of the user service: 504 if the
+// request timed out, 404 for missing users and 500 with message for
+// anything else, whose cause is only logged.
+func (ctrl *UserController) serviceError(c *gin.Context, err error, message string) {
+	switch {
+	case timedOut(c, err):
+	case errors.Is(err, services.ErrUserNotFound):
+		errorJSON(c, http.StatusNotFound, gin.H{"error": "User not found", "message": message})
+	default:
+		internalError(c, ctrl.logger, err, message)
+	}
 }
 
-// RegistrationUser godoc
-// @Summary Register a new user
-// @Description Create a new user in the system
-// @Tags users
-// @Param user body model.User true "User Data"
-// @Success 201 {object} model.User
-// @Failure 400 {object} map[string]string "Invalid input"
-// @Router / [post]
+// RegistrationUser registers a new user from the JSON body.
 func (ctrl *UserController) RegistrationUser(c *gin.Context) {
-	var user model.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid input"})
+	var request RegisterUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid input"})
 		return
 	}
 
-	createdUser, err := ctrl.userService.Registration(&user)
+	createdUser, err := ctrl.userService.Registration(c.Request.Context(), request.user())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Failed to create user"})
+		if errors.Is(err, services.ErrMissingFields) || errors.Is(err, services.ErrEmailTaken) {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Failed to create user"})
+			return
+		}
+		ctrl.serviceError(c, err, "Failed to create user")
 		return
 	}
-	c.JSON(http.StatusCreated, createdUser)
+	userJSON(c, http.StatusCreated, createdUser)
 }
 
-// GetUserByID godoc
-// @Summary Get user by ID
-// @Description Retrieve a user by their unique ID
-// @Tags users
-// @Param id path int true "User ID"
-// @Success 200 {object} model.User
-// @Failure 404 {object} map[string]string "User not found"
-// @Router /{id} [get]
+// GetUserByID returns a single user.
 func (ctrl *UserController) GetUserByID(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Id is missing"})
+	id := c.Param("id")
+	if id == "" {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Id is missing"})
+		return
+	}
+	readStaleness, ok := staleness(c, ctrl.readStaleness)
+	if !ok {
 		return
 	}
-	user, err := ctrl.userService.GetUserById(uint(id))
+	user, readTimestamp, err := ctrl.userService.GetUserById(c.Request.Context(), id, readStaleness)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		ctrl.serviceError(c, err, "Could not retrieve user")
 		return
 	}
-	c.JSON(http.StatusOK, user)
+	setReadTimestamp(c, readTimestamp)
+	userJSON(c, http.StatusOK, user)
 
 }
 
-// GetAllUsers godoc
-// @Summary List all users
-// @Description Retrieve all users in the system
-// @Tags users
-// @Success 200 {array} model.User
-// @Router /users [get]
+// GetAllUsers lists the users ordered by ID, a page at a time when
+// page_size is given.
 func (ctrl *UserController) GetAllUsers(c *gin.Context) {
 
-	users, err := ctrl.userService.GetAllUsers()
+	readStaleness, ok := staleness(c, ctrl.listReadStaleness)
+	if !ok {
+		return
+	}
+	listPage, ok := page(c)
+	if !ok {
+		return
+	}
+	users, readTimestamp, err := ctrl.userService.GetAllUsers(c.Request.Context(), readStaleness, listPage)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve users"})
+		if timedOut(c, err) {
+			return
+		}
+		internalError(c, ctrl.logger, err, "Could not retrieve users")
 		return
 
 	}
-	c.JSON(http.StatusOK, users)
+	setReadTimestamp(c, readTimestamp)
+	if users.NextPageToken != "" {
+		c.Header(NextPageTokenHeader, users.NextPageToken)
+	}
+	usersJSON(c, http.StatusOK, users.Users)
 
 }
 
-// UpdateUser godoc
-// @Summary Update user information
-// @Description Update the details of an existing user
-// @Tags users
-// @Param id path int true "User ID"
-// @Param user body model.User true "Updated User Data"
-// @Success 200 {object} model.User
-// @Failure 404 {object} map[string]string "User not found"
-// @Router /{id} [put]
+// UpdateUser applies the non-empty fields of the JSON body to a user.
 func (ctrl *UserController) UpdateUser(c *gin.Context) {
 
-	var user model.User
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	var request UpdateUserRequest
+	id := c.Param("id")
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Id is missing"})
+	if id == "" {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Id is missing"})
 		return
 	}
 
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
-	updatedUser, err := ctrl.userService.UpdateUser(uint(id), &user)
+	updatedUser, err := ctrl.userService.UpdateUser(c.Request.Context(), id, request.user())
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update user"})
+		if errors.Is(err, services.ErrEmailTaken) {
+			errorJSON(c, http.StatusConflict, gin.H{"error": err.Error(), "message": "Could not update user"})
+			return
+		}
+		ctrl.serviceError(c, err, "Could not update user")
 		return
 	}
 
-	c.JSON(http.StatusOK, updatedUser)
+	userJSON(c, http.StatusOK, updatedUser)
 
 }
 
-// DeleteUser godoc
-// @Summary Delete a user
-// @Description Remove a user from the system by ID
-// @Tags users
-// @Param id path int true "User ID"
-// @Success 200 {object} map[string]string "User deleted successfully"
-// @Failure 404 {object} map[string]string "User not found"
-// @Router /{id} [delete]
-func (ctrl *UserController) DeleteUser(c *gin.Context) {
+// PatchUser sets the fields present in the JSON merge patch body. Unlike
+// UpdateUser it can clear the address.
+func (ctrl *UserController) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Id is missing"})
+		return
+	}
 
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	var request PatchUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	patchedUser, err := ctrl.userService.PatchUser(c.Request.Context(), id, request.patch())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Id is missing"})
+		switch {
+		case errors.Is(err, services.ErrInvalidPatch):
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailTaken):
+			errorJSON(c, http.StatusConflict, gin.H{"error": err.Error(), "message": "Could not update user"})
+		default:
+			ctrl.serviceError(c, err, "Could not update user")
+		}
 		return
 	}
 
-	if err := ctrl.userService.DeleteUser(uint(id)); err != nil {
+	userJSON(c, http.StatusOK, patchedUser)
+}
+
+// DeleteUser soft-deletes a user, or removes it permanently with hard=true.
+// Permanent removal also applies to users that are already soft-deleted.
+func (ctrl *UserController) DeleteUser(c *gin.Context) {
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete user"})
+	id := c.Param("id")
+	if id == "" {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Id is missing"})
+		return
+	}
+
+	hard, err := strconv.ParseBool(c.DefaultQuery("hard", "false"))
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "hard must be a boolean"})
+		return
+	}
 
+	if hard {
+		if err := ctrl.userService.PermanentlyDeleteUser(c.Request.Context(), id); err != nil {
+			ctrl.serviceError(c, err, "Could not delete user")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "User permanently deleted"})
 		return
+	}
 
+	if err := ctrl.userService.DeleteUser(c.Request.Context(), id); err != nil {
+		ctrl.serviceError(c, err, "Could not delete user")
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
 
 }
+
+// GetDeletedUsers lists users that have been soft-deleted and not purged yet.
+func (ctrl *UserController) GetDeletedUsers(c *gin.Context) {
+
+	users, err := ctrl.userService.GetDeletedUsers(c.Request.Context())
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		internalError(c, ctrl.logger, err, "Could not retrieve deleted users")
+		return
+	}
+	usersJSON(c, http.StatusOK, users)
+}
+
+// UserAction runs custom methods addressed as /users/{id}:{action}. The only
+// action is restore, which undoes a soft delete.
+func (ctrl *UserController) UserAction(c *gin.Context) {
+	id, action, found := strings.Cut(c.Param("id"), ":")
+	if !found {
+		errorJSON(c, http.StatusNotFound, gin.H{"error": "Unknown action"})
+		return
+	}
+
+	if id == "" {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Id is missing"})
+		return
+	}
+
+	switch action {
+	case "restore":
+		ctrl.restoreUser(c, id)
+	default:
+		errorJSON(c, http.StatusNotFound, gin.H{"error": "Unknown action"})
+	}
+}
+
+func (ctrl *UserController) restoreUser(c *gin.Context, id string) {
+	user, err := ctrl.userService.RestoreUser(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrEmailTaken) {
+			errorJSON(c, http.StatusConflict, gin.H{"error": err.Error(), "message": "Could not restore user"})
+			return
+		}
+		ctrl.serviceError(c, err, "Could not restore user")
+		return
+	}
+	userJSON(c, http.StatusOK, user)
+}
+
+// ImportUsers creates users from an uploaded CSV file. With dry_run=true every
+// line is validated but nothing is written; with report=csv the rejected lines
+// are returned as a downloadable CSV instead of JSON.
+func (ctrl *UserController) ImportUsers(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "CSV file is missing"})
+		return
+	}
+
+	var options services.ImportOptions
+	if mapping := c.PostForm("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &options.Mapping); err != nil {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid column mapping"})
+			return
+		}
+	}
+	if dryRun := c.Query("dry_run"); dryRun != "" {
+		options.DryRun, err = strconv.ParseBool(dryRun)
+		if err != nil {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": "dry_run must be a boolean"})
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Could not read CSV file"})
+		return
+	}
+	defer file.Close()
+
+	result, err := ctrl.userService.ImportUsers(c.Request.Context(), file, options)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImport) {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Failed to import users"})
+			return
+		}
+		ctrl.serviceError(c, err, "Failed to import users")
+		return
+	}
+
+	if c.Query("report") == "csv" {
+		writeImportReport(c, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func writeImportReport(c *gin.Context, result *services.ImportResult) {
+	c.Header("Content-Disposition", `attachment; filename="import-errors.csv"`)
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"line", "email", "reason"})
+	for _, rejected := range result.Rejected {
+		writer.Write([]string{strconv.Itoa(rejected.Line), rejected.Email, rejected.Reason})
+	}
+	writer.Flush()
+}
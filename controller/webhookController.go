@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"crudspanner/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type WebhookController struct {
+	webhookService services.WebhookService
+	logger         *zap.Logger
+}
+
+func NewWebhookController(webhookService services.WebhookService, logger *zap.Logger) *WebhookController {
+	return &WebhookController{webhookService: webhookService, logger: logger}
+}
+
+// webhookError answers with the status that matches err.
+func (ctrl *WebhookController) webhookError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		errorJSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSubscription):
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": message})
+	case timedOut(c, err):
+	default:
+		internalError(c, ctrl.logger, err, message)
+	}
+}
+
+// CreateWebhook subscribes a URL to user events. The answer is the only one
+// that contains the signing secret.
+func (ctrl *WebhookController) CreateWebhook(c *gin.Context) {
+	var input services.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "message": "Invalid input"})
+		return
+	}
+	subscription, err := ctrl.webhookService.CreateSubscription(c.Request.Context(), input)
+	if err != nil {
+		ctrl.webhookError(c, err, "Could not create webhook")
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// GetWebhooks lists all webhook subscriptions.
+func (ctrl *WebhookController) GetWebhooks(c *gin.Context) {
+	subscriptions, err := ctrl.webhookService.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		ctrl.webhookError(c, err, "Could not retrieve webhooks")
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GetWebhook returns a single webhook subscription.
+func (ctrl *WebhookController) GetWebhook(c *gin.Context) {
+	subscription, err := ctrl.webhookService.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		ctrl.webhookError(c, err, "Could not retrieve webhook")
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// UpdateWebhook changes the URL, event types or secret of a subscription,
+// or enables or disables it.
+func (ctrl *WebhookController) UpdateWebhook(c *gin.Context) {
+	var input services.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errorJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	subscription, err := ctrl.webhookService.UpdateSubscription(c.Request.Context(), c.Param("id"), input)
+	if err != nil {
+		ctrl.webhookError(c, err, "Could not update webhook")
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteWebhook removes a subscription and its delivery log.
+func (ctrl *WebhookController) DeleteWebhook(c *gin.Context) {
+	if err := ctrl.webhookService.DeleteSubscription(c.Request.Context(), c.Param("id")); err != nil {
+		ctrl.webhookError(c, err, "Could not delete webhook")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// GetWebhookDeliveries returns the most recent deliveries of a subscription.
+func (ctrl *WebhookController) GetWebhookDeliveries(c *gin.Context) {
+	limit := 0
+	if value, ok := c.GetQuery("limit"); ok {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > services.MaxDeliveryLimit {
+			errorJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxDeliveryLimit)})
+			return
+		}
+	}
+	deliveries, err := ctrl.webhookService.ListDeliveries(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		ctrl.webhookError(c, err, "Could not retrieve deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
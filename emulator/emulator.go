@@ -0,0 +1,106 @@
+// Package emulator prepares databases on the Spanner emulator. The emulator
+// starts without instances or databases and needs no credentials; the
+// Spanner clients connect to it on their own when SPANNER_EMULATOR_HOST is
+// set.
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var databaseName = regexp.MustCompile(`^projects/([^/]+)/instances/([^/]+)/databases/([^/]+)$`)
+
+// Host returns the address of the emulator (SPANNER_EMULATOR_HOST), or an
+// empty string when the clients talk to Cloud Spanner.
+func Host() string {
+	return os.Getenv("SPANNER_EMULATOR_HOST")
+}
+
+// EnsureDatabase creates the instance and the database of name, a full
+// database name, unless they exist.
+func EnsureDatabase(ctx context.Context, name string) error {
+	if Host() == "" {
+		return fmt.Errorf("SPANNER_EMULATOR_HOST is not set, refusing to create %s", name)
+	}
+	parts := databaseName.FindStringSubmatch(name)
+	if parts == nil {
+		return fmt.Errorf("%q is not a database name of the form projects/p/instances/i/databases/d", name)
+	}
+	project, instanceID, databaseID := parts[1], parts[2], parts[3]
+	if err := createInstance(ctx, project, instanceID); err != nil {
+		return fmt.Errorf("creating emulator instance %s: %w", instanceID, err)
+	}
+	if err := createDatabase(ctx, "projects/"+project+"/instances/"+instanceID, databaseID); err != nil {
+		return fmt.Errorf("creating emulator database %s: %w", databaseID, err)
+	}
+	return nil
+}
+
+// DropDatabase removes the database name from the emulator.
+func DropDatabase(ctx context.Context, name string) error {
+	client, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.DropDatabase(ctx, &databasepb.DropDatabaseRequest{Database: name})
+}
+
+func createInstance(ctx context.Context, project, instanceID string) error {
+	client, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	op, err := client.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + project,
+		InstanceId: instanceID,
+		Instance: &instancepb.Instance{
+			Config:      "projects/" + project + "/instanceConfigs/emulator-config",
+			DisplayName: instanceID,
+			NodeCount:   1,
+		},
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
+
+// createDatabase creates an empty database; its schema is left to the
+// migrations.
+func createDatabase(ctx context.Context, instanceName, databaseID string) error {
+	client, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	op, err := client.CreateDatabase(ctx, &databasepb.CreateDatabaseRequest{
+		Parent:          instanceName,
+		CreateStatement: "CREATE DATABASE `" + databaseID + "`",
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
@@ -0,0 +1,22 @@
+package emulator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureDatabaseRequiresEmulator(t *testing.T) {
+	t.Setenv("SPANNER_EMULATOR_HOST", "")
+
+	err := EnsureDatabase(context.Background(), "projects/p/instances/i/databases/d")
+	assert.EqualError(t, err, "SPANNER_EMULATOR_HOST is not set, refusing to create projects/p/instances/i/databases/d")
+}
+
+func TestEnsureDatabaseRejectsInvalidNames(t *testing.T) {
+	t.Setenv("SPANNER_EMULATOR_HOST", "localhost:9010")
+
+	err := EnsureDatabase(context.Background(), "projects/p/instances/i")
+	assert.EqualError(t, err, `"projects/p/instances/i" is not a database name of the form projects/p/instances/i/databases/d`)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"crudspanner/model"
+)
+
+type AuditRepository interface {
+	// List returns the audit entries of a user that match filter, newest
+	// first.
+	List(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error)
+}
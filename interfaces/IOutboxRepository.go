@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+	"crudspanner/model"
+	"time"
+)
+
+type OutboxRepository interface {
+	// Claim returns up to limit unpublished events that are due at now and
+	// postpones them by lease, so that other relays skip them while they are
+	// being delivered.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id string, publishedAt time.Time) error
+	MarkFailed(ctx context.Context, id string, attempts int64, lastError string, nextAttemptAt time.Time) error
+	// DeletePublishedBefore deletes up to limit events that were published
+	// before before and returns how many were deleted.
+	DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+	"crudspanner/model"
+)
+
+// Publisher delivers outbox events to a message bus. Events may be published
+// more than once, so consumers should deduplicate them by ID.
+type Publisher interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
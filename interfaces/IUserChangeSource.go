@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+	"crudspanner/model"
+	"time"
+)
+
+type UserChangeSource interface {
+	// Changes returns the changes committed after since and at or before
+	// until, oldest first.
+	Changes(ctx context.Context, since, until time.Time) ([]model.UserChange, error)
+}
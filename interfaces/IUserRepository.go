@@ -1,12 +1,38 @@
 package interfaces
 
-import "crudspanner/model"
+import (
+	"context"
+	"crudspanner/model"
+	"time"
+)
 
 type UserRepository interface {
-	Create(user *model.User) (*model.User, error)
-	Get(id uint) (*model.User, error)
-	GetAll() ([]model.User, error)
-	Delete(id uint) error
-	Update(id uint, user *model.User) (*model.User, error)
-	FindByEmail(email string) (*model.User, error)
+	Create(ctx context.Context, user *model.User) (*model.User, error)
+	Get(ctx context.Context, id string) (*model.User, error)
+	GetAll(ctx context.Context) ([]model.User, error)
+	// List returns the page of users that are not deleted, ordered by ID.
+	List(ctx context.Context, page model.Page) ([]model.User, error)
+	Delete(ctx context.Context, id string) error
+	Update(ctx context.Context, id string, user *model.User) (*model.User, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	GetDeleted(ctx context.Context) ([]model.User, error)
+	FindDeleted(ctx context.Context, id string) (*model.User, error)
+	Restore(ctx context.Context, id string) (*model.User, error)
+	HardDelete(ctx context.Context, id string) error
+	// ListDeletedBefore returns up to limit users that were deleted before
+	// the given time, the longest deleted first.
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]model.User, error)
+	// AddEvent stores an event in the outbox. Called inside Transaction it is
+	// committed together with the change it describes.
+	AddEvent(ctx context.Context, event *model.OutboxEvent) error
+	// AddAuditEntry stores an audit entry; like AddEvent it belongs to the
+	// transaction it is called in.
+	AddAuditEntry(ctx context.Context, entry *model.AuditEntry) error
+	// Transaction runs fn in a single read-write transaction. fn must only
+	// use the repository it is given and may run more than once if the
+	// transaction is aborted.
+	Transaction(ctx context.Context, fn func(repo UserRepository) error) error
+	// ReadOnly runs fn in a read-only transaction at the given staleness and
+	// returns the read timestamp, or the zero time if it is not known.
+	ReadOnly(ctx context.Context, staleness model.Staleness, fn func(repo UserRepository) error) (time.Time, error)
 }
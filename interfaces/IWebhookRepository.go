@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"crudspanner/model"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	SaveSubscription(ctx context.Context, subscription *model.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// AddDeliveries stores new deliveries and skips those that already
+	// exist, so that an event published twice is delivered once.
+	AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries that are due at
+	// now and postpones them by lease, like OutboxRepository.Claim.
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	// RecordAttempt saves the outcome of a delivery attempt and updates the
+	// failure count of its subscription, which is disabled once it reaches
+	// disableAfter failures in a row. Failures are not counted while the
+	// subscription is disabled. It reports whether the subscription was
+	// disabled by this attempt.
+	RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, disableAfter int64) (bool, error)
+	// ListDeliveries returns the most recent deliveries of a subscription.
+	ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error)
+}
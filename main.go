@@ -1,65 +1,149 @@
 package main
 
 import (
+	"context"
+	"crudspanner/middleware"
 	"crudspanner/routes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"crudspanner/config"
 
-	"crudspanner/docs"
-
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	swaggerFiles "github.com/swaggo/files"
-	ginswagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
+	"google.golang.org/api/idtoken"
 )
 
-var logger *zap.Logger
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger, err := config.NewLogger(cfg.Log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to initialize logger:", err)
+		os.Exit(1)
+	}
 
-func init() {
-	var err error
-	logger, err = zap.NewDevelopment()
+	err = run(cfg, logger)
 	if err != nil {
-		panic("Failed to initialize logger" + err.Error())
+		logger.Error("Server failed", zap.Error(err))
+	}
+	logger.Sync()
+	if err != nil {
+		os.Exit(1)
 	}
-	defer logger.Sync()
 }
 
-// @title           Swagger Example API
-// @version         1.0
-// @description     This is a sample server celler server.
-// @termsOfService  http://swagger.io/terms/
+// run serves until SIGTERM or SIGINT, then lets running requests finish,
+// stops the background workers and closes the database connections.
+func run(cfg *config.Config, logger *zap.Logger) error {
+	repos, err := config.ConnectRepositories(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := repos.Close(); err != nil {
+			logger.Error("Failed to close the database", zap.Error(err))
+		}
+	}()
+	if cfg.Profile == config.ProfileDev {
+		seeded, err := config.SeedDevData(context.Background(), repos.Users)
+		if err != nil {
+			logger.Error("failed to seed dev data", zap.Error(err))
+		} else if seeded > 0 {
+			logger.Info("seeded dev data", zap.Int("users", seeded))
+		}
+	}
 
-// @contact.name   API Support
-// @contact.url    http://www.swagger.io/support
-// @contact.email  support@swagger.io
+	if cfg.Log.Format == config.LogFormatJSON {
+		// Keep gin's debug output out of the machine-read log
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return err
+	}
+	verifyActor, err := actorVerifier(cfg, logger)
+	if err != nil {
+		return err
+	}
+	router.Use(
+		middleware.RequestInfo(verifyActor, logger, "/healthz", "/readyz"),
+		middleware.AccessLog(logger, "/healthz", "/readyz"),
+		middleware.Recovery(logger),
+	)
+	health, err := routes.HealthRoutes(router, logger, cfg, repos)
+	if err != nil {
+		return err
+	}
+	stopWorkers, err := routes.UserRoutes(router, logger, cfg, repos)
+	if err != nil {
+		return err
+	}
+	defer stopWorkers()
 
-// @license.name  Apache 2.0
-// @license.url   http://www.apache.org/licenses/LICENSE-2.0.html
+	server := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           router,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+	}
 
-// @host      localhost:8080
-// @BasePath  /api/v1
+	ctx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
 
-// @securityDefinitions.basic  BasicAuth
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	logger.Info("Listening", zap.String("addr", cfg.HTTP.Addr))
 
-// @externalDocs.description  OpenAPI
-// @externalDocs.url          https://swagger.io/resources/open-api/
-func main() {
-	docs.SwaggerInfo.Title = "Swagger Example API"
-	docs.SwaggerInfo.Description = "This is a sample server Petstore server."
-	docs.SwaggerInfo.Version = "1.0"
-	docs.SwaggerInfo.Host = "localhost:8080"
-	docs.SwaggerInfo.BasePath = "/"
-	docs.SwaggerInfo.Schemes = []string{"http"}
-	if err := godotenv.Load(); err != nil {
-		panic("Failed to load env file")
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
 	}
+	// A second signal kills the server right away.
+	stopSignals()
 
-	httpServer := gin.Default()
-	dbConnector := config.ConnectDB()
-	routes.UserRoutes(httpServer, logger, dbConnector)
-
-	httpServer.GET("/swagger/*any", ginswagger.WrapHandler(swaggerFiles.Handler))
-	httpServer.Run(":8080")
+	health.SetShuttingDown()
+	if cfg.HTTP.ShutdownDelay > 0 {
+		logger.Info("Reporting not ready before shutting down", zap.Duration("delay", cfg.HTTP.ShutdownDelay))
+		time.Sleep(cfg.HTTP.ShutdownDelay)
+	}
+	logger.Info("Shutting down", zap.Duration("timeout", cfg.HTTP.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("draining requests: %w", err)
+	}
+	return nil
+}
 
+// actorVerifier verifies the Identity-Aware Proxy assertions of requests when
+// IAP_AUDIENCE is set.
+func actorVerifier(cfg *config.Config, logger *zap.Logger) (middleware.ActorVerifier, error) {
+	if cfg.IAPAudience == "" {
+		logger.Warn("IAP_AUDIENCE is not set, so every user is recorded as anonymous")
+		return nil, nil
+	}
+	validator, err := idtoken.NewValidator(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the IAP token validator: %w", err)
+	}
+	return middleware.IAPVerifier(validator, cfg.IAPAudience), nil
 }
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"crudspanner/model"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLog logs every request once it is handled. The route is logged as
+// its template, so that IDs in the path do not split it into many; the
+// query string is not logged as it may hold secrets. Server errors are
+// logged as errors and client errors as warnings. Successful requests to
+// quietPaths, such as the health probes, are only logged at debug level.
+func AccessLog(logger *zap.Logger, quietPaths ...string) gin.HandlerFunc {
+	logger = logger.WithOptions(zap.WithCaller(false))
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := zapcore.InfoLevel
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = zapcore.ErrorLevel
+		case status >= http.StatusBadRequest:
+			level = zapcore.WarnLevel
+		case slices.Contains(quietPaths, c.Request.URL.Path):
+			level = zapcore.DebugLevel
+		}
+		if !logger.Core().Enabled(level) {
+			return
+		}
+
+		info := model.RequestInfoFrom(c.Request.Context())
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", max(c.Writer.Size(), 0)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user", info.Actor),
+			zap.String("request_id", info.RequestID),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+		logger.Log(level, "Request", fields...)
+	}
+}
+
+// Recovery answers 500 when a handler panics and logs the panic with its
+// stack trace.
+func Recovery(logger *zap.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		model.RequestLogger(c.Request.Context(), logger).Error("Request panicked",
+			zap.String("route", c.FullPath()), zap.Any("panic", recovered), zap.Stack("stack"))
+		abortWithError(c, http.StatusInternalServerError, "Internal server error")
+	})
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func accessLogRouter(level zapcore.Level) (*gin.Engine, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(level)
+	logger := zap.New(core)
+	router := gin.New()
+	router.Use(AccessLog(logger, "/healthz"), Recovery(logger), RequestInfo(fakeVerifier, logger, "/healthz"))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "user "+c.Param("id"))
+	})
+	router.GET("/healthz", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return router, logs
+}
+
+func TestAccessLog(t *testing.T) {
+	router, logs := accessLogRouter(zapcore.InfoLevel)
+
+	request := httptest.NewRequest(http.MethodGet, "/users/42?token=secret", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	request.Header.Set(IAPAssertionHeader, validAssertion)
+	request.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.InfoLevel, entry.Level)
+	fields := entry.ContextMap()
+	assert.Contains(t, fields, "latency")
+	delete(fields, "latency")
+	assert.Equal(t, map[string]any{
+		"method":     "GET",
+		"route":      "/users/:id",
+		"status":     int64(200),
+		"bytes":      int64(7),
+		"client_ip":  "192.0.2.1",
+		"user":       "admin@example.com",
+		"request_id": "req-1",
+	}, fields)
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	router, logs := accessLogRouter(zapcore.InfoLevel)
+
+	for _, path := range []string{"/healthz", "/missing", "/panic"} {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set(IAPAssertionHeader, validAssertion)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	requests := logs.FilterMessage("Request").All()
+	require.Len(t, requests, 2, "successful probes are only logged at debug level")
+	assert.Equal(t, zapcore.WarnLevel, requests[0].Level)
+	assert.Equal(t, "unmatched", requests[0].ContextMap()["route"])
+	assert.Equal(t, zapcore.ErrorLevel, requests[1].Level)
+	assert.Equal(t, int64(http.StatusInternalServerError), requests[1].ContextMap()["status"])
+	assert.Equal(t, 1, logs.FilterMessage("Request panicked").Len())
+}
+
+func TestRecoveryAnswersWithRequestID(t *testing.T) {
+	router, _ := accessLogRouter(zapcore.InfoLevel)
+
+	request := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	request.Header.Set(RequestIDHeader, "req-2")
+	request.Header.Set(IAPAssertionHeader, validAssertion)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, ProblemContentType, recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Internal server error","error":"Internal server error","requestId":"req-2"}`, recorder.Body.String())
+}
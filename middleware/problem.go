@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crudspanner/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of error bodies, which are RFC 9457
+// problem details.
+const ProblemContentType = "application/problem+json"
+
+// WriteProblem answers with body as problem details. The type, title and
+// status members are filled in, the error member is repeated as detail and
+// the ID of the request is added, so that a failed call can be looked up in
+// the logs. body must hold at least an error.
+func WriteProblem(c *gin.Context, status int, body gin.H) {
+	body["type"] = "about:blank"
+	body["title"] = http.StatusText(status)
+	body["status"] = status
+	if detail, ok := body["error"]; ok {
+		body["detail"] = detail
+	}
+	if id := model.RequestInfoFrom(c.Request.Context()).RequestID; id != "" {
+		body["requestId"] = id
+	}
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(status, body)
+}
+
+// abortWithError ends the request with an error body that carries its ID.
+func abortWithError(c *gin.Context, status int, message string) {
+	c.Abort()
+	WriteProblem(c, status, gin.H{"error": message})
+}
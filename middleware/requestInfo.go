@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"crudspanner/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/api/idtoken"
+)
+
+const (
+	// RequestIDHeader carries the ID of a request. A valid ID sent by the
+	// client or a proxy is kept; otherwise one is generated. It is echoed in
+	// the response.
+	RequestIDHeader = "X-Request-ID"
+	// IAPAssertionHeader carries the JWT that Identity-Aware Proxy signs for
+	// every request it lets through.
+	IAPAssertionHeader = "X-Goog-IAP-JWT-Assertion"
+
+	iapIssuer = "https://cloud.google.com/iap"
+)
+
+// ActorVerifier verifies the identity assertion of a request and returns the
+// user it was made by.
+type ActorVerifier func(ctx context.Context, assertion string) (string, error)
+
+// IAPVerifier verifies the JWTs of Identity-Aware Proxy for audience, which
+// is /projects/NUMBER/global/backendServices/ID behind a load balancer or
+// /projects/NUMBER/apps/PROJECT on App Engine. The actor is the email claim.
+func IAPVerifier(validator *idtoken.Validator, audience string) ActorVerifier {
+	return func(ctx context.Context, assertion string) (string, error) {
+		payload, err := validator.Validate(ctx, assertion, audience)
+		if err != nil {
+			return "", err
+		}
+		if payload.Issuer != iapIssuer {
+			return "", fmt.Errorf("unexpected issuer %q", payload.Issuer)
+		}
+		email, _ := payload.Claims["email"].(string)
+		if email == "" {
+			return "", errors.New("the assertion has no email claim")
+		}
+		return email, nil
+	}
+}
+
+// RequestInfo stores who made the request, from where, and its ID in the
+// request context, for the audit log, the log and error responses. When
+// verify is set, every request must carry an IAPAssertionHeader that it
+// accepts, and the actor is taken from it; other requests are answered with
+// 401, except those for publicPaths such as health checks, which do not pass
+// through the proxy and have an UnknownActor. When verify is nil all requests
+// have an UnknownActor. The client IP is only taken from forwarding headers
+// set by trusted proxies. It must come before all other middleware.
+func RequestInfo(verify ActorVerifier, logger *zap.Logger, publicPaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		info := model.RequestInfo{ClientIP: c.ClientIP(), RequestID: requestID}
+		ctx := model.WithRequestInfo(c.Request.Context(), info)
+		c.Request = c.Request.WithContext(ctx)
+		if verify != nil && !slices.Contains(publicPaths, c.Request.URL.Path) {
+			assertion := c.GetHeader(IAPAssertionHeader)
+			if assertion == "" {
+				model.RequestLogger(ctx, logger).Warn("Missing identity assertion")
+				abortWithError(c, http.StatusUnauthorized, "Missing identity assertion")
+				return
+			}
+			actor, err := verify(ctx, assertion)
+			if err != nil {
+				model.RequestLogger(ctx, logger).Warn("Rejected identity assertion", zap.Error(err))
+				abortWithError(c, http.StatusUnauthorized, "Invalid identity assertion")
+				return
+			}
+			info.Actor = actor
+			c.Request = c.Request.WithContext(model.WithRequestInfo(ctx, info))
+		}
+		c.Next()
+	}
+}
+
+// validRequestID accepts up to 128 printable ASCII characters, so that the
+// ID can be stored and logged safely.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crudspanner/model"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/api/idtoken"
+)
+
+const validAssertion = "signed-by-iap"
+
+// fakeVerifier accepts validAssertion only.
+func fakeVerifier(ctx context.Context, assertion string) (string, error) {
+	if assertion != validAssertion {
+		return "", errors.New("invalid signature")
+	}
+	return "admin@example.com", nil
+}
+
+// serveWithRequestInfo serves a request with headers. Requests that have no
+// IAPAssertionHeader entry at all go to the public /healthz, the others to /.
+func serveWithRequestInfo(headers map[string]string, trustedProxies ...string) (model.RequestInfo, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.SetTrustedProxies(trustedProxies)
+	var info model.RequestInfo
+	router.Use(RequestInfo(fakeVerifier, zap.NewNop(), "/healthz"))
+	handler := func(c *gin.Context) {
+		info = model.RequestInfoFrom(c.Request.Context())
+	}
+	router.GET("/", handler)
+	router.GET("/healthz", handler)
+
+	path := "/"
+	if _, ok := headers[IAPAssertionHeader]; !ok {
+		path = "/healthz"
+	}
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return info, recorder
+}
+
+func TestRequestInfoReadsActorAndRequestID(t *testing.T) {
+	info, recorder := serveWithRequestInfo(map[string]string{
+		IAPAssertionHeader: validAssertion,
+		RequestIDHeader:    "req-1",
+	})
+
+	assert.Equal(t, model.RequestInfo{Actor: "admin@example.com", ClientIP: "192.0.2.1", RequestID: "req-1"}, info)
+	assert.Equal(t, "req-1", recorder.Header().Get(RequestIDHeader))
+}
+
+func TestRequestInfoRejectsUnverifiedActors(t *testing.T) {
+	for _, headers := range []map[string]string{
+		{IAPAssertionHeader: "forged", RequestIDHeader: "req-1"},
+		{IAPAssertionHeader: "", RequestIDHeader: "req-1", "X-Goog-Authenticated-User-Email": "accounts.google.com:admin@example.com"},
+	} {
+		info, recorder := serveWithRequestInfo(headers)
+
+		assert.Equal(t, model.RequestInfo{}, info, "the handler must not run")
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Equal(t, ProblemContentType, recorder.Header().Get("Content-Type"))
+		assert.Contains(t, recorder.Body.String(), `"requestId":"req-1"`)
+	}
+}
+
+func TestRequestInfoSkipsVerificationForPublicPaths(t *testing.T) {
+	info, recorder := serveWithRequestInfo(map[string]string{"X-Goog-Authenticated-User-Email": "accounts.google.com:admin@example.com"})
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, model.UnknownActor, info.Actor)
+}
+
+func TestRequestInfoWithoutVerifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var info model.RequestInfo
+	router.GET("/", RequestInfo(nil, zap.NewNop()), func(c *gin.Context) {
+		info = model.RequestInfoFrom(c.Request.Context())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(IAPAssertionHeader, validAssertion)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, model.UnknownActor, info.Actor)
+}
+
+func TestRequestInfoTrustsForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	headers := map[string]string{"X-Forwarded-For": "203.0.113.7"}
+
+	info, _ := serveWithRequestInfo(headers)
+	assert.Equal(t, "192.0.2.1", info.ClientIP)
+
+	info, _ = serveWithRequestInfo(headers, "192.0.2.0/24")
+	assert.Equal(t, "203.0.113.7", info.ClientIP)
+}
+
+func TestRequestInfoGeneratesRequestID(t *testing.T) {
+	for _, requestID := range []string{"", "has spaces", strings.Repeat("x", 129)} {
+		info, recorder := serveWithRequestInfo(map[string]string{RequestIDHeader: requestID})
+
+		assert.Equal(t, model.UnknownActor, info.Actor)
+		assert.Len(t, info.RequestID, 36)
+		assert.Equal(t, info.RequestID, recorder.Header().Get(RequestIDHeader))
+	}
+}
+
+// roundTripFunc serves the requests of an http.Client without a network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// signIAPAssertion returns a JWT signed like those of Identity-Aware Proxy
+// and a validator that trusts the key it was signed with.
+func signIAPAssertion(t *testing.T, claims map[string]any) (string, *idtoken.Validator) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encode := func(value any) string {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	content := encode(map[string]string{"alg": "ES256", "typ": "JWT", "kid": "test-key"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(content))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	jwks := map[string]any{"keys": []map[string]string{{
+		"alg": "ES256", "crv": "P-256", "kid": "test-key", "kty": "EC", "use": "sig",
+		"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}}}
+	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		body, err := json.Marshal(jwks)
+		require.NoError(t, err)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body))}, nil
+	})}
+	validator, err := idtoken.NewValidator(context.Background(), idtoken.WithHTTPClient(client))
+	require.NoError(t, err)
+	return content + "." + base64.RawURLEncoding.EncodeToString(signature), validator
+}
+
+func TestIAPVerifier(t *testing.T) {
+	const audience = "/projects/1/global/backendServices/2"
+	claims := map[string]any{
+		"iss":   "https://cloud.google.com/iap",
+		"aud":   audience,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"email": "admin@example.com",
+	}
+
+	assertion, validator := signIAPAssertion(t, claims)
+	actor, err := IAPVerifier(validator, audience)(context.Background(), assertion)
+	require.NoError(t, err)
+	assert.Equal(t, "admin@example.com", actor)
+
+	_, err = IAPVerifier(validator, "/projects/1/global/backendServices/3")(context.Background(), assertion)
+	assert.Error(t, err, "assertions for other backends are rejected")
+
+	_, err = IAPVerifier(validator, audience)(context.Background(), assertion[:len(assertion)-4]+"AAAA")
+	assert.Error(t, err, "assertions with a wrong signature are rejected")
+
+	claims["iss"] = "https://accounts.google.com"
+	assertion, validator = signIAPAssertion(t, claims)
+	_, err = IAPVerifier(validator, audience)(context.Background(), assertion)
+	assert.EqualError(t, err, `unexpected issuer "https://accounts.google.com"`)
+}
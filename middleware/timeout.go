@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeoutHeader lets a client choose the deadline of its request,
+// as a Go duration such as "5s", up to the configured maximum.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// Timeout attaches a deadline to the request context so that the database
+// calls it makes are cancelled once the deadline passes. Handlers that fail
+// because of the deadline are expected to answer 504; if a handler returns
+// without writing anything, Timeout does it for them.
+func Timeout(timeout, maxTimeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestTimeout := timeout
+		if value := c.GetHeader(RequestTimeoutHeader); value != "" {
+			requested, err := time.ParseDuration(value)
+			if err != nil || requested <= 0 {
+				abortWithError(c, http.StatusBadRequest, RequestTimeoutHeader+" must be a positive duration such as 5s")
+				return
+			}
+			requestTimeout = min(requested, maxTimeout)
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			abortWithError(c, http.StatusGatewayTimeout, "Request timed out")
+		}
+	}
+}
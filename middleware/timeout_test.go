@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveWithTimeout(timeout, maxTimeout time.Duration, header string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", Timeout(timeout, maxTimeout), handler)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		request.Header.Set(RequestTimeoutHeader, header)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestTimeoutAnswersGatewayTimeout(t *testing.T) {
+	recorder := serveWithTimeout(10*time.Millisecond, time.Second, "", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	assert.Equal(t, http.StatusGatewayTimeout, recorder.Code)
+	assert.Equal(t, ProblemContentType, recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Gateway Timeout","status":504,"detail":"Request timed out","error":"Request timed out"}`, recorder.Body.String())
+}
+
+func TestTimeoutKeepsHandlerResponse(t *testing.T) {
+	recorder := serveWithTimeout(10*time.Millisecond, time.Second, "", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	})
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestTimeoutHeader(t *testing.T) {
+	tests := []struct {
+		name             string
+		header           string
+		expectedStatus   int
+		expectedDeadline time.Duration
+	}{
+		{name: "default", expectedStatus: http.StatusOK, expectedDeadline: time.Minute},
+		{name: "shorter", header: "5s", expectedStatus: http.StatusOK, expectedDeadline: 5 * time.Second},
+		{name: "capped", header: "1h", expectedStatus: http.StatusOK, expectedDeadline: 2 * time.Minute},
+		{name: "invalid", header: "soon", expectedStatus: http.StatusBadRequest},
+		{name: "negative", header: "-1s", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			recorder := serveWithTimeout(time.Minute, 2*time.Minute, tt.header, func(c *gin.Context) {
+				deadline, _ := c.Request.Context().Deadline()
+				remaining = time.Until(deadline)
+				c.Status(http.StatusOK)
+			})
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.InDelta(t, tt.expectedDeadline, remaining, float64(time.Second))
+			}
+		})
+	}
+}
@@ -0,0 +1,597 @@
+// Package migrations applies versioned schema changes and records them in
+// the schema_migrations table.
+//
+// A migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql holding statements separated by semicolons.
+// The checksum of every applied up file is stored, so a migration that is
+// edited after it ran is reported instead of silently diverging.
+//
+// Up, Down and Baseline hold a lock row in schema_migrations while they
+// run, so servers that apply migrations on startup can start together:
+// the others wait and then find nothing left to do.
+//
+// Every database dialect has its own directory of migrations. A version
+// means the same schema change in all of them; changes that only concern
+// Spanner, such as commit timestamps and change streams, are missing from
+// the others.
+package migrations
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed spanner/*.sql postgres/*.sql sqlite/*.sql
+var dialectFiles embed.FS
+
+// ErrSchemaBehind is returned by EnsureCurrent when migrations are pending.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// dollarQuote matches the opening of a dollar-quoted PostgreSQL string such
+// as $$ or $body$.
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+const (
+	// lockVersion is the version of the row in schema_migrations that
+	// marks a running migration. Versions of migrations start at 1.
+	lockVersion = 0
+	// lockTimeout is how long a lock is held at most. A lock that is older
+	// belongs to a run that died and is taken over.
+	lockTimeout = time.Hour
+	// lockPoll is how often a held lock is checked while waiting.
+	lockPoll = time.Second
+)
+
+// ErrLocked is returned when the lock cannot be taken because the context
+// ended while another run held it.
+var ErrLocked = errors.New("another migration is running")
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       []string
+	Down     []string
+	Checksum string
+}
+
+// Status describes a known migration and whether it has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type appliedMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Spanner returns the migrations for Spanner databases (GoogleSQL dialect).
+func Spanner() ([]Migration, error) {
+	return loadDialect("spanner")
+}
+
+// Postgres returns the migrations for PostgreSQL, which also run on Spanner
+// databases with the PostgreSQL dialect.
+func Postgres() ([]Migration, error) {
+	return loadDialect("postgres")
+}
+
+func SQLite() ([]Migration, error) {
+	return loadDialect("sqlite")
+}
+
+// ForDialect returns the migrations for the gorm dialect of a database.
+func ForDialect(dialect string) ([]Migration, error) {
+	switch dialect {
+	case "spanner":
+		return Spanner()
+	case "postgres":
+		return Postgres()
+	case "sqlite":
+		return SQLite()
+	}
+	return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+}
+
+func loadDialect(dir string) ([]Migration, error) {
+	files, err := fs.Sub(dialectFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	return Load(files)
+}
+
+// Load reads the migrations in the root of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %q is not named <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %q: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = splitStatements(string(content))
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = splitStatements(string(content))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements drops comments and splits the rest on the semicolons that
+// end statements. Semicolons in quoted strings and identifiers, including
+// GoogleSQL triple-quoted strings and PostgreSQL dollar-quoted bodies, are
+// kept.
+func splitStatements(content string) []string {
+	var statements []string
+	var statement strings.Builder
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+			statement.WriteByte(' ')
+			i += end
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			end := quotedLength(rest)
+			statement.WriteString(rest[:end])
+			i += end
+		case rest[0] == '$' && dollarQuote.MatchString(rest):
+			tag := dollarQuote.FindString(rest)
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 2 * len(tag)
+			}
+			statement.WriteString(rest[:end])
+			i += end
+		case rest[0] == ';':
+			if text := strings.TrimSpace(statement.String()); text != "" {
+				statements = append(statements, text)
+			}
+			statement.Reset()
+			i++
+		default:
+			statement.WriteByte(rest[0])
+			i++
+		}
+	}
+	if text := strings.TrimSpace(statement.String()); text != "" {
+		statements = append(statements, text)
+	}
+	return statements
+}
+
+// quotedLength returns the length of the quoted string or identifier at the
+// start of s. Quotes are escaped with a backslash or by doubling them.
+func quotedLength(s string) int {
+	quote := s[:1]
+	if triple := strings.Repeat(quote, 3); strings.HasPrefix(s, triple) {
+		for i := 3; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if strings.HasPrefix(s[i:], triple) {
+				return i + 3
+			}
+		}
+		return len(s)
+	}
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == quote[0] && i+1 < len(s) && s[i+1] == quote[0]:
+			i++
+		case s[i] == quote[0]:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func New(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies all pending migrations in order and returns how many ran. It
+// creates the schema_migrations table if it does not exist yet.
+func (m *Migrator) Up(ctx context.Context) (count int, err error) {
+	if err := m.createTable(ctx); err != nil {
+		return 0, err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	applied, err := m.readApplied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.exec(ctx, migration.Up); err != nil {
+			return count, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		if err := m.record(ctx, migration); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (count int, err error) {
+	exists, err := m.tableExists(ctx)
+	if err != nil || !exists {
+		return 0, err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	applied, err := m.readApplied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if len(migration.Down) == 0 {
+			return count, fmt.Errorf("migration %d (%s) has no down file", migration.Version, migration.Name)
+		}
+		if err := m.exec(ctx, migration.Down); err != nil {
+			return count, fmt.Errorf("reverting migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		if err := m.db.WithContext(ctx).Delete(&appliedMigration{}, migration.Version).Error; err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		record, ok := applied[migration.Version]
+		statuses[i] = Status{Version: migration.Version, Name: migration.Name, Applied: ok, AppliedAt: record.AppliedAt}
+	}
+	return statuses, nil
+}
+
+// Baseline records the migrations up to version as applied without running
+// them, for databases whose schema already matches those migrations.
+func (m *Migrator) Baseline(ctx context.Context, version int64) (err error) {
+	if err := m.createTable(ctx); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	applied, err := m.readApplied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := m.record(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureCurrent returns ErrSchemaBehind if any migration is pending.
+func (m *Migrator) EnsureCurrent(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	pending := len(m.migrations) - len(applied)
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migrations", ErrSchemaBehind, pending)
+	}
+	return nil
+}
+
+// applied returns the applied migrations by version, none if the
+// schema_migrations table does not exist yet. Only Up and Baseline create
+// it, as that is a schema change on Spanner.
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	exists, err := m.tableExists(ctx)
+	if err != nil || !exists {
+		return map[int64]appliedMigration{}, err
+	}
+	return m.readApplied(ctx)
+}
+
+// Pending returns how many migrations are applied and how many are
+// pending. It only reads, so it is cheap enough for health checks.
+func (m *Migrator) Pending(ctx context.Context) (applied int, pending int, err error) {
+	records, err := m.applied(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, migration := range m.migrations {
+		if _, ok := records[migration.Version]; ok {
+			applied++
+		} else {
+			pending++
+		}
+	}
+	return applied, pending, nil
+}
+
+// readApplied reads the applied migrations by version. It fails if one of
+// them is unknown to this build or was changed after it ran.
+func (m *Migrator) readApplied(ctx context.Context) (map[int64]appliedMigration, error) {
+	var records []appliedMigration
+	if err := m.db.WithContext(ctx).Order("version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	applied := make(map[int64]appliedMigration, len(records))
+	for _, record := range records {
+		if record.Version == lockVersion {
+			continue
+		}
+		migration, ok := known[record.Version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %d (%s) is unknown to this build", record.Version, record.Name)
+		}
+		if migration.Checksum != record.Checksum {
+			return nil, fmt.Errorf("migration %d (%s) was changed after it was applied", record.Version, record.Name)
+		}
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// tableExists reports whether the schema_migrations table exists.
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	switch m.db.Dialector.Name() {
+	case "sqlite":
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	case "spanner":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = '' AND table_name = ?"
+	case "postgres":
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"
+	}
+	var count int64
+	if err := m.db.WithContext(ctx).Raw(query, appliedMigration{}.TableName()).Scan(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// createTable creates the schema_migrations table if it is missing.
+func (m *Migrator) createTable(ctx context.Context) error {
+	exists, err := m.tableExists(ctx)
+	if err != nil || exists {
+		return err
+	}
+	statement := `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT NOT NULL PRIMARY KEY,
+  name VARCHAR(255),
+  checksum VARCHAR(64),
+  applied_at TIMESTAMP
+)`
+	switch m.db.Dialector.Name() {
+	case "spanner":
+		statement = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INT64 NOT NULL,
+  name STRING(MAX),
+  checksum STRING(64),
+  applied_at TIMESTAMP,
+) PRIMARY KEY (version)`
+	case "postgres":
+		// Spanner's PostgreSQL dialect has no timestamp without time zone.
+		statement = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint NOT NULL PRIMARY KEY,
+  name varchar(255),
+  checksum varchar(64),
+  applied_at timestamptz
+)`
+	}
+	return m.db.WithContext(ctx).Exec(statement).Error
+}
+
+// lock takes the lock row, waiting while another run holds it, and returns
+// the function that releases it.
+func (m *Migrator) lock(ctx context.Context) (unlock func() error, err error) {
+	owner, err := lockOwner()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		locked, err := m.tryLock(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return func() error {
+				// The lock is released even if ctx was cancelled.
+				return m.db.WithContext(context.WithoutCancel(ctx)).
+					Where("version = ? AND checksum = ?", lockVersion, owner).
+					Delete(&appliedMigration{}).Error
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrLocked, ctx.Err())
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// tryLock inserts the lock row and reports false if another run holds it.
+func (m *Migrator) tryLock(ctx context.Context, owner string) (bool, error) {
+	db := m.db.WithContext(ctx)
+	now := time.Now().UTC()
+	if err := db.Where("version = ? AND applied_at < ?", lockVersion, now.Add(-lockTimeout)).Delete(&appliedMigration{}).Error; err != nil {
+		return false, err
+	}
+	err := db.Create(&appliedMigration{Version: lockVersion, Name: "lock", Checksum: owner, AppliedAt: now}).Error
+	if err == nil {
+		return true, nil
+	}
+	// The insert fails on the primary key if the lock is held.
+	var held int64
+	if countErr := db.Model(&appliedMigration{}).Where("version = ?", lockVersion).Count(&held).Error; countErr != nil || held == 0 {
+		return false, err
+	}
+	return false, nil
+}
+
+// Unlock removes the lock left behind by a run that was killed, so that the
+// next run does not wait for it to time out.
+func (m *Migrator) Unlock(ctx context.Context) error {
+	return m.db.WithContext(ctx).Where("version = ?", lockVersion).Delete(&appliedMigration{}).Error
+}
+
+func lockOwner() (string, error) {
+	owner := make([]byte, 16)
+	if _, err := rand.Read(owner); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(owner), nil
+}
+
+func (m *Migrator) record(ctx context.Context, migration Migration) error {
+	return m.db.WithContext(ctx).Create(&appliedMigration{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		Checksum:  migration.Checksum,
+		AppliedAt: time.Now().UTC(),
+	}).Error
+}
+
+// exec runs the statements of one migration. On Spanner they are submitted
+// as a single DDL batch, which is much faster than one schema change per
+// statement.
+func (m *Migrator) exec(ctx context.Context, statements []string) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		batched := conn.Dialector.Name() == "spanner"
+		if batched {
+			if err := conn.Exec("START BATCH DDL").Error; err != nil {
+				return err
+			}
+		}
+		for _, statement := range statements {
+			if err := conn.Exec(statement).Error; err != nil {
+				if batched {
+					conn.Exec("ABORT BATCH")
+				}
+				return err
+			}
+		}
+		if batched {
+			return conn.Exec("RUN BATCH").Error
+		}
+		return nil
+	})
+}
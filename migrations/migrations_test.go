@@ -0,0 +1,352 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testFiles = fstest.MapFS{
+	"0002_add_phone.up.sql":      {Data: []byte("-- phone numbers\nALTER TABLE users ADD COLUMN phone STRING(32);\n")},
+	"0002_add_phone.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN phone;\n")},
+	"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id STRING(36)) PRIMARY KEY (id);\nCREATE INDEX idx_users_id ON users (id);\n")},
+	"0001_create_users.down.sql": {Data: []byte("DROP INDEX idx_users_id;\nDROP TABLE users;\n")},
+	"README.md":                  {Data: []byte("not a migration")},
+}
+
+func mockDatabase() (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	gormDb, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("an error '%s' was not expected when opening a gorm database connection", err)
+	}
+	return gormDb, mock
+}
+
+func loadTestMigrations(t *testing.T) []Migration {
+	migrations, err := Load(testFiles)
+	require.NoError(t, err)
+	return migrations
+}
+
+func expectTable(mock sqlmock.Sqlmock, exists bool) {
+	count := 0
+	if exists {
+		count = 1
+	}
+	mock.ExpectQuery("^SELECT COUNT\\(\\*\\) FROM information_schema.tables").
+		WithArgs("schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `schema_migrations` WHERE version = \\? AND applied_at < \\?$").
+		WithArgs(0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `schema_migrations`").
+		WithArgs(int64(0), "lock", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `schema_migrations` WHERE version = \\? AND checksum = \\?$").
+		WithArgs(0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectApplied(mock sqlmock.Sqlmock, migrations ...Migration) {
+	rows := sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"})
+	for _, migration := range migrations {
+		rows.AddRow(migration.Version, migration.Name, migration.Checksum, time.Now())
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `schema_migrations` ORDER BY version$").WillReturnRows(rows)
+}
+
+func TestLoad(t *testing.T) {
+	migrations := loadTestMigrations(t)
+
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Equal(t, []string{"CREATE TABLE users (id STRING(36)) PRIMARY KEY (id)", "CREATE INDEX idx_users_id ON users (id)"}, migrations[0].Up)
+	assert.Equal(t, []string{"DROP INDEX idx_users_id", "DROP TABLE users"}, migrations[0].Down)
+	assert.Equal(t, []string{"ALTER TABLE users ADD COLUMN phone STRING(32)"}, migrations[1].Up)
+	assert.Len(t, migrations[1].Checksum, 64)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(fstest.MapFS{"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")}})
+	assert.EqualError(t, err, "migration 1 (create_users) has no up file")
+
+	_, err = Load(fstest.MapFS{"create_users.sql": {Data: []byte("CREATE TABLE users;")}})
+	assert.EqualError(t, err, `migration file "create_users.sql" is not named <version>_<name>.up.sql or .down.sql`)
+}
+
+func TestDialectMigrations(t *testing.T) {
+	spanner, err := Spanner()
+	require.NoError(t, err)
+	names := map[int64]string{}
+	for _, migration := range spanner {
+		names[migration.Version] = migration.Name
+	}
+
+	for _, dialect := range []string{"spanner", "postgres", "sqlite"} {
+		migrations, err := ForDialect(dialect)
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+		assert.Equal(t, "create_users", migrations[0].Name)
+		for _, migration := range migrations {
+			assert.NotEmpty(t, migration.Down, "%s migration %d has no down file", dialect, migration.Version)
+			assert.Equal(t, names[migration.Version], migration.Name, "%s migration %d differs from Spanner", dialect, migration.Version)
+		}
+	}
+
+	_, err = ForDialect("mysql")
+	assert.EqualError(t, err, `no migrations for database dialect "mysql"`)
+}
+
+func TestSQLiteMigrationsUpAndDown(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+	migrations, err := SQLite()
+	require.NoError(t, err)
+	migrator := New(db, migrations)
+	ctx := context.Background()
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), applied)
+	require.NoError(t, migrator.EnsureCurrent(ctx))
+	assert.True(t, db.Migrator().HasTable("user_audit_entries"))
+
+	reverted, err := migrator.Down(ctx, len(migrations))
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), reverted)
+	assert.False(t, db.Migrator().HasTable("users"))
+
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err, "migrations apply again after being reverted")
+}
+
+func TestSQLiteMigrationsKeepLiveEmailsUnique(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+	migrations, err := SQLite()
+	require.NoError(t, err)
+	_, err = New(db, migrations).Up(context.Background())
+	require.NoError(t, err)
+
+	insert := "INSERT INTO users (id, email, deleted_at) VALUES (?, ?, ?)"
+	require.NoError(t, db.Exec(insert, "deleted", "john@example.com", time.Now()).Error)
+	require.NoError(t, db.Exec(insert, "live", "john@example.com", nil).Error, "deleted users do not hold on to their email")
+	assert.Error(t, db.Exec(insert, "other", "john@example.com", nil).Error)
+}
+
+func TestSplitStatementsKeepsQuotedSemicolons(t *testing.T) {
+	content := `-- a comment; with a semicolon
+INSERT INTO notes (text) VALUES ('a; b', 'it''s; fine', "c\"; d");
+/* block; comment */ UPDATE notes SET text = """x; y""" WHERE id = 1;
+CREATE FUNCTION f() RETURNS trigger AS $body$ BEGIN RETURN NEW; END; $body$ LANGUAGE plpgsql;
+SELECT 1 -- trailing; comment
+`
+	assert.Equal(t, []string{
+		`INSERT INTO notes (text) VALUES ('a; b', 'it''s; fine', "c\"; d")`,
+		`UPDATE notes SET text = """x; y""" WHERE id = 1`,
+		`CREATE FUNCTION f() RETURNS trigger AS $body$ BEGIN RETURN NEW; END; $body$ LANGUAGE plpgsql`,
+		`SELECT 1`,
+	}, splitStatements(content))
+}
+
+func TestUpWaitsForLock(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+	migrations, err := SQLite()
+	require.NoError(t, err)
+	migrator := New(db, migrations)
+	require.NoError(t, migrator.createTable(context.Background()))
+	unlock, err := migrator.lock(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	require.NoError(t, unlock())
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), applied)
+	applied, pending, err := migrator.Pending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), applied, "the lock row is not counted as a migration")
+	assert.Zero(t, pending)
+}
+
+func TestUnlockRemovesStaleLock(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+	migrator := New(db, loadTestMigrations(t))
+	require.NoError(t, migrator.createTable(context.Background()))
+	_, err := migrator.lock(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.Unlock(context.Background()))
+	unlock, err := migrator.lock(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, unlock())
+}
+
+func TestUpAppliesPendingMigrations(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	migrations := loadTestMigrations(t)
+
+	expectTable(mock, true)
+	expectLock(mock)
+	expectApplied(mock, migrations[0])
+	mock.ExpectExec("^ALTER TABLE users ADD COLUMN phone STRING\\(32\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `schema_migrations`").
+		WithArgs(int64(2), "add_phone", migrations[1].Checksum, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := New(mockDb, migrations).Up(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpStopsAtFailingMigration(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	migrations := loadTestMigrations(t)
+
+	expectTable(mock, false)
+	mock.ExpectExec("^CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLock(mock)
+	expectApplied(mock)
+	mock.ExpectExec("^CREATE TABLE users").WillReturnError(assert.AnError)
+	expectUnlock(mock)
+
+	applied, err := New(mockDb, migrations).Up(context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorContains(t, err, "migration 1 (create_users)")
+	assert.Equal(t, 0, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownRevertsNewestFirst(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	migrations := loadTestMigrations(t)
+
+	expectTable(mock, true)
+	expectLock(mock)
+	expectApplied(mock, migrations...)
+	mock.ExpectExec("^ALTER TABLE users DROP COLUMN phone$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `schema_migrations` WHERE `schema_migrations`.`version` = \\?$").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	reverted, err := New(mockDb, migrations).Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	migrations := loadTestMigrations(t)
+
+	expectTable(mock, true)
+	expectApplied(mock, migrations[0])
+
+	statuses, err := New(mockDb, migrations).Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	assert.False(t, statuses[1].Applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnsureCurrent(t *testing.T) {
+	migrations := loadTestMigrations(t)
+
+	mockDb, mock := mockDatabase()
+	expectTable(mock, true)
+	expectApplied(mock, migrations...)
+	assert.NoError(t, New(mockDb, migrations).EnsureCurrent(context.Background()))
+
+	mockDb, mock = mockDatabase()
+	expectTable(mock, true)
+	expectApplied(mock, migrations[0])
+	err := New(mockDb, migrations).EnsureCurrent(context.Background())
+	assert.ErrorIs(t, err, ErrSchemaBehind)
+	assert.EqualError(t, err, "database schema is behind: 1 pending migrations")
+}
+
+func TestPendingDoesNotCreateTable(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	migrations := loadTestMigrations(t)
+
+	expectTable(mock, false)
+
+	applied, pending, err := New(mockDb, migrations).Pending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+	assert.Equal(t, 2, pending)
+
+	expectTable(mock, true)
+	expectApplied(mock, migrations[0])
+
+	applied, pending, err = New(mockDb, migrations).Pending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.Equal(t, 1, pending)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangedMigrationIsRejected(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	migrations := loadTestMigrations(t)
+
+	changed := migrations[0]
+	changed.Checksum = "edited"
+	expectTable(mock, true)
+	expectLock(mock)
+	expectApplied(mock, changed)
+	expectUnlock(mock)
+
+	_, err := New(mockDb, migrations).Up(context.Background())
+	assert.EqualError(t, err, "migration 1 (create_users) was changed after it was applied")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStartupOffSkipsDatabase(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	assert.NoError(t, New(mockDb, loadTestMigrations(t)).Startup(context.Background(), StartupOff))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
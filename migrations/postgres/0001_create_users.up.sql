@@ -0,0 +1,17 @@
+-- Runs on PostgreSQL and on Spanner databases with the PostgreSQL dialect,
+-- so only features both support are used.
+CREATE TABLE users (
+  id varchar(36) NOT NULL PRIMARY KEY,
+  legacy_id bigint,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz,
+  name text,
+  email text,
+  address text,
+  password text
+);
+
+CREATE UNIQUE INDEX idx_users_legacy_id ON users (legacy_id) WHERE legacy_id IS NOT NULL;
+
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,3 @@
+DROP INDEX idx_outbox_events_pending;
+
+DROP TABLE outbox_events;
@@ -0,0 +1,13 @@
+CREATE TABLE outbox_events (
+  id varchar(36) NOT NULL PRIMARY KEY,
+  type varchar(64),
+  user_id varchar(36),
+  payload text,
+  created_at timestamptz,
+  attempts bigint,
+  last_error text,
+  next_attempt_at timestamptz,
+  published_at timestamptz
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events (published_at, next_attempt_at);
@@ -0,0 +1,32 @@
+CREATE TABLE webhook_subscriptions (
+  id varchar(36) NOT NULL PRIMARY KEY,
+  url varchar(2048),
+  event_types text,
+  secret varchar(128),
+  consecutive_failures bigint,
+  disabled_at timestamptz,
+  disabled_reason text,
+  created_at timestamptz,
+  updated_at timestamptz
+);
+
+-- Deliveries are keyed by the outbox event they carry, so an event that is
+-- published twice is delivered to each subscription only once.
+CREATE TABLE webhook_deliveries (
+  subscription_id varchar(36) NOT NULL,
+  id varchar(36) NOT NULL,
+  event_type varchar(64),
+  payload text,
+  status varchar(16),
+  attempts bigint,
+  next_attempt_at timestamptz,
+  last_status_code bigint,
+  last_error text,
+  created_at timestamptz,
+  delivered_at timestamptz,
+  PRIMARY KEY (subscription_id, id),
+  CONSTRAINT fk_webhook_deliveries_subscription FOREIGN KEY (subscription_id)
+    REFERENCES webhook_subscriptions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (status, next_attempt_at);
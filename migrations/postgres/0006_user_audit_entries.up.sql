@@ -0,0 +1,14 @@
+-- No foreign key to users, so that the audit trail outlives the users it
+-- describes.
+CREATE TABLE user_audit_entries (
+  id varchar(36) NOT NULL PRIMARY KEY,
+  user_id varchar(36),
+  action varchar(32),
+  actor text,
+  client_ip varchar(64),
+  request_id varchar(128),
+  changes text,
+  created_at timestamptz
+);
+
+CREATE INDEX idx_user_audit_entries_user_id ON user_audit_entries (user_id, created_at DESC);
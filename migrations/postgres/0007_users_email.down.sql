@@ -0,0 +1,3 @@
+DROP INDEX idx_users_live_email;
+
+DROP INDEX idx_users_email;
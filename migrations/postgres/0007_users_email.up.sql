@@ -0,0 +1,7 @@
+CREATE INDEX idx_users_email ON users (email);
+
+-- Deleted users keep their email, so only live users must not share one.
+-- Spanner's PostgreSQL dialect only filters indexes on IS NOT NULL; such
+-- databases need idx_users_email created by hand and this version marked
+-- as applied with migrate baseline.
+CREATE UNIQUE INDEX idx_users_live_email ON users (email) WHERE deleted_at IS NULL;
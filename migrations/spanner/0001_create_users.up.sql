@@ -0,0 +1,15 @@
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  legacy_id INT64,
+  created_at TIMESTAMP,
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP,
+  name STRING(MAX),
+  email STRING(MAX),
+  address STRING(MAX),
+  password STRING(MAX),
+) PRIMARY KEY (id);
+
+CREATE UNIQUE NULL_FILTERED INDEX idx_users_legacy_id ON users (legacy_id);
+
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,3 @@
+ALTER TABLE users ALTER COLUMN updated_at SET OPTIONS (allow_commit_timestamp = null);
+
+ALTER TABLE users ALTER COLUMN created_at SET OPTIONS (allow_commit_timestamp = null);
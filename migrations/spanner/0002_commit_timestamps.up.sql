@@ -0,0 +1,5 @@
+-- created_at and updated_at are set to the commit timestamp by the
+-- repository instead of the clock of the server that wrote the row.
+ALTER TABLE users ALTER COLUMN created_at SET OPTIONS (allow_commit_timestamp = true);
+
+ALTER TABLE users ALTER COLUMN updated_at SET OPTIONS (allow_commit_timestamp = true);
@@ -0,0 +1 @@
+DROP CHANGE STREAM users_changes;
@@ -0,0 +1,6 @@
+-- Feeds GET /users/changes. The retention period bounds how far back the
+-- since parameter may reach (services.ChangeRetention).
+CREATE CHANGE STREAM users_changes FOR users OPTIONS (
+  value_capture_type = 'OLD_AND_NEW_VALUES',
+  retention_period = '7d'
+);
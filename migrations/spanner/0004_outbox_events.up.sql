@@ -0,0 +1,13 @@
+CREATE TABLE outbox_events (
+  id STRING(36) NOT NULL,
+  type STRING(64),
+  user_id STRING(36),
+  payload STRING(MAX),
+  created_at TIMESTAMP,
+  attempts INT64,
+  last_error STRING(MAX),
+  next_attempt_at TIMESTAMP,
+  published_at TIMESTAMP,
+) PRIMARY KEY (id);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events (published_at, next_attempt_at);
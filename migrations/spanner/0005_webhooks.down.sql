@@ -0,0 +1,5 @@
+DROP INDEX idx_webhook_deliveries_pending;
+
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhook_subscriptions;
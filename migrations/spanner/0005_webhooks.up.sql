@@ -0,0 +1,30 @@
+CREATE TABLE webhook_subscriptions (
+  id STRING(36) NOT NULL,
+  url STRING(2048),
+  event_types STRING(MAX),
+  secret STRING(128),
+  consecutive_failures INT64,
+  disabled_at TIMESTAMP,
+  disabled_reason STRING(MAX),
+  created_at TIMESTAMP,
+  updated_at TIMESTAMP,
+) PRIMARY KEY (id);
+
+-- Deliveries are keyed by the outbox event they carry, so an event that is
+-- published twice is delivered to each subscription only once.
+CREATE TABLE webhook_deliveries (
+  subscription_id STRING(36) NOT NULL,
+  id STRING(36) NOT NULL,
+  event_type STRING(64),
+  payload STRING(MAX),
+  status STRING(16),
+  attempts INT64,
+  next_attempt_at TIMESTAMP,
+  last_status_code INT64,
+  last_error STRING(MAX),
+  created_at TIMESTAMP,
+  delivered_at TIMESTAMP,
+) PRIMARY KEY (subscription_id, id),
+  INTERLEAVE IN PARENT webhook_subscriptions ON DELETE CASCADE;
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,14 @@
+-- Not interleaved in users, so that the audit trail outlives the users it
+-- describes.
+CREATE TABLE user_audit_entries (
+  id STRING(36) NOT NULL,
+  user_id STRING(36),
+  action STRING(32),
+  actor STRING(MAX),
+  client_ip STRING(64),
+  request_id STRING(128),
+  changes STRING(MAX),
+  created_at TIMESTAMP,
+) PRIMARY KEY (id);
+
+CREATE INDEX idx_user_audit_entries_user_id ON user_audit_entries (user_id, created_at DESC);
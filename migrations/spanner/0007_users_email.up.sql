@@ -0,0 +1,4 @@
+-- Spanner has no partial indexes, so an email may be shared by a live and
+-- a deleted user and the uniqueness of live emails is only checked by the
+-- service, in the transaction of each write.
+CREATE INDEX idx_users_email ON users (email);
@@ -0,0 +1,5 @@
+DROP INDEX idx_users_deleted_at;
+
+DROP INDEX idx_users_legacy_id;
+
+DROP TABLE users;
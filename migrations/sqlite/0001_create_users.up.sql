@@ -0,0 +1,15 @@
+CREATE TABLE users (
+  id TEXT NOT NULL PRIMARY KEY,
+  legacy_id INTEGER,
+  created_at DATETIME,
+  updated_at DATETIME,
+  deleted_at DATETIME,
+  name TEXT,
+  email TEXT,
+  address TEXT,
+  password TEXT
+);
+
+CREATE UNIQUE INDEX idx_users_legacy_id ON users (legacy_id) WHERE legacy_id IS NOT NULL;
+
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
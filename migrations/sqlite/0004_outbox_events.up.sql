@@ -0,0 +1,13 @@
+CREATE TABLE outbox_events (
+  id TEXT NOT NULL PRIMARY KEY,
+  type TEXT,
+  user_id TEXT,
+  payload TEXT,
+  created_at DATETIME,
+  attempts INTEGER,
+  last_error TEXT,
+  next_attempt_at DATETIME,
+  published_at DATETIME
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events (published_at, next_attempt_at);
@@ -0,0 +1,32 @@
+CREATE TABLE webhook_subscriptions (
+  id TEXT NOT NULL PRIMARY KEY,
+  url TEXT,
+  event_types TEXT,
+  secret TEXT,
+  consecutive_failures INTEGER,
+  disabled_at DATETIME,
+  disabled_reason TEXT,
+  created_at DATETIME,
+  updated_at DATETIME
+);
+
+-- Deliveries are keyed by the outbox event they carry, so an event that is
+-- published twice is delivered to each subscription only once.
+CREATE TABLE webhook_deliveries (
+  subscription_id TEXT NOT NULL,
+  id TEXT NOT NULL,
+  event_type TEXT,
+  payload TEXT,
+  status TEXT,
+  attempts INTEGER,
+  next_attempt_at DATETIME,
+  last_status_code INTEGER,
+  last_error TEXT,
+  created_at DATETIME,
+  delivered_at DATETIME,
+  PRIMARY KEY (subscription_id, id),
+  CONSTRAINT fk_webhook_deliveries_subscription FOREIGN KEY (subscription_id)
+    REFERENCES webhook_subscriptions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,3 @@
+DROP INDEX idx_user_audit_entries_user_id;
+
+DROP TABLE user_audit_entries;
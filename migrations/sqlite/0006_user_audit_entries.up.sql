@@ -0,0 +1,14 @@
+-- No foreign key to users, so that the audit trail outlives the users it
+-- describes.
+CREATE TABLE user_audit_entries (
+  id TEXT NOT NULL PRIMARY KEY,
+  user_id TEXT,
+  action TEXT,
+  actor TEXT,
+  client_ip TEXT,
+  request_id TEXT,
+  changes TEXT,
+  created_at DATETIME
+);
+
+CREATE INDEX idx_user_audit_entries_user_id ON user_audit_entries (user_id, created_at DESC);
@@ -0,0 +1,4 @@
+CREATE INDEX idx_users_email ON users (email);
+
+-- Deleted users keep their email, so only live users must not share one.
+CREATE UNIQUE INDEX idx_users_live_email ON users (email) WHERE deleted_at IS NULL;
@@ -0,0 +1,15 @@
+//go:build !cgo
+
+package migrations
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// openSQLite skips the test, as the SQLite driver needs cgo.
+func openSQLite(t *testing.T, dsn string, config *gorm.Config) *gorm.DB {
+	t.Skip("SQLite needs a build with CGO_ENABLED=1")
+	return nil
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+)
+
+// StartupMode selects what the server does with migrations when it starts.
+type StartupMode string
+
+const (
+	// StartupCheck refuses to start while migrations are pending. It is the
+	// default, so schema changes are applied deliberately with the migrate
+	// command.
+	StartupCheck StartupMode = "check"
+	// StartupUp applies pending migrations before serving.
+	StartupUp StartupMode = "up"
+	// StartupOff skips migrations entirely.
+	StartupOff StartupMode = "off"
+)
+
+func ParseStartupMode(value string) (StartupMode, error) {
+	switch mode := StartupMode(value); mode {
+	case StartupCheck, StartupUp, StartupOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown migration startup mode %q", value)
+}
+
+// Startup prepares the schema according to mode before the server starts.
+func (m *Migrator) Startup(ctx context.Context, mode StartupMode) error {
+	switch mode {
+	case StartupOff:
+		return nil
+	case StartupUp:
+		_, err := m.Up(ctx)
+		return err
+	}
+	return m.EnsureCurrent(ctx)
+}
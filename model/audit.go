@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+const (
+	AuditCreate          = "create"
+	AuditUpdate          = "update"
+	AuditDelete          = "delete"
+	AuditRestore         = "restore"
+	AuditPermanentDelete = "permanent_delete"
+
+	// PasswordChanged stands in for password values, which are never
+	// recorded.
+	PasswordChanged = "changed"
+	// UnknownActor is recorded for requests without an authenticated user.
+	UnknownActor = "anonymous"
+	// PurgeActor is recorded for users deleted when their retention ended.
+	PurgeActor = "retention-purge"
+)
+
+// AuditEntry records who changed a user, from where and how.
+type AuditEntry struct {
+	ID        string        `gorm:"primaryKey;size:36" json:"id"`
+	UserID    string        `gorm:"size:36" json:"userId"`
+	Action    string        `json:"action"`
+	Actor     string        `json:"actor"`
+	ClientIP  string        `json:"clientIp"`
+	RequestID string        `json:"requestId"`
+	Changes   []FieldChange `gorm:"serializer:json" json:"changes"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+func (AuditEntry) TableName() string {
+	return "user_audit_entries"
+}
+
+// FieldChange is the value of a user field before and after a change.
+// Password changes only have an After of PasswordChanged.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// DiffUsers returns the fields that differ between before and after. Pass an
+// empty user as before for users that are created.
+func DiffUsers(before, after *User) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range []struct {
+		name          string
+		before, after string
+	}{
+		{"name", before.Name, after.Name},
+		{"email", before.Email, after.Email},
+		{"address", before.Address, after.Address},
+	} {
+		if field.before != field.after {
+			changes = append(changes, FieldChange{Field: field.name, Before: field.before, After: field.after})
+		}
+	}
+	if before.Password != after.Password {
+		changes = append(changes, FieldChange{Field: "password", After: PasswordChanged})
+	}
+	return changes
+}
+
+// AuditFilter selects audit entries. Zero fields match every entry.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	// Field selects entries that changed this field.
+	Field string
+	Since time.Time
+	Until time.Time
+	Limit int
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// KeyStrategy selects how the primary keys of new users are generated.
+type KeyStrategy string
+
+const (
+	// KeyStrategyUUIDv4 generates random keys, which spread writes evenly
+	// across Spanner splits. It is the default.
+	KeyStrategyUUIDv4 KeyStrategy = "uuidv4"
+	// KeyStrategyUUIDv7 generates time-ordered keys. They index well in
+	// PostgreSQL or SQLite but concentrate Spanner writes on one split.
+	KeyStrategyUUIDv7 KeyStrategy = "uuidv7"
+)
+
+// IDGenerator returns new primary keys. The user repositories are given
+// the generator of the configured KeyStrategy.
+type IDGenerator func() (string, error)
+
+func ParseKeyStrategy(value string) (KeyStrategy, error) {
+	switch strategy := KeyStrategy(value); strategy {
+	case KeyStrategyUUIDv4, KeyStrategyUUIDv7:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown key strategy %q", value)
+}
+
+// Generator returns the generator of the keys of the strategy.
+func (s KeyStrategy) Generator() IDGenerator {
+	if s == KeyStrategyUUIDv7 {
+		return newUUIDv7
+	}
+	return NewID
+}
+
+// NewID returns a random primary key. It is used for the rows other than
+// users, such as events and audit entries.
+func NewID() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+func newUUIDv7() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
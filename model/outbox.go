@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventUserRegistered = "UserRegistered"
+	EventUserUpdated    = "UserUpdated"
+	EventUserDeleted    = "UserDeleted"
+	EventUserRestored   = "UserRestored"
+)
+
+// OutboxEvent is a domain event stored in the transaction of the change that
+// caused it and delivered afterwards by the outbox relay.
+type OutboxEvent struct {
+	ID            string `gorm:"primaryKey;size:36"`
+	Type          string
+	UserID        string
+	Payload       string
+	CreatedAt     time.Time
+	Attempts      int64
+	LastError     string
+	NextAttemptAt time.Time
+	PublishedAt   *time.Time
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// UserEventData is the payload of user events. It never contains the
+// password hash.
+type UserEventData struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Address string `json:"address"`
+	// Permanent is set on UserDeleted events of users that cannot be restored.
+	Permanent bool `json:"permanent,omitempty"`
+}
+
+func NewUserEvent(eventType string, user *User, permanent bool) (*OutboxEvent, error) {
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(UserEventData{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Address:   user.Address,
+		Permanent: permanent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &OutboxEvent{
+		ID:            id,
+		Type:          eventType,
+		UserID:        user.ID,
+		Payload:       string(payload),
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}, nil
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// MaxPageSize is the largest page a list may ask for.
+const MaxPageSize = 1000
+
+// Page selects up to Size users with an ID greater than After, ordered by
+// ID. A zero Size selects all of them.
+type Page struct {
+	After string
+	Size  int
+}
+
+// PageToken returns the opaque token of the page that follows the user with
+// the given ID.
+func PageToken(lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastID))
+}
+
+// ParsePageToken returns the ID a page token continues after.
+func ParsePageToken(token string) (string, error) {
+	lastID, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(lastID) == 0 {
+		return "", errors.New("invalid page token")
+	}
+	return string(lastID), nil
+}
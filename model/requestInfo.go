@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// RequestInfo describes the request a change is made for.
+type RequestInfo struct {
+	Actor     string
+	ClientIP  string
+	RequestID string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the request info of ctx. Changes made outside of a
+// request, such as by workers, have an UnknownActor.
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	if !ok || info.Actor == "" {
+		info.Actor = UnknownActor
+	}
+	return info
+}
+
+// RequestLogger adds the request ID of ctx to logger, so that all lines
+// logged for a request can be found by its ID. Outside of a request logger
+// is returned as is.
+func RequestLogger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id := RequestInfoFrom(ctx).RequestID; id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxStaleness is the oldest data a read may ask for. Spanner keeps old
+// versions for one hour by default.
+const MaxStaleness = time.Hour
+
+// Staleness says how old the data returned by a read may be. The zero value
+// is a strong read, which sees every committed write.
+type Staleness struct {
+	Duration time.Duration
+	// Bounded lets Spanner pick the newest timestamp it can serve without
+	// waiting, at most Duration ago. Otherwise data exactly Duration old is
+	// read.
+	Bounded bool
+}
+
+// ParseStaleness parses "strong" or "" for strong reads, a duration such as
+// "15s" for exact staleness and "max:15s" for bounded staleness.
+func ParseStaleness(value string) (Staleness, error) {
+	if value == "" || value == "strong" {
+		return Staleness{}, nil
+	}
+
+	raw, bounded := strings.CutPrefix(value, "max:")
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return Staleness{}, errors.New("staleness must be strong, a duration like 15s or max:15s")
+	}
+	if duration < 0 || duration > MaxStaleness {
+		return Staleness{}, errors.New("staleness must be between 0s and 1h")
+	}
+	return Staleness{Duration: duration, Bounded: bounded && duration > 0}, nil
+}
+
+func (s Staleness) IsStrong() bool {
+	return s.Duration == 0
+}
@@ -1,11 +1,30 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
-	gorm.Model
-	Name     string
-	Email    string
-	Address  string
-	Password string
+	ID string `gorm:"primaryKey;size:36" json:"id"`
+	// LegacyID is the numeric ID of users created before string keys were
+	// introduced, so that old links keep resolving.
+	LegacyID  *int64         `gorm:"uniqueIndex" json:"-"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Address   string         `json:"address"`
+	// Password is the hash of the password. It is never rendered.
+	Password string `json:"-"`
+}
+
+// UserPatch holds the fields of a user to change. Nil fields are left as
+// they are; an empty address clears it.
+type UserPatch struct {
+	Name    *string
+	Email   *string
+	Address *string
 }
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// UserChange is a committed write to a user. Old and New hold the changed
+// columns by column name; Old is empty for creates and New for deletes.
+type UserChange struct {
+	Type            string         `json:"type"`
+	UserID          string         `json:"userId"`
+	CommitTimestamp time.Time      `json:"commitTimestamp"`
+	Old             map[string]any `json:"old,omitempty"`
+	New             map[string]any `json:"new,omitempty"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// UserEventTypes are the event types webhooks can subscribe to.
+var UserEventTypes = []string{EventUserRegistered, EventUserUpdated, EventUserDeleted, EventUserRestored}
+
+// WebhookSubscription is an endpoint of a partner system that is notified
+// of user events. The secret signs every delivery and is only returned when
+// the subscription is created or the secret is rotated.
+type WebhookSubscription struct {
+	ID  string `gorm:"primaryKey;size:36" json:"id"`
+	URL string `json:"url"`
+	// EventTypes are the subscribed event types; empty means all of them.
+	EventTypes          []string   `gorm:"serializer:json" json:"eventTypes"`
+	Secret              string     `json:"secret,omitempty"`
+	ConsecutiveFailures int64      `json:"consecutiveFailures"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+	DisabledReason      string     `json:"disabledReason,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+func (s *WebhookSubscription) Enabled() bool {
+	return s.DisabledAt == nil
+}
+
+// Wants reports whether the subscription receives events of eventType.
+func (s *WebhookSubscription) Wants(eventType string) bool {
+	return len(s.EventTypes) == 0 || slices.Contains(s.EventTypes, eventType)
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a subscription. Its ID
+// is the ID of the outbox event, which receivers can use to deduplicate.
+type WebhookDelivery struct {
+	SubscriptionID string     `gorm:"primaryKey;size:36" json:"subscriptionId"`
+	ID             string     `gorm:"primaryKey;size:36" json:"id"`
+	EventType      string     `json:"eventType"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int64      `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastStatusCode int64      `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+}
@@ -0,0 +1,301 @@
+// Package openapi builds an OpenAPI 3.1 document from route descriptions
+// and the Go types the handlers bind and render.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+	// Security lists the schemes of which every operation accepts one.
+	Security []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []*ParameterObject         `json:"parameters,omitempty"`
+	RequestBody *RequestBodyObject         `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+}
+
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBodyObject struct {
+	Description string                      `json:"description,omitempty"`
+	Required    bool                        `json:"required,omitempty"`
+	Content     map[string]*MediaTypeObject `json:"content"`
+}
+
+type ResponseObject struct {
+	Description string                      `json:"description"`
+	Headers     map[string]*HeaderObject    `json:"headers,omitempty"`
+	Content     map[string]*MediaTypeObject `json:"content,omitempty"`
+}
+
+type HeaderObject struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaTypeObject struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// SecurityRequirement maps scheme names to the scopes they need.
+type SecurityRequirement map[string][]string
+
+// Route describes one handler. Path uses gin syntax relative to the
+// resource (e.g. "/:id"); DocPath overrides it in the document for routes
+// whose gin path cannot express the public shape, such as custom methods.
+type Route struct {
+	Method      string
+	Path        string
+	DocPath     string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Parameters  []Parameter
+	RequestBody *RequestBody
+	Responses   []Response
+}
+
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	// Type is a value of the parameter's Go type, e.g. uint(0) or true.
+	Type any
+	Enum []any
+}
+
+type RequestBody struct {
+	Description string
+	Required    bool
+	// Content maps a media type to a value of the Go type it is decoded into.
+	Content map[string]any
+}
+
+type Response struct {
+	Status      int
+	Description string
+	Headers     map[string]string
+	// Content maps a media type to a value of the Go type that is rendered.
+	Content map[string]any
+}
+
+// Builder accumulates operations and the schemas they reference.
+type Builder struct {
+	doc *Document
+}
+
+func NewBuilder(info Info, servers ...Server) *Builder {
+	return &Builder{doc: &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Servers:    servers,
+		Paths:      map[string]*PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}}
+}
+
+// Add documents routes mounted below prefix, e.g. "/users".
+func (b *Builder) Add(prefix string, routes ...Route) {
+	for _, route := range routes {
+		path := route.Path
+		if route.DocPath != "" {
+			path = route.DocPath
+		}
+		path = TemplatePath(prefix + path)
+
+		item, ok := b.doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			b.doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = b.operation(path, route)
+	}
+}
+
+// AddSecurityScheme documents a way to authenticate that every operation
+// accepts.
+func (b *Builder) AddSecurityScheme(name string, scheme SecurityScheme) {
+	if b.doc.Components.SecuritySchemes == nil {
+		b.doc.Components.SecuritySchemes = map[string]*SecurityScheme{}
+	}
+	b.doc.Components.SecuritySchemes[name] = &scheme
+	b.doc.Security = append(b.doc.Security, SecurityRequirement{name: {}})
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+func (b *Builder) operation(path string, route Route) *OperationObject {
+	operation := &OperationObject{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Tags:        route.Tags,
+		Responses:   map[string]*ResponseObject{},
+	}
+
+	documented := map[string]bool{}
+	for _, parameter := range route.Parameters {
+		documented[parameter.In+":"+parameter.Name] = true
+		operation.Parameters = append(operation.Parameters, b.parameter(parameter))
+	}
+	for _, name := range PathParameters(path) {
+		if !documented["path:"+name] {
+			operation.Parameters = append(operation.Parameters, &ParameterObject{
+				Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+	}
+
+	if route.RequestBody != nil {
+		operation.RequestBody = &RequestBodyObject{
+			Description: route.RequestBody.Description,
+			Required:    route.RequestBody.Required,
+			Content:     b.content(route.RequestBody.Content),
+		}
+	}
+
+	for _, response := range route.Responses {
+		object := &ResponseObject{Description: response.Description}
+		if object.Description == "" {
+			object.Description = http.StatusText(response.Status)
+		}
+		if len(response.Content) > 0 {
+			object.Content = b.content(response.Content)
+		}
+		for name, description := range response.Headers {
+			if object.Headers == nil {
+				object.Headers = map[string]*HeaderObject{}
+			}
+			object.Headers[name] = &HeaderObject{Description: description, Schema: &Schema{Type: "string"}}
+		}
+		operation.Responses[strconv.Itoa(response.Status)] = object
+	}
+
+	return operation
+}
+
+func (b *Builder) parameter(parameter Parameter) *ParameterObject {
+	schema := &Schema{Type: "string"}
+	if parameter.Type != nil {
+		schema = b.schema(reflect.TypeOf(parameter.Type))
+	}
+	schema.Enum = parameter.Enum
+	return &ParameterObject{
+		Name:        parameter.Name,
+		In:          parameter.In,
+		Description: parameter.Description,
+		Required:    parameter.Required || parameter.In == "path",
+		Schema:      schema,
+	}
+}
+
+func (b *Builder) content(content map[string]any) map[string]*MediaTypeObject {
+	media := make(map[string]*MediaTypeObject, len(content))
+	for mediaType, value := range content {
+		if schema, ok := value.(*Schema); ok {
+			media[mediaType] = &MediaTypeObject{Schema: schema}
+			continue
+		}
+		media[mediaType] = &MediaTypeObject{Schema: b.schema(reflect.TypeOf(value))}
+	}
+	return media
+}
+
+// TemplatePath converts gin path parameters (":id") to OpenAPI path
+// templates ("{id}").
+func TemplatePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name, suffix, _ := strings.Cut(segment[1:], ":")
+			segments[i] = "{" + name + "}"
+			if suffix != "" {
+				segments[i] += ":" + suffix
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// PathParameters returns the names of the templated parameters in path.
+func PathParameters(path string) []string {
+	var names []string
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(path[start:], "}")
+		if end < 0 {
+			break
+		}
+		names = append(names, path[start+1:start+end])
+		path = path[start+end+1:]
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Marshal renders a document the way it is committed and served.
+func Marshal(doc *Document) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type embedded struct {
+	ID        uint
+	DeletedAt gorm.DeletedAt
+}
+
+type widget struct {
+	embedded
+	Name     string            `json:"name"`
+	Optional *int64            `json:"optional,omitempty"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Created  time.Time         `json:"created_at"`
+	Parent   *widget           `json:"parent,omitempty"`
+	Secret   string            `json:"-"`
+	internal string
+}
+
+func TestSchemasFollowEncodingJSON(t *testing.T) {
+	builder := NewBuilder(Info{Title: "test", Version: "1"})
+	builder.Add("/widgets", Route{
+		Method:      http.MethodGet,
+		Path:        "",
+		OperationID: "listWidgets",
+		Responses:   []Response{{Status: http.StatusOK, Content: map[string]any{"application/json": []widget{}}}},
+	})
+
+	document := builder.Document()
+	response := (*document.Paths["/widgets"])["get"].Responses["200"]
+	assert.Equal(t, "OK", response.Description)
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/widget"}}, response.Content["application/json"].Schema)
+
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"ID":         {Type: "integer"},
+			"DeletedAt":  {Type: []string{"string", "null"}, Format: "date-time"},
+			"name":       {Type: "string"},
+			"optional":   {Type: "integer", Format: "int64"},
+			"tags":       {Type: "array", Items: &Schema{Type: "string"}},
+			"labels":     {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"created_at": {Type: "string", Format: "date-time"},
+			"parent":     {Ref: "#/components/schemas/widget"},
+		},
+	}, document.Components.Schemas["widget"])
+}
+
+func TestPathParametersAreAlwaysDocumented(t *testing.T) {
+	builder := NewBuilder(Info{Title: "test", Version: "1"})
+	builder.Add("/widgets", Route{
+		Method:      http.MethodPost,
+		Path:        "/:id",
+		DocPath:     "/:id:archive",
+		OperationID: "archiveWidget",
+		Parameters:  []Parameter{{Name: "force", In: "query", Type: false}},
+	})
+
+	operation := (*builder.Document().Paths["/widgets/{id}:archive"])["post"]
+	assert.Equal(t, []*ParameterObject{
+		{Name: "force", In: "query", Schema: &Schema{Type: "boolean"}},
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+	}, operation.Parameters)
+}
+
+func TestTemplatePath(t *testing.T) {
+	assert.Equal(t, "/users/{id}", TemplatePath("/users/:id"))
+	assert.Equal(t, "/users/{id}:restore", TemplatePath("/users/:id:restore"))
+	assert.Equal(t, "/files/{path}", TemplatePath("/files/*path"))
+	assert.Equal(t, []string{"id", "version"}, PathParameters("/users/{id}/versions/{version}"))
+}
@@ -0,0 +1,27 @@
+package publishers
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"errors"
+)
+
+type fanoutPublisher []interfaces.Publisher
+
+// Fanout publishes every event to all publishers. If one of them fails the
+// event is published to all of them again on the next attempt, so they have
+// to tolerate duplicates, as every publisher does.
+func Fanout(publishers ...interfaces.Publisher) interfaces.Publisher {
+	return fanoutPublisher(publishers)
+}
+
+func (f fanoutPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	var errs []error
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
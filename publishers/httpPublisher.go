@@ -0,0 +1,78 @@
+// Package publishers delivers outbox events to message buses.
+package publishers
+
+import (
+	"bytes"
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Envelope is the JSON body of a published event.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	UserID     string          `json:"userId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// EnvelopeBody returns the JSON envelope of an event.
+func EnvelopeBody(event model.OutboxEvent) ([]byte, error) {
+	return json.Marshal(Envelope{
+		ID:         event.ID,
+		Type:       event.Type,
+		UserID:     event.UserID,
+		OccurredAt: event.CreatedAt,
+		Data:       json.RawMessage(event.Payload),
+	})
+}
+
+type httpPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPPublisher posts every event to url. A nil client uses
+// http.DefaultClient.
+func NewHTTPPublisher(url string, client *http.Client) interfaces.Publisher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpPublisher{url: url, client: client}
+}
+
+// Publish posts the event and fails unless the webhook answers with a 2xx
+// status. The event ID is sent as X-Event-ID so the receiver can deduplicate
+// redeliveries.
+func (p *httpPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	body, err := EnvelopeBody(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
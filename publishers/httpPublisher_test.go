@@ -0,0 +1,50 @@
+package publishers
+
+import (
+	"context"
+	"crudspanner/model"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = model.OutboxEvent{
+	ID:        "event-1",
+	Type:      model.EventUserRegistered,
+	UserID:    "user-1",
+	Payload:   `{"id":"user-1","name":"John","email":"john@example.com","address":""}`,
+	CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func TestHTTPPublisher_Publish(t *testing.T) {
+	var envelope Envelope
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "event-1", r.Header.Get("X-Event-ID"))
+		assert.Equal(t, model.EventUserRegistered, r.Header.Get("X-Event-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&envelope))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	err := NewHTTPPublisher(server.URL, nil).Publish(context.Background(), testEvent)
+	assert.NoError(t, err)
+	assert.Equal(t, "event-1", envelope.ID)
+	assert.Equal(t, "user-1", envelope.UserID)
+	assert.Equal(t, testEvent.CreatedAt, envelope.OccurredAt)
+	assert.JSONEq(t, testEvent.Payload, string(envelope.Data))
+}
+
+func TestHTTPPublisher_PublishFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewHTTPPublisher(server.URL, nil).Publish(context.Background(), testEvent)
+	assert.EqualError(t, err, "webhook answered 503 Service Unavailable")
+}
@@ -0,0 +1,32 @@
+package publishers
+
+import (
+	"context"
+	"crudspanner/model"
+	"sync"
+)
+
+// MemoryPublisher keeps published events in memory, for tests and local
+// development.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []model.OutboxEvent
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the events published so far, oldest first.
+func (p *MemoryPublisher) Events() []model.OutboxEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]model.OutboxEvent(nil), p.events...)
+}
@@ -0,0 +1,56 @@
+package publishers
+
+import (
+	"context"
+	"crudspanner/model"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWebhook(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	// echo -n '1700000000.{"id":"event-1"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=01017e2b3bf7b2f3c53c64a662fb4ee9c60a8a998e81d1b19dcfd0aa2de23880",
+		SignWebhook("secret", timestamp, []byte(`{"id":"event-1"}`)),
+	)
+}
+
+func TestSendSignedWebhook(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"event-1"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		assert.Equal(t, "event-1", r.Header.Get(WebhookIDHeader))
+		assert.Equal(t, SignWebhook("secret", time.Unix(timestamp, 0), received), r.Header.Get(WebhookSignatureHeader))
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	status, err := SendSignedWebhook(context.Background(), http.DefaultClient, server.URL, "secret", "event-1", body, now)
+	assert.Equal(t, http.StatusGone, status)
+	assert.EqualError(t, err, "webhook answered 410 Gone")
+}
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	return errors.New("unavailable")
+}
+
+func TestFanoutPublishesToEveryPublisher(t *testing.T) {
+	first, second := NewMemoryPublisher(), NewMemoryPublisher()
+
+	err := Fanout(first, failingPublisher{}, second).Publish(context.Background(), testEvent)
+	assert.EqualError(t, err, "unavailable")
+	assert.Len(t, first.Events(), 1)
+	assert.Len(t, second.Events(), 1)
+}
@@ -0,0 +1,65 @@
+package publishers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookClientRefusesNonPublicAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the webhook reached a loopback address")
+	}))
+	defer server.Close()
+
+	_, err := SendSignedWebhook(context.Background(), NewWebhookClient(time.Second), server.URL, "secret", "event-1", []byte(`{}`), time.Now())
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Error("the redirect was followed")
+		}
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	client := NewWebhookClient(time.Second)
+	// The test server listens on loopback, which the client refuses.
+	client.Transport = http.DefaultTransport
+	status, err := SendSignedWebhook(context.Background(), client, server.URL, "secret", "event-1", []byte(`{}`), time.Now())
+	assert.Equal(t, http.StatusTemporaryRedirect, status)
+	assert.Error(t, err)
+}
+
+func TestIsPublic(t *testing.T) {
+	for address, public := range map[string]bool{
+		"8.8.8.8":                true,
+		"2001:4860:4860::8888":   true,
+		"127.0.0.1":              false,
+		"::1":                    false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"fd00:ec2::254":          false,
+		"fe80::1":                false,
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"::":                     false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"224.0.0.1":              false,
+	} {
+		ip, err := netip.ParseAddr(address)
+		require.NoError(t, err)
+		assert.Equal(t, public, isPublic(ip), address)
+	}
+}
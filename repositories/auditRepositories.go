@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+
+	"gorm.io/gorm"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) interfaces.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) List(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	query := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Field != "" {
+		// changes is a JSON array of field changes
+		query = query.Where("changes LIKE ?", `%"field":"`+filter.Field+`"%`)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var entries []model.AuditEntry
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
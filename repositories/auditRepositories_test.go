@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditRepositoryListAppliesFilter(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	defer func() {
+		sqlDB, _ := mockDb.DB()
+		sqlDB.Close()
+	}()
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT \\* FROM `user_audit_entries` WHERE user_id = \\? AND actor = \\? AND changes LIKE \\? AND created_at >= \\? ORDER BY created_at DESC LIMIT \\?").
+		WithArgs(testID, "admin@example.com", `%"field":"email"%`, since, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "action", "changes"}).
+			AddRow("entry-1", testID, model.AuditUpdate, `[{"field":"email","before":"a@example.com","after":"b@example.com"}]`))
+
+	entries, err := NewAuditRepository(mockDb).List(context.Background(), testID, model.AuditFilter{
+		Actor: "admin@example.com", Field: "email", Since: since, Limit: 10,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, []model.FieldChange{{Field: "email", Before: "a@example.com", After: "b@example.com"}}, entries[0].Changes)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
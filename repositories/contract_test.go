@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/emulator"
+	"crudspanner/interfaces"
+	"crudspanner/migrations"
+	"crudspanner/model"
+	"crudspanner/repositories/repositorytest"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannergorm "github.com/googleapis/go-gorm-spanner"
+	_ "github.com/googleapis/go-sql-spanner"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMemoryUserRepositoryContract(t *testing.T) {
+	repositorytest.RunUserRepositoryTests(t, func(t *testing.T) interfaces.UserRepository {
+		return NewMemoryUserRepository(model.NewID)
+	})
+}
+
+func TestSQLiteUserRepositoryContract(t *testing.T) {
+	repositorytest.RunUserRepositoryTests(t, func(t *testing.T) interfaces.UserRepository {
+		db := openSQLite(t, filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		// SQLite allows a single writer, so transactions are serialized on
+		// one connection.
+		sqlDB.SetMaxOpenConns(1)
+		t.Cleanup(func() { sqlDB.Close() })
+		migrate(t, db)
+		return NewUserRepository(db, zap.NewNop(), model.NewID)
+	})
+}
+
+// TestPostgresUserRepositoryContract runs in a fresh schema of the database
+// at POSTGRES_TEST_URL, a postgres:// URL, and is skipped without one.
+func TestPostgresUserRepositoryContract(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL is not set")
+	}
+	admin, err := gorm.Open(postgres.Open(url), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	repositorytest.RunUserRepositoryTests(t, func(t *testing.T) interfaces.UserRepository {
+		schema := fmt.Sprintf("contract_%d", time.Now().UnixNano())
+		require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+		t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+		separator := "?"
+		if strings.Contains(url, "?") {
+			separator = "&"
+		}
+		db, err := gorm.Open(postgres.Open(url+separator+"search_path="+schema), &gorm.Config{Logger: logger.Discard})
+		require.NoError(t, err)
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+		migrate(t, db)
+		return NewUserRepository(db, zap.NewNop(), model.NewID)
+	})
+}
+
+// TestSpannerUserRepositoryContract runs against a fresh database on the
+// Spanner emulator, so it only runs when SPANNER_EMULATOR_HOST is set.
+func TestSpannerUserRepositoryContract(t *testing.T) {
+	if emulator.Host() == "" {
+		t.Skip("SPANNER_EMULATOR_HOST is not set")
+	}
+	ctx := context.Background()
+
+	repositorytest.RunUserRepositoryTests(t, func(t *testing.T) interfaces.UserRepository {
+		name := fmt.Sprintf("projects/test-project/instances/test-instance/databases/contract-%d", time.Now().UnixNano()%1e12)
+		require.NoError(t, emulator.EnsureDatabase(ctx, name))
+		t.Cleanup(func() { emulator.DropDatabase(context.Background(), name) })
+
+		db, err := gorm.Open(spannergorm.New(spannergorm.Config{DriverName: "spanner", DSN: name}), &gorm.Config{Logger: logger.Discard})
+		require.NoError(t, err)
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+		migrate(t, db)
+
+		client, err := spanner.NewClient(ctx, name)
+		require.NoError(t, err)
+		t.Cleanup(client.Close)
+		return NewSpannerUserRepository(client, zap.NewNop(), model.NewID)
+	})
+}
+
+// migrate applies the migrations of the dialect of db.
+func migrate(t *testing.T, db *gorm.DB) {
+	schema, err := migrations.ForDialect(db.Dialector.Name())
+	require.NoError(t, err)
+	_, err = migrations.New(db, schema).Up(context.Background())
+	require.NoError(t, err)
+}
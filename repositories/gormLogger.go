@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// slowStatement is how long a statement may take before it is logged as a
+// warning.
+const slowStatement = 500 * time.Millisecond
+
+// gormLogger sends the log of gorm to zap. Statements are logged without
+// their parameters, which may hold personal data and password hashes. All
+// statements are logged at debug level, failed ones as errors and slow ones
+// as warnings. Missing records are not failures.
+type gormLogger struct {
+	logger *zap.Logger
+}
+
+// NewGormLogger logs the statements of gorm to logger.
+func NewGormLogger(logger *zap.Logger) gormlogger.Interface {
+	return gormLogger{logger: logger.WithOptions(zap.WithCaller(false))}
+}
+
+// LogMode is ignored; the level of the zap logger decides what is logged.
+func (l gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l gormLogger) Info(ctx context.Context, message string, args ...interface{}) {
+	model.RequestLogger(ctx, l.logger).Info(fmt.Sprintf(message, args...))
+}
+
+func (l gormLogger) Warn(ctx context.Context, message string, args ...interface{}) {
+	model.RequestLogger(ctx, l.logger).Warn(fmt.Sprintf(message, args...))
+}
+
+func (l gormLogger) Error(ctx context.Context, message string, args ...interface{}) {
+	model.RequestLogger(ctx, l.logger).Error(fmt.Sprintf(message, args...))
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	level, message := zap.DebugLevel, "Statement"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, context.Canceled):
+		level, message = zap.ErrorLevel, "Statement failed"
+	case elapsed > slowStatement:
+		level, message = zap.WarnLevel, "Slow statement"
+	default:
+		err = nil
+	}
+	if !l.logger.Core().Enabled(level) {
+		return
+	}
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		// gorm's own frames would hide the repository that ran the statement
+		zap.String("source", utils.FileWithLineNum()),
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.Duration("elapsed", elapsed),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	model.RequestLogger(ctx, l.logger).Log(level, message, fields...)
+}
+
+// ParamsFilter drops the parameters of statements before they are logged.
+func (l gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+)
+
+func TestGormLoggerOmitsParameters(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	db := openSQLite(t, ":memory:", &gorm.Config{Logger: NewGormLogger(zap.New(core))})
+	require.NoError(t, db.AutoMigrate(&model.User{}))
+	logs.TakeAll()
+
+	user := model.User{Name: "Ada", Email: "ada@example.com", Password: "secret-hash"}
+	require.NoError(t, db.Create(&user).Error)
+	ctx := model.WithRequestInfo(context.Background(), model.RequestInfo{RequestID: "req-1"})
+	err := db.WithContext(ctx).Where("email = ?", "grace@example.com").First(&model.User{}).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	db.Exec("SELECT * FROM missing_table WHERE email = ?", "ada@example.com")
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		sql := entry.ContextMap()["sql"].(string)
+		assert.NotContains(t, sql, "example.com")
+		assert.NotContains(t, sql, "secret-hash")
+		assert.Contains(t, entry.ContextMap()["source"], "gormLogger_test.go")
+	}
+	assert.Equal(t, zapcore.DebugLevel, entries[1].Level, "missing records are not failures")
+	assert.Equal(t, "req-1", entries[1].ContextMap()["request_id"])
+	assert.NotContains(t, entries[0].ContextMap(), "request_id")
+	assert.Equal(t, zapcore.ErrorLevel, entries[2].Level)
+	assert.Equal(t, "Statement failed", entries[2].Message)
+}
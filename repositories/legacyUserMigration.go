@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// legacyUser is a row of the users table as it was before string keys,
+// when the primary key was a sequential integer.
+type legacyUser struct {
+	ID        int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+	Name      string
+	Email     string
+	Address   string
+	Password  string
+}
+
+// CopyLegacyUsers copies the rows of the numeric-key table legacyTable into
+// the users table in batches. Every copied user gets a new key and keeps its
+// old ID as LegacyID, so links with numeric IDs keep resolving. Rows that
+// were copied by an earlier run are skipped, so the copy can be resumed.
+func CopyLegacyUsers(ctx context.Context, db *gorm.DB, legacyTable string, batchSize int, newID model.IDGenerator) (int64, error) {
+	db = db.WithContext(ctx)
+
+	var lastID int64
+	if err := db.Unscoped().Model(&model.User{}).Select("COALESCE(MAX(legacy_id), 0)").Scan(&lastID).Error; err != nil {
+		return 0, err
+	}
+
+	var copied int64
+	for {
+		var batch []legacyUser
+		if err := db.Table(legacyTable).Unscoped().Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&batch).Error; err != nil {
+			return copied, err
+		}
+		if len(batch) == 0 {
+			return copied, nil
+		}
+
+		users := make([]model.User, len(batch))
+		for i, legacy := range batch {
+			id, err := newID()
+			if err != nil {
+				return copied, err
+			}
+			legacyID := legacy.ID
+			users[i] = model.User{
+				ID:        id,
+				LegacyID:  &legacyID,
+				CreatedAt: legacy.CreatedAt,
+				UpdatedAt: legacy.UpdatedAt,
+				DeletedAt: legacy.DeletedAt,
+				Name:      legacy.Name,
+				Email:     legacy.Email,
+				Address:   legacy.Address,
+				Password:  legacy.Password,
+			}
+		}
+		if err := db.Create(&users).Error; err != nil {
+			return copied, err
+		}
+
+		copied += int64(len(batch))
+		lastID = batch[len(batch)-1].ID
+	}
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyLegacyUsers(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectQuery("^SELECT COALESCE\\(MAX\\(legacy_id\\), 0\\) FROM `users`$").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectQuery("^SELECT \\* FROM `users_legacy` WHERE id > \\? ORDER BY id LIMIT \\?$").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).
+			AddRow(2, "testName", "testEmail").
+			AddRow(3, "testName2", "testEmail2"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `users`").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `users_legacy` WHERE id > \\? ORDER BY id LIMIT \\?$").
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+
+	copied, err := CopyLegacyUsers(context.Background(), mockDb, "users_legacy", 2, model.NewID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), copied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"crudspanner/model"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The outbox, webhook and audit repositories of an in-memory database share
+// the store of its user repository, just as their tables share a database.
+
+type memoryOutboxRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.store.update(func(data *memoryData) error {
+		for _, event := range data.events {
+			if event.PublishedAt == nil && !event.NextAttemptAt.After(now) {
+				events = append(events, event)
+			}
+		}
+		slices.SortFunc(events, func(a, b model.OutboxEvent) int {
+			return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), cmp.Compare(a.ID, b.ID))
+		})
+		events = events[:min(len(events), limit)]
+		for _, event := range events {
+			event.NextAttemptAt = now.Add(lease)
+			data.events[event.ID] = event
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (r *memoryOutboxRepository) MarkPublished(ctx context.Context, id string, publishedAt time.Time) error {
+	return r.store.update(func(data *memoryData) error {
+		event, ok := data.events[id]
+		if ok {
+			event.PublishedAt = &publishedAt
+			data.events[id] = event
+		}
+		return nil
+	})
+}
+
+func (r *memoryOutboxRepository) MarkFailed(ctx context.Context, id string, attempts int64, lastError string, nextAttemptAt time.Time) error {
+	return r.store.update(func(data *memoryData) error {
+		event, ok := data.events[id]
+		if ok {
+			event.Attempts = attempts
+			event.LastError = lastError
+			event.NextAttemptAt = nextAttemptAt
+			data.events[id] = event
+		}
+		return nil
+	})
+}
+
+func (r *memoryOutboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var deleted int64
+	err := r.store.update(func(data *memoryData) error {
+		for id, event := range data.events {
+			if deleted == int64(limit) {
+				break
+			}
+			if event.PublishedAt != nil && event.PublishedAt.Before(before) {
+				delete(data.events, id)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+type memoryWebhookRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryWebhookRepository) CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return r.store.update(func(data *memoryData) error {
+		if _, ok := data.subscriptions[subscription.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+		now := time.Now().UTC()
+		subscription.CreatedAt = now
+		subscription.UpdatedAt = now
+		data.subscriptions[subscription.ID] = *subscription
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	var found model.WebhookSubscription
+	err := r.store.update(func(data *memoryData) error {
+		subscription, ok := data.subscriptions[id]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		found = subscription
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (r *memoryWebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	subscriptions := []model.WebhookSubscription{}
+	err := r.store.update(func(data *memoryData) error {
+		for _, subscription := range data.subscriptions {
+			subscriptions = append(subscriptions, subscription)
+		}
+		return nil
+	})
+	slices.SortFunc(subscriptions, func(a, b model.WebhookSubscription) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return subscriptions, err
+}
+
+func (r *memoryWebhookRepository) SaveSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return r.store.update(func(data *memoryData) error {
+		subscription.UpdatedAt = time.Now().UTC()
+		data.subscriptions[subscription.ID] = *subscription
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	return r.store.update(func(data *memoryData) error {
+		if _, ok := data.subscriptions[id]; !ok {
+			return gorm.ErrRecordNotFound
+		}
+		delete(data.subscriptions, id)
+		for key := range data.deliveries {
+			if key.subscriptionID == id {
+				delete(data.deliveries, key)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	return r.store.update(func(data *memoryData) error {
+		for _, delivery := range deliveries {
+			key := deliveryKey{delivery.SubscriptionID, delivery.ID}
+			if _, ok := data.deliveries[key]; !ok {
+				data.deliveries[key] = delivery
+			}
+		}
+		return nil
+	})
+}
+
+func (r *memoryWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.store.update(func(data *memoryData) error {
+		for _, delivery := range data.deliveries {
+			if delivery.Status == model.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		slices.SortFunc(deliveries, func(a, b model.WebhookDelivery) int {
+			return a.NextAttemptAt.Compare(b.NextAttemptAt)
+		})
+		deliveries = deliveries[:min(len(deliveries), limit)]
+		for _, delivery := range deliveries {
+			delivery.NextAttemptAt = now.Add(lease)
+			data.deliveries[deliveryKey{delivery.SubscriptionID, delivery.ID}] = delivery
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+func (r *memoryWebhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, disableAfter int64) (bool, error) {
+	disabled := false
+	err := r.store.update(func(data *memoryData) error {
+		key := deliveryKey{delivery.SubscriptionID, delivery.ID}
+		if _, ok := data.deliveries[key]; !ok {
+			return gorm.ErrRecordNotFound
+		}
+		data.deliveries[key] = *delivery
+
+		subscription, ok := data.subscriptions[delivery.SubscriptionID]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		if delivery.Status == model.DeliverySucceeded {
+			subscription.ConsecutiveFailures = 0
+		} else if subscription.Enabled() {
+			subscription.ConsecutiveFailures++
+			if subscription.ConsecutiveFailures >= disableAfter {
+				disabled = true
+				now := time.Now().UTC()
+				subscription.DisabledAt = &now
+				subscription.DisabledReason = fmt.Sprintf("%d deliveries failed in a row", subscription.ConsecutiveFailures)
+			}
+		}
+		data.subscriptions[subscription.ID] = subscription
+		return nil
+	})
+	return disabled, err
+}
+
+func (r *memoryWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	deliveries := []model.WebhookDelivery{}
+	err := r.store.update(func(data *memoryData) error {
+		for key, delivery := range data.deliveries {
+			if key.subscriptionID == subscriptionID {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(deliveries, func(a, b model.WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return deliveries[:min(len(deliveries), limit)], err
+}
+
+type memoryAuditRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryAuditRepository) List(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	err := r.store.update(func(data *memoryData) error {
+		for _, entry := range data.audit {
+			if entry.UserID == userID && matchesAuditFilter(entry, filter) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	slices.SortStableFunc(entries, func(a, b model.AuditEntry) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if filter.Limit > 0 {
+		entries = entries[:min(len(entries), filter.Limit)]
+	}
+	return entries, err
+}
+
+func matchesAuditFilter(entry model.AuditEntry, filter model.AuditFilter) bool {
+	switch {
+	case filter.Actor != "" && entry.Actor != filter.Actor,
+		filter.Action != "" && entry.Action != filter.Action,
+		!filter.Since.IsZero() && entry.CreatedAt.Before(filter.Since),
+		!filter.Until.IsZero() && !entry.CreatedAt.Before(filter.Until):
+		return false
+	}
+	if filter.Field == "" {
+		return true
+	}
+	return slices.ContainsFunc(entry.Changes, func(change model.FieldChange) bool {
+		return change.Field == filter.Field
+	})
+}
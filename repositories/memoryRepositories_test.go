@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMemoryUserRepositorySoftDelete(t *testing.T) {
+	repo := NewMemoryUserRepository(model.NewID)
+	ctx := context.Background()
+
+	user, err := repo.Create(ctx, &model.User{Name: "John", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	require.NoError(t, repo.Delete(ctx, user.ID))
+	_, err = repo.Get(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = repo.FindByEmail(ctx, "john@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, user.ID), gorm.ErrRecordNotFound)
+
+	deleted, err := repo.FindDeleted(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.DeletedAt.Valid)
+
+	restored, err := repo.Restore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+	_, err = repo.Restore(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	require.NoError(t, repo.HardDelete(ctx, user.ID))
+	_, err = repo.FindDeleted(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestMemoryUserRepositoryUsesKeyStrategy(t *testing.T) {
+	repo := NewMemoryUserRepository(model.KeyStrategyUUIDv7.Generator())
+	ctx := context.Background()
+
+	user, err := repo.Create(ctx, &model.User{Name: "John", Email: "john@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), uuid.MustParse(user.ID).Version())
+
+	err = repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		user, err = repo.Create(ctx, &model.User{Name: "Jane", Email: "jane@example.com"})
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), uuid.MustParse(user.ID).Version(), "transactions use the same generator")
+}
+
+func TestMemoryUserRepositoryResolvesLegacyIDs(t *testing.T) {
+	repo := NewMemoryUserRepository(model.NewID)
+	ctx := context.Background()
+
+	legacyID := int64(42)
+	user, err := repo.Create(ctx, &model.User{LegacyID: &legacyID, Name: "John"})
+	require.NoError(t, err)
+
+	found, err := repo.Get(ctx, "42")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = repo.Create(ctx, &model.User{LegacyID: &legacyID})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	_, err = repo.Create(ctx, &model.User{ID: user.ID})
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+func TestMemoryUserRepositoryRollsBackFailedTransactions(t *testing.T) {
+	repos := NewMemoryRepositories(model.NewID)
+	ctx := context.Background()
+
+	err := repos.Users.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		user, err := repo.Create(ctx, &model.User{Name: "John"})
+		if err != nil {
+			return err
+		}
+		if err := repo.AddEvent(ctx, &model.OutboxEvent{ID: "event-1", UserID: user.ID}); err != nil {
+			return err
+		}
+		return errors.New("validation failed")
+	})
+	assert.EqualError(t, err, "validation failed")
+
+	users, err := repos.Users.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	events, err := repos.Outbox.Claim(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestMemoryRepositoriesShareData(t *testing.T) {
+	repos := NewMemoryRepositories(model.NewID)
+	ctx := context.Background()
+
+	err := repos.Users.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		if err := repo.AddEvent(ctx, &model.OutboxEvent{ID: "event-1", NextAttemptAt: time.Now()}); err != nil {
+			return err
+		}
+		return repo.AddAuditEntry(ctx, &model.AuditEntry{
+			ID: "entry-1", UserID: "user-1", Action: model.AuditUpdate,
+			Changes: []model.FieldChange{{Field: "email", Before: "a@example.com", After: "b@example.com"}},
+		})
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	events, err := repos.Outbox.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	events, err = repos.Outbox.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events, "claimed events are leased")
+
+	entries, err := repos.Audit.List(ctx, "user-1", model.AuditFilter{Field: "email"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	entries, err = repos.Audit.List(ctx, "user-1", model.AuditFilter{Field: "name"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"crudspanner/model"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// memoryStore holds the data of the in-memory repositories. A single mutex
+// serializes all access, which is plenty for local development and tests.
+type memoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+}
+
+type memoryData struct {
+	users         map[string]model.User
+	events        map[string]model.OutboxEvent
+	audit         []model.AuditEntry
+	subscriptions map[string]model.WebhookSubscription
+	deliveries    map[deliveryKey]model.WebhookDelivery
+}
+
+type deliveryKey struct {
+	subscriptionID string
+	id             string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: &memoryData{
+		users:         map[string]model.User{},
+		events:        map[string]model.OutboxEvent{},
+		subscriptions: map[string]model.WebhookSubscription{},
+		deliveries:    map[deliveryKey]model.WebhookDelivery{},
+	}}
+}
+
+func (s *memoryStore) update(fn func(data *memoryData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.data)
+}
+
+// clone copies the data for a transaction. Values are copied, so the
+// transaction must replace stored values rather than modify what they point
+// to.
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		users:         maps.Clone(d.users),
+		events:        maps.Clone(d.events),
+		audit:         slices.Clone(d.audit),
+		subscriptions: maps.Clone(d.subscriptions),
+		deliveries:    maps.Clone(d.deliveries),
+	}
+}
@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryUserRepository keeps users in memory with the semantics of the
+// database repository: deletes are soft, lookups skip deleted users unless
+// they ask for them, numeric IDs resolve legacy IDs and missing users are
+// reported as gorm.ErrRecordNotFound.
+type memoryUserRepository struct {
+	store *memoryStore
+	newID model.IDGenerator
+	// tx is the data of the transaction the repository belongs to.
+	tx *memoryData
+}
+
+// NewMemoryUserRepository returns an empty in-memory user repository that
+// generates the keys of new users with newID.
+func NewMemoryUserRepository(newID model.IDGenerator) interfaces.UserRepository {
+	return &memoryUserRepository{store: newMemoryStore(), newID: newID}
+}
+
+func (r *memoryUserRepository) do(fn func(data *memoryData) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.store.update(fn)
+}
+
+// findUser returns the stored user with the given key, deleted or not.
+func (data *memoryData) findUser(id string) (model.User, bool) {
+	if legacyID, err := strconv.ParseInt(id, 10, 64); err == nil {
+		for _, user := range data.users {
+			if user.LegacyID != nil && *user.LegacyID == legacyID {
+				return user, true
+			}
+		}
+		return model.User{}, false
+	}
+	user, ok := data.users[id]
+	return user, ok
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	err := r.do(func(data *memoryData) error {
+		if user.ID == "" {
+			id, err := r.newID()
+			if err != nil {
+				return err
+			}
+			user.ID = id
+		}
+		if _, ok := data.users[user.ID]; ok {
+			return gorm.ErrDuplicatedKey
+		}
+		if user.LegacyID != nil {
+			if _, ok := data.findUser(strconv.FormatInt(*user.LegacyID, 10)); ok {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+		now := time.Now().UTC()
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = now
+		}
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = now
+		}
+		data.users[user.ID] = *user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	var found model.User
+	err := r.do(func(data *memoryData) error {
+		user, ok := data.findUser(id)
+		if !ok || user.DeletedAt.Valid {
+			return gorm.ErrRecordNotFound
+		}
+		found = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (r *memoryUserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	return r.list(func(user model.User) bool { return !user.DeletedAt.Valid })
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, page model.Page) ([]model.User, error) {
+	users, err := r.list(func(user model.User) bool {
+		return !user.DeletedAt.Valid && user.ID > page.After
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(users, func(a, b model.User) int { return cmp.Compare(a.ID, b.ID) })
+	if page.Size > 0 && len(users) > page.Size {
+		users = users[:page.Size]
+	}
+	return users, nil
+}
+
+// list returns the users that match, oldest first.
+func (r *memoryUserRepository) list(match func(user model.User) bool) ([]model.User, error) {
+	users := []model.User{}
+	err := r.do(func(data *memoryData) error {
+		for _, user := range data.users {
+			if match(user) {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(users, func(a, b model.User) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return users, err
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id string) error {
+	return r.do(func(data *memoryData) error {
+		user, ok := data.findUser(id)
+		if !ok || user.DeletedAt.Valid {
+			return gorm.ErrRecordNotFound
+		}
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+		data.users[user.ID] = user
+		return nil
+	})
+}
+
+// Update stores user in place of the user with the given key, like Save of
+// the database repository.
+func (r *memoryUserRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	err := r.do(func(data *memoryData) error {
+		existing, ok := data.findUser(id)
+		if !ok || existing.DeletedAt.Valid {
+			return gorm.ErrRecordNotFound
+		}
+		user.ID = existing.ID
+		user.LegacyID = existing.LegacyID
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = existing.CreatedAt
+		}
+		user.UpdatedAt = time.Now().UTC()
+		data.users[user.ID] = *user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	users, err := r.list(func(user model.User) bool { return !user.DeletedAt.Valid && user.Email == email })
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *memoryUserRepository) GetDeleted(ctx context.Context) ([]model.User, error) {
+	return r.list(func(user model.User) bool { return user.DeletedAt.Valid })
+}
+
+func (r *memoryUserRepository) FindDeleted(ctx context.Context, id string) (*model.User, error) {
+	var found model.User
+	err := r.do(func(data *memoryData) error {
+		user, ok := data.findUser(id)
+		if !ok || !user.DeletedAt.Valid {
+			return gorm.ErrRecordNotFound
+		}
+		found = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	var restored model.User
+	err := r.do(func(data *memoryData) error {
+		user, ok := data.findUser(id)
+		if !ok || !user.DeletedAt.Valid {
+			return gorm.ErrRecordNotFound
+		}
+		user.DeletedAt = gorm.DeletedAt{}
+		user.UpdatedAt = time.Now().UTC()
+		data.users[user.ID] = user
+		restored = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+func (r *memoryUserRepository) HardDelete(ctx context.Context, id string) error {
+	return r.do(func(data *memoryData) error {
+		user, ok := data.findUser(id)
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		delete(data.users, user.ID)
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]model.User, error) {
+	users, err := r.list(func(user model.User) bool { return user.DeletedAt.Valid && user.DeletedAt.Time.Before(before) })
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(users, func(a, b model.User) int {
+		return a.DeletedAt.Time.Compare(b.DeletedAt.Time)
+	})
+	return users[:min(len(users), limit)], nil
+}
+
+func (r *memoryUserRepository) AddEvent(ctx context.Context, event *model.OutboxEvent) error {
+	return r.do(func(data *memoryData) error {
+		data.events[event.ID] = *event
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) AddAuditEntry(ctx context.Context, entry *model.AuditEntry) error {
+	return r.do(func(data *memoryData) error {
+		data.audit = append(data.audit, *entry)
+		return nil
+	})
+}
+
+// Transaction runs fn on a copy of the data that replaces the stored data
+// only if fn succeeds. Other callers wait until the transaction is done, so
+// fn must not use any repository other than the one it is given.
+func (r *memoryUserRepository) Transaction(ctx context.Context, fn func(repo interfaces.UserRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	return r.store.update(func(data *memoryData) error {
+		tx := data.clone()
+		if err := fn(&memoryUserRepository{store: r.store, newID: r.newID, tx: tx}); err != nil {
+			return err
+		}
+		*data = *tx
+		return nil
+	})
+}
+
+// ReadOnly always reads the latest data, which is as fresh as any staleness
+// allows.
+func (r *memoryUserRepository) ReadOnly(ctx context.Context, staleness model.Staleness, fn func(repo interfaces.UserRepository) error) (time.Time, error) {
+	return time.Time{}, fn(r)
+}
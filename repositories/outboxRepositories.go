@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) interfaces.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("published_at IS NULL AND next_attempt_at <= ?", now).
+			Order("next_attempt_at").Limit(limit).Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		return tx.Model(&model.OutboxEvent{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, id string, publishedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.OutboxEvent{}).Where("id = ?", id).Update("published_at", publishedAt).Error
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id string, attempts int64, lastError string, nextAttemptAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.OutboxEvent{}).Where("id = ?", id).Updates(map[string]any{
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	}).Error
+}
+
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	db := r.db.WithContext(ctx)
+	published := db.Model(&model.OutboxEvent{}).Select("id").Where("published_at < ?", before).Limit(limit)
+	result := db.Where("id IN (?)", published).Delete(&model.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
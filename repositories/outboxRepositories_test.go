@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxRepository_Claim(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	defer func() {
+		sqlDB, _ := mockDb.DB()
+		sqlDB.Close()
+	}()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `outbox_events` WHERE published_at IS NULL AND next_attempt_at <= \\? ORDER BY next_attempt_at LIMIT \\?").
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "attempts"}).AddRow("event-1", model.EventUserRegistered, 0))
+	mock.ExpectExec("UPDATE `outbox_events` SET `next_attempt_at`=\\? WHERE id IN \\(\\?\\)").
+		WithArgs(now.Add(time.Minute), "event-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	events, err := NewOutboxRepository(mockDb).Claim(context.Background(), now, time.Minute, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "event-1", events[0].ID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+func TestOutboxRepository_DeletePublishedBefore(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	defer func() {
+		sqlDB, _ := mockDb.DB()
+		sqlDB.Close()
+	}()
+
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `outbox_events` WHERE id IN \\(SELECT `id` FROM `outbox_events` WHERE published_at < \\? LIMIT \\?\\)").
+		WithArgs(before, 100).
+		WillReturnResult(sqlmock.NewResult(0, 42))
+	mock.ExpectCommit()
+
+	deleted, err := NewOutboxRepository(mockDb).DeletePublishedBefore(context.Background(), before, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSpannerOutboxRepository_DeletePublishedBefore(t *testing.T) {
+	server, client := mockSpanner(t)
+	require.NoError(t, server.TestSpanner.PutStatementResult("DELETE FROM outbox_events WHERE id IN (SELECT id FROM outbox_events WHERE published_at < @before LIMIT @limit)", updateCount(42)))
+
+	deleted, err := NewSpannerOutboxRepository(client).DeletePublishedBefore(context.Background(), time.Now(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), deleted)
+}
+
+func TestMemoryOutboxRepository_DeletePublishedBefore(t *testing.T) {
+	repos := NewMemoryRepositories(model.NewID)
+	ctx := context.Background()
+	now := time.Now()
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		require.NoError(t, repos.Users.AddEvent(ctx, &model.OutboxEvent{ID: id, NextAttemptAt: now}))
+	}
+	require.NoError(t, repos.Outbox.MarkPublished(ctx, "event-1", now.Add(-2*time.Hour)))
+	require.NoError(t, repos.Outbox.MarkPublished(ctx, "event-2", now))
+
+	deleted, err := repos.Outbox.DeletePublishedBefore(ctx, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	events, err := repos.Outbox.Claim(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "unpublished events are kept")
+	assert.Equal(t, "event-3", events[0].ID)
+	deleted, err = repos.Outbox.DeletePublishedBefore(ctx, now.Add(time.Second), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted, "only event-2 was left to delete")
+}
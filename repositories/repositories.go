@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"errors"
+
+	"cloud.google.com/go/spanner"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Repositories are the repositories of one database.
+type Repositories struct {
+	Users    interfaces.UserRepository
+	Outbox   interfaces.OutboxRepository
+	Webhooks interfaces.WebhookRepository
+	Audit    interfaces.AuditRepository
+	// Changes is nil for databases without a change stream.
+	Changes interfaces.UserChangeSource
+	// DB is the SQL database, nil for the in-memory repositories.
+	DB *gorm.DB
+
+	closers []func() error
+}
+
+// NewRepositories returns the repositories of a SQL database. Their
+// statements are logged to logger and new users get keys from newID.
+func NewRepositories(db *gorm.DB, logger *zap.Logger, newID model.IDGenerator) Repositories {
+	db = db.Session(&gorm.Session{Logger: NewGormLogger(logger)})
+	return Repositories{
+		Users:    NewUserRepository(db, logger, newID),
+		Outbox:   NewOutboxRepository(db),
+		Webhooks: NewWebhookRepository(db),
+		Audit:    NewAuditRepository(db),
+		DB:       db,
+		closers: []func() error{func() error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Close()
+		}},
+	}
+}
+
+// NewSpannerRepositories returns the repositories of a Spanner database.
+// They use client, which tags every request with the API request it belongs
+// to; db only serves the health checks and migrations.
+func NewSpannerRepositories(db *gorm.DB, client *spanner.Client, logger *zap.Logger, newID model.IDGenerator) Repositories {
+	repos := NewRepositories(db, logger, newID)
+	repos.Users = NewSpannerUserRepository(client, logger, newID)
+	repos.Outbox = NewSpannerOutboxRepository(client)
+	repos.Webhooks = NewSpannerWebhookRepository(client)
+	repos.Audit = NewSpannerAuditRepository(client)
+	repos.Changes = NewUserChangeStream(client)
+	repos.closers = append(repos.closers, func() error {
+		client.Close()
+		return nil
+	})
+	return repos
+}
+
+// Close releases the connections to the database. Requests still running
+// fail afterwards.
+func (r Repositories) Close() error {
+	var errs []error
+	for _, close := range r.closers {
+		errs = append(errs, close())
+	}
+	return errors.Join(errs...)
+}
+
+// NewMemoryRepositories returns the repositories of a new, empty in-memory
+// database, in which new users get keys from newID. Its data is lost when
+// the process exits.
+func NewMemoryRepositories(newID model.IDGenerator) Repositories {
+	store := newMemoryStore()
+	return Repositories{
+		Users:    &memoryUserRepository{store: store, newID: newID},
+		Outbox:   &memoryOutboxRepository{store: store},
+		Webhooks: &memoryWebhookRepository{store: store},
+		Audit:    &memoryAuditRepository{store: store},
+	}
+}
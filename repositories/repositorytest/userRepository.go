@@ -0,0 +1,400 @@
+// Package repositorytest is a conformance suite that every implementation
+// of interfaces.UserRepository has to pass, so that the in-memory and the
+// database repositories behave the same.
+package repositorytest
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// NewUserRepository returns an empty repository for a single test.
+type NewUserRepository func(t *testing.T) interfaces.UserRepository
+
+// RunUserRepositoryTests runs the suite against the repositories returned by
+// newRepository.
+func RunUserRepositoryTests(t *testing.T, newRepository NewUserRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo interfaces.UserRepository)
+	}{
+		{"CreateAssignsIDAndTimestamps", testCreate},
+		{"GetMissingUser", testGetMissing},
+		{"Update", testUpdate},
+		{"SoftDelete", testSoftDelete},
+		{"Restore", testRestore},
+		{"HardDelete", testHardDelete},
+		{"ListDeletedBefore", testListDeletedBefore},
+		{"FindByEmail", testFindByEmail},
+		{"LegacyID", testLegacyID},
+		{"List", testList},
+		{"ListPages", testListPages},
+		{"TransactionCommits", testTransactionCommits},
+		{"TransactionRollsBack", testTransactionRollsBack},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentTransactions", testConcurrentTransactions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+func create(t *testing.T, repo interfaces.UserRepository, name string) *model.User {
+	t.Helper()
+	user, err := repo.Create(context.Background(), &model.User{
+		Name:     name,
+		Email:    name + "@example.com",
+		Address:  name + " Street 1",
+		Password: "hash",
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func testCreate(t *testing.T, repo interfaces.UserRepository) {
+	before := time.Now().Add(-time.Second)
+	user := create(t, repo, "john")
+	assert.NotEmpty(t, user.ID)
+	assert.True(t, user.CreatedAt.After(before), "CreatedAt %v", user.CreatedAt)
+	assert.False(t, user.UpdatedAt.IsZero())
+
+	found, err := repo.Get(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Equal(t, "john", found.Name)
+	assert.Equal(t, "john@example.com", found.Email)
+	assert.Equal(t, "john Street 1", found.Address)
+	assert.Equal(t, "hash", found.Password)
+	assert.WithinDuration(t, user.CreatedAt, found.CreatedAt, time.Millisecond)
+	assert.False(t, found.DeletedAt.Valid)
+}
+
+func testGetMissing(t *testing.T, repo interfaces.UserRepository) {
+	_, err := repo.Get(context.Background(), "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func testUpdate(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	user := create(t, repo, "john")
+
+	found, err := repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	found.Name = "Johnny"
+	found.Address = "Side Street 2"
+	updated, err := repo.Update(ctx, user.ID, found)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, updated.ID)
+	assert.False(t, updated.UpdatedAt.Before(user.UpdatedAt))
+
+	found, err = repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Johnny", found.Name)
+	assert.Equal(t, "Side Street 2", found.Address)
+	assert.Equal(t, "john@example.com", found.Email)
+	assert.WithinDuration(t, user.CreatedAt, found.CreatedAt, time.Millisecond)
+
+	require.NoError(t, repo.Delete(ctx, user.ID))
+	_, err = repo.Update(ctx, user.ID, found)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "deleted users cannot be updated")
+}
+
+func testSoftDelete(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	user := create(t, repo, "john")
+
+	require.NoError(t, repo.Delete(ctx, user.ID))
+	assert.ErrorIs(t, repo.Delete(ctx, user.ID), gorm.ErrRecordNotFound)
+
+	_, err := repo.Get(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = repo.FindByEmail(ctx, "john@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	users, err := repo.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	deleted, err := repo.FindDeleted(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.DeletedAt.Valid)
+	assert.Equal(t, "john", deleted.Name)
+
+	deletedUsers, err := repo.GetDeleted(ctx)
+	require.NoError(t, err)
+	require.Len(t, deletedUsers, 1)
+	assert.Equal(t, user.ID, deletedUsers[0].ID)
+}
+
+func testRestore(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	user := create(t, repo, "john")
+
+	_, err := repo.Restore(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "only deleted users can be restored")
+
+	require.NoError(t, repo.Delete(ctx, user.ID))
+	restored, err := repo.Restore(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, restored.ID)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	_, err = repo.Get(ctx, user.ID)
+	assert.NoError(t, err)
+	_, err = repo.FindDeleted(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func testHardDelete(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	active := create(t, repo, "john")
+	deleted := create(t, repo, "jane")
+	require.NoError(t, repo.Delete(ctx, deleted.ID))
+
+	require.NoError(t, repo.HardDelete(ctx, active.ID))
+	require.NoError(t, repo.HardDelete(ctx, deleted.ID), "soft-deleted users can be removed")
+	assert.ErrorIs(t, repo.HardDelete(ctx, active.ID), gorm.ErrRecordNotFound)
+
+	for _, id := range []string{active.ID, deleted.ID} {
+		_, err := repo.Get(ctx, id)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = repo.FindDeleted(ctx, id)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	}
+}
+
+func testListDeletedBefore(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	active := create(t, repo, "john")
+	first := create(t, repo, "jane")
+	second := create(t, repo, "jim")
+	require.NoError(t, repo.Delete(ctx, first.ID))
+	// Deletion times are stored with millisecond precision on some databases.
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, repo.Delete(ctx, second.ID))
+
+	users, err := repo.ListDeletedBefore(ctx, time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, users, "users deleted after the cutoff are kept")
+
+	users, err = repo.ListDeletedBefore(ctx, time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first.ID, second.ID}, userIDs(users), "the longest deleted come first")
+
+	users, err = repo.ListDeletedBefore(ctx, time.Now().Add(time.Minute), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first.ID}, userIDs(users))
+	assert.NotContains(t, userIDs(users), active.ID, "active users are never listed")
+}
+
+func userIDs(users []model.User) []string {
+	ids := []string{}
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func testFindByEmail(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	create(t, repo, "john")
+	jane := create(t, repo, "jane")
+
+	found, err := repo.FindByEmail(ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, jane.ID, found.ID)
+
+	_, err = repo.FindByEmail(ctx, "nobody@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func testLegacyID(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	legacyID := int64(42)
+	user, err := repo.Create(ctx, &model.User{LegacyID: &legacyID, Name: "john", Email: "john@example.com"})
+	require.NoError(t, err)
+
+	found, err := repo.Get(ctx, "42")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = repo.Get(ctx, "43")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = repo.Create(ctx, &model.User{LegacyID: &legacyID, Name: "jane", Email: "jane@example.com"})
+	assert.Error(t, err, "legacy IDs are unique")
+}
+
+// testList checks GetAll. The interface has no paging, so every user that
+// is not deleted is returned.
+func testList(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	users, err := repo.GetAll(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, users, "an empty list is serialized as [], not null")
+	assert.Empty(t, users)
+
+	var want []string
+	for i := range 5 {
+		want = append(want, create(t, repo, fmt.Sprintf("user%d", i)).ID)
+	}
+	require.NoError(t, repo.Delete(ctx, want[2]))
+	want = append(want[:2], want[3:]...)
+
+	users, err = repo.GetAll(ctx)
+	require.NoError(t, err)
+	var got []string
+	for _, user := range users {
+		got = append(got, user.ID)
+	}
+	assert.ElementsMatch(t, want, got)
+}
+
+// testListPages checks that List returns the users ordered by ID and that
+// following the pages visits every user that is not deleted once.
+func testListPages(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	var want []string
+	for i := range 5 {
+		want = append(want, create(t, repo, fmt.Sprintf("user%d", i)).ID)
+	}
+	require.NoError(t, repo.Delete(ctx, want[1]))
+	want = append(want[:1], want[2:]...)
+	slices.Sort(want)
+
+	users, err := repo.List(ctx, model.Page{})
+	require.NoError(t, err)
+	var got []string
+	for _, user := range users {
+		got = append(got, user.ID)
+	}
+	assert.Equal(t, want, got, "without a size every user is listed")
+
+	got = nil
+	page := model.Page{Size: 3}
+	for {
+		users, err := repo.List(ctx, page)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(users), page.Size)
+		if len(users) == 0 {
+			break
+		}
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+		page.After = users[len(users)-1].ID
+	}
+	assert.Equal(t, want, got)
+}
+
+func testTransactionCommits(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	var created *model.User
+	err := repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		var err error
+		created, err = repo.Create(ctx, &model.User{Name: "john", Email: "john@example.com"})
+		if err != nil {
+			return err
+		}
+		// Nested transactions join the outer one
+		return repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+			return repo.Delete(ctx, created.ID)
+		})
+	})
+	require.NoError(t, err)
+
+	_, err = repo.FindDeleted(ctx, created.ID)
+	assert.NoError(t, err)
+}
+
+func testTransactionRollsBack(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	existing := create(t, repo, "jane")
+
+	failure := errors.New("validation failed")
+	err := repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		if _, err := repo.Create(ctx, &model.User{Name: "john", Email: "john@example.com"}); err != nil {
+			return err
+		}
+		if err := repo.Delete(ctx, existing.ID); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	_, err = repo.FindByEmail(ctx, "john@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = repo.Get(ctx, existing.ID)
+	assert.NoError(t, err)
+}
+
+func testConcurrentCreates(t *testing.T, repo interfaces.UserRepository) {
+	const count = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Create(context.Background(), &model.User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i)})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	users, err := repo.GetAll(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, users, count)
+}
+
+// testConcurrentTransactions checks that read-modify-write transactions
+// do not lose updates.
+func testConcurrentTransactions(t *testing.T, repo interfaces.UserRepository) {
+	ctx := context.Background()
+	user := create(t, repo, "")
+
+	const count = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+				found, err := repo.Get(ctx, user.ID)
+				if err != nil {
+					return err
+				}
+				found.Name += "x"
+				_, err = repo.Update(ctx, user.ID, found)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	found, err := repo.Get(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "xxxxx", found.Name)
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// The Spanner repositories use the Spanner client rather than gorm, because
+// go-sql-spanner v1.9.0 cannot tag its statements. Every query, DML
+// statement and read-write transaction carries the tag of requestTag.
+
+// maxRequestTag is the longest request tag Spanner accepts.
+const maxRequestTag = 50
+
+// requestTag tags the Spanner requests of an API request with its ID, so
+// that they can be found in the query and transaction statistics. IDs that
+// do not fit are cut off.
+func requestTag(ctx context.Context) string {
+	id := model.RequestInfoFrom(ctx).RequestID
+	if id == "" {
+		return ""
+	}
+	tag := "request_id=" + id
+	return tag[:min(len(tag), maxRequestTag)]
+}
+
+// queryOptions tags a query with the request of ctx.
+func queryOptions(ctx context.Context) spanner.QueryOptions {
+	return spanner.QueryOptions{RequestTag: requestTag(ctx)}
+}
+
+// transactionOptions tags a read-write transaction with the request of ctx.
+func transactionOptions(ctx context.Context) spanner.TransactionOptions {
+	return spanner.TransactionOptions{TransactionTag: requestTag(ctx)}
+}
+
+// spannerReader is a transaction that runs queries.
+type spannerReader interface {
+	QueryWithOptions(ctx context.Context, statement spanner.Statement, opts spanner.QueryOptions) *spanner.RowIterator
+}
+
+// spannerQuery runs a tagged query and scans every row of its result.
+func spannerQuery[T any](ctx context.Context, reader spannerReader, sql string, params map[string]any, scan func(row *spanner.Row) (T, error)) ([]T, error) {
+	results := []T{}
+	statement := spanner.Statement{SQL: sql, Params: params}
+	err := reader.QueryWithOptions(ctx, statement, queryOptions(ctx)).Do(func(row *spanner.Row) error {
+		result, err := scan(row)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// spannerExec runs a tagged DML statement in txn and returns the number of
+// rows it changed.
+func spannerExec(ctx context.Context, txn *spanner.ReadWriteTransaction, sql string, params map[string]any) (int64, error) {
+	return txn.UpdateWithOptions(ctx, spanner.Statement{SQL: sql, Params: params}, queryOptions(ctx))
+}
+
+// readWrite runs fn in a tagged read-write transaction of client, which is
+// retried when Spanner aborts it.
+func readWrite(ctx context.Context, client *spanner.Client, fn func(ctx context.Context, txn *spanner.ReadWriteTransaction) error) error {
+	_, err := client.ReadWriteTransactionWithOptions(ctx, fn, transactionOptions(ctx))
+	return err
+}
+
+func nullInt64(value *int64) spanner.NullInt64 {
+	if value == nil {
+		return spanner.NullInt64{}
+	}
+	return spanner.NullInt64{Int64: *value, Valid: true}
+}
+
+func nullTime(value *time.Time) spanner.NullTime {
+	if value == nil {
+		return spanner.NullTime{}
+	}
+	return spanner.NullTime{Time: *value, Valid: true}
+}
+
+// timePointer is the inverse of nullTime.
+func timePointer(value spanner.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
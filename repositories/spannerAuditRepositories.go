@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"encoding/json"
+
+	"cloud.google.com/go/spanner"
+)
+
+type spannerAuditRepository struct {
+	client *spanner.Client
+}
+
+// NewSpannerAuditRepository returns the audit log in the database of client.
+func NewSpannerAuditRepository(client *spanner.Client) interfaces.AuditRepository {
+	return &spannerAuditRepository{client: client}
+}
+
+func (r *spannerAuditRepository) List(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	sql := "SELECT id, user_id, action, actor, client_ip, request_id, changes, created_at FROM user_audit_entries WHERE user_id = @userId"
+	params := map[string]any{"userId": userID}
+	if filter.Actor != "" {
+		sql += " AND actor = @actor"
+		params["actor"] = filter.Actor
+	}
+	if filter.Action != "" {
+		sql += " AND action = @action"
+		params["action"] = filter.Action
+	}
+	if filter.Field != "" {
+		// changes is a JSON array of field changes
+		sql += " AND changes LIKE @field"
+		params["field"] = `%"field":"` + filter.Field + `"%`
+	}
+	if !filter.Since.IsZero() {
+		sql += " AND created_at >= @since"
+		params["since"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		sql += " AND created_at < @until"
+		params["until"] = filter.Until
+	}
+	sql += " ORDER BY created_at DESC LIMIT @limit"
+	params["limit"] = int64(filter.Limit)
+	return spannerQuery(ctx, r.client.Single(), sql, params, scanAuditEntry)
+}
+
+func scanAuditEntry(row *spanner.Row) (model.AuditEntry, error) {
+	var (
+		entry                                      model.AuditEntry
+		userID, action, actor, clientIP, requestID spanner.NullString
+		changes                                    spanner.NullString
+		createdAt                                  spanner.NullTime
+	)
+	if err := row.Columns(&entry.ID, &userID, &action, &actor, &clientIP, &requestID, &changes, &createdAt); err != nil {
+		return model.AuditEntry{}, err
+	}
+	entry.UserID = userID.StringVal
+	entry.Action = action.StringVal
+	entry.Actor = actor.StringVal
+	entry.ClientIP = clientIP.StringVal
+	entry.RequestID = requestID.StringVal
+	entry.CreatedAt = createdAt.Time
+	if changes.Valid {
+		if err := json.Unmarshal([]byte(changes.StringVal), &entry.Changes); err != nil {
+			return model.AuditEntry{}, err
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+type spannerOutboxRepository struct {
+	client *spanner.Client
+}
+
+// NewSpannerOutboxRepository returns the outbox in the database of client.
+func NewSpannerOutboxRepository(client *spanner.Client) interfaces.OutboxRepository {
+	return &spannerOutboxRepository{client: client}
+}
+
+func (r *spannerOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		var err error
+		events, err = spannerQuery(ctx, txn, `SELECT id, type, user_id, payload, created_at, attempts, last_error, next_attempt_at, published_at FROM outbox_events
+WHERE published_at IS NULL AND next_attempt_at <= @now ORDER BY next_attempt_at LIMIT @limit`, map[string]any{
+			"now":   now,
+			"limit": int64(limit),
+		}, scanOutboxEvent)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		_, err = spannerExec(ctx, txn, "UPDATE outbox_events SET next_attempt_at = @nextAttemptAt WHERE id IN UNNEST(@ids)", map[string]any{
+			"nextAttemptAt": now.Add(lease),
+			"ids":           ids,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *spannerOutboxRepository) MarkPublished(ctx context.Context, id string, publishedAt time.Time) error {
+	return readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		_, err := spannerExec(ctx, txn, "UPDATE outbox_events SET published_at = @publishedAt WHERE id = @id", map[string]any{
+			"id":          id,
+			"publishedAt": publishedAt,
+		})
+		return err
+	})
+}
+
+func (r *spannerOutboxRepository) MarkFailed(ctx context.Context, id string, attempts int64, lastError string, nextAttemptAt time.Time) error {
+	return readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		_, err := spannerExec(ctx, txn, "UPDATE outbox_events SET attempts = @attempts, last_error = @lastError, next_attempt_at = @nextAttemptAt WHERE id = @id", map[string]any{
+			"id":            id,
+			"attempts":      attempts,
+			"lastError":     lastError,
+			"nextAttemptAt": nextAttemptAt,
+		})
+		return err
+	})
+}
+
+func (r *spannerOutboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var deleted int64
+	err := readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		var err error
+		deleted, err = spannerExec(ctx, txn, "DELETE FROM outbox_events WHERE id IN (SELECT id FROM outbox_events WHERE published_at < @before LIMIT @limit)", map[string]any{
+			"before": before,
+			"limit":  int64(limit),
+		})
+		return err
+	})
+	return deleted, err
+}
+
+func scanOutboxEvent(row *spanner.Row) (model.OutboxEvent, error) {
+	var (
+		event                                 model.OutboxEvent
+		eventType, userID, payload, lastError spanner.NullString
+		createdAt, nextAttemptAt, publishedAt spanner.NullTime
+		attempts                              spanner.NullInt64
+	)
+	if err := row.Columns(&event.ID, &eventType, &userID, &payload, &createdAt, &attempts, &lastError, &nextAttemptAt, &publishedAt); err != nil {
+		return model.OutboxEvent{}, err
+	}
+	event.Type = eventType.StringVal
+	event.UserID = userID.StringVal
+	event.Payload = payload.StringVal
+	event.CreatedAt = createdAt.Time
+	event.Attempts = attempts.Int64
+	event.LastError = lastError.StringVal
+	event.NextAttemptAt = nextAttemptAt.Time
+	event.PublishedAt = timePointer(publishedAt)
+	return event, nil
+}
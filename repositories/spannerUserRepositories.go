@@ -0,0 +1,413 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const selectUsers = `SELECT id, legacy_id, created_at, updated_at, deleted_at, name, email, address, password FROM users`
+
+// errReadOnly is returned by writes inside ReadOnly.
+var errReadOnly = errors.New("the user repository is read-only in this transaction")
+
+// spannerUserRepository stores users with the Spanner client instead of
+// database/sql, as go-sql-spanner v1.9.0 cannot report the read timestamp
+// of a read-only transaction. Missing users are reported as gorm.ErrRecordNotFound, like the other
+// repositories do.
+//
+// created_at and updated_at hold the commit timestamp of the write, so they
+// order changes consistently no matter which server wrote them. The
+// timestamp is only known once the transaction has committed, so written
+// users are remembered and get it afterwards.
+type spannerUserRepository struct {
+	client *spanner.Client
+	logger *zap.Logger
+	newID  model.IDGenerator
+	// txn is the read-write transaction the repository belongs to.
+	txn     *spanner.ReadWriteTransaction
+	pending *[]pendingWrite
+	// snapshot is the read-only transaction the repository belongs to.
+	snapshot *snapshot
+}
+
+// pendingWrite is a user written with the commit timestamp of its
+// transaction.
+type pendingWrite struct {
+	user    *model.User
+	created bool
+}
+
+// NewSpannerUserRepository returns the repository of the users in the
+// database of client. The keys of new users are generated by newID.
+func NewSpannerUserRepository(client *spanner.Client, logger *zap.Logger, newID model.IDGenerator) interfaces.UserRepository {
+	return &spannerUserRepository{client: client, logger: logger, newID: newID}
+}
+
+// reader returns the transaction the queries of the repository run in.
+// Outside of a transaction each query runs in a strong single-use one.
+func (r *spannerUserRepository) reader() spannerReader {
+	switch {
+	case r.txn != nil:
+		return r.txn
+	case r.snapshot != nil:
+		return r.snapshot.reader()
+	default:
+		return r.client.Single()
+	}
+}
+
+func (r *spannerUserRepository) queryUsers(ctx context.Context, sql string, params map[string]any) ([]model.User, error) {
+	return spannerQuery(ctx, r.reader(), sql, params, scanUser)
+}
+
+func (r *spannerUserRepository) queryUser(ctx context.Context, sql string, params map[string]any) (*model.User, error) {
+	users, err := r.queryUsers(ctx, sql+" LIMIT 1", params)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &users[0], nil
+}
+
+func scanUser(row *spanner.Row) (model.User, error) {
+	var (
+		user                            model.User
+		legacyID                        spanner.NullInt64
+		createdAt, updatedAt, deletedAt spanner.NullTime
+		name, email, address, password  spanner.NullString
+	)
+	if err := row.Columns(&user.ID, &legacyID, &createdAt, &updatedAt, &deletedAt, &name, &email, &address, &password); err != nil {
+		return model.User{}, err
+	}
+	if legacyID.Valid {
+		user.LegacyID = &legacyID.Int64
+	}
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	user.DeletedAt = gorm.DeletedAt{Time: deletedAt.Time, Valid: deletedAt.Valid}
+	user.Name = name.StringVal
+	user.Email = email.StringVal
+	user.Address = address.StringVal
+	user.Password = password.StringVal
+	return user, nil
+}
+
+// spannerWhereID matches a user by key. Numeric IDs are looked up as the
+// legacy IDs of users created before string keys were introduced.
+func spannerWhereID(id string) (string, map[string]any) {
+	if legacyID, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return " WHERE legacy_id = @id", map[string]any{"id": legacyID}
+	}
+	return " WHERE id = @id", map[string]any{"id": id}
+}
+
+// write runs fn in the read-write transaction of the repository, or in a
+// new one outside of a transaction.
+func (r *spannerUserRepository) write(ctx context.Context, fn func(repo *spannerUserRepository) error) error {
+	switch {
+	case r.txn != nil:
+		return fn(r)
+	case r.snapshot != nil:
+		return errReadOnly
+	}
+	return r.readWrite(ctx, fn)
+}
+
+func (r *spannerUserRepository) exec(ctx context.Context, sql string, params map[string]any) (int64, error) {
+	return spannerExec(ctx, r.txn, sql, params)
+}
+
+// written remembers that user gets the commit timestamp of the transaction.
+func (r *spannerUserRepository) written(user *model.User, created bool) {
+	*r.pending = append(*r.pending, pendingWrite{user: user, created: created})
+}
+
+func (r *spannerUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	if user.ID == "" {
+		id, err := r.newID()
+		if err != nil {
+			return nil, err
+		}
+		user.ID = id
+	}
+	err := r.write(ctx, func(repo *spannerUserRepository) error {
+		_, err := repo.exec(ctx, `INSERT INTO users (id, legacy_id, created_at, updated_at, deleted_at, name, email, address, password)
+VALUES (@id, @legacyId, PENDING_COMMIT_TIMESTAMP(), PENDING_COMMIT_TIMESTAMP(), @deletedAt, @name, @email, @address, @password)`, map[string]any{
+			"id":        user.ID,
+			"legacyId":  nullInt64(user.LegacyID),
+			"deletedAt": spanner.NullTime{Time: user.DeletedAt.Time, Valid: user.DeletedAt.Valid},
+			"name":      user.Name,
+			"email":     user.Email,
+			"address":   user.Address,
+			"password":  user.Password,
+		})
+		if err != nil {
+			return err
+		}
+		repo.written(user, true)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *spannerUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	where, params := spannerWhereID(id)
+	return r.queryUser(ctx, selectUsers+where+" AND deleted_at IS NULL", params)
+}
+
+func (r *spannerUserRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	err := r.write(ctx, func(repo *spannerUserRepository) error {
+		existingUser, err := repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		user.ID = existingUser.ID
+		_, err = repo.exec(ctx, `UPDATE users SET name = @name, email = @email, address = @address, password = @password, updated_at = PENDING_COMMIT_TIMESTAMP() WHERE id = @id`, map[string]any{
+			"id":       user.ID,
+			"name":     user.Name,
+			"email":    user.Email,
+			"address":  user.Address,
+			"password": user.Password,
+		})
+		if err != nil {
+			return err
+		}
+		repo.written(user, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *spannerUserRepository) Delete(ctx context.Context, id string) error {
+	return r.write(ctx, func(repo *spannerUserRepository) error {
+		user, err := repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		_, err = repo.exec(ctx, `UPDATE users SET deleted_at = @deletedAt WHERE id = @id`, map[string]any{
+			"id":        user.ID,
+			"deletedAt": time.Now().UTC(),
+		})
+		return err
+	})
+}
+
+func (r *spannerUserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	return r.queryUsers(ctx, selectUsers+" WHERE deleted_at IS NULL", nil)
+}
+
+func (r *spannerUserRepository) List(ctx context.Context, page model.Page) ([]model.User, error) {
+	sql := selectUsers + " WHERE deleted_at IS NULL"
+	params := map[string]any{}
+	if page.After != "" {
+		sql += " AND id > @after"
+		params["after"] = page.After
+	}
+	sql += " ORDER BY id"
+	if page.Size > 0 {
+		sql += " LIMIT @size"
+		params["size"] = int64(page.Size)
+	}
+	return r.queryUsers(ctx, sql, params)
+}
+
+func (r *spannerUserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.queryUser(ctx, selectUsers+" WHERE email = @email AND deleted_at IS NULL", map[string]any{"email": email})
+}
+
+func (r *spannerUserRepository) GetDeleted(ctx context.Context) ([]model.User, error) {
+	return r.queryUsers(ctx, selectUsers+" WHERE deleted_at IS NOT NULL", nil)
+}
+
+func (r *spannerUserRepository) FindDeleted(ctx context.Context, id string) (*model.User, error) {
+	where, params := spannerWhereID(id)
+	return r.queryUser(ctx, selectUsers+where+" AND deleted_at IS NOT NULL", params)
+}
+
+func (r *spannerUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	var user *model.User
+	err := r.write(ctx, func(repo *spannerUserRepository) error {
+		var err error
+		user, err = repo.FindDeleted(ctx, id)
+		if err != nil {
+			return err
+		}
+		_, err = repo.exec(ctx, `UPDATE users SET deleted_at = NULL, updated_at = PENDING_COMMIT_TIMESTAMP() WHERE id = @id`, map[string]any{"id": user.ID})
+		if err != nil {
+			return err
+		}
+		user.DeletedAt = gorm.DeletedAt{}
+		repo.written(user, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *spannerUserRepository) HardDelete(ctx context.Context, id string) error {
+	return r.write(ctx, func(repo *spannerUserRepository) error {
+		where, params := spannerWhereID(id)
+		user, err := repo.queryUser(ctx, selectUsers+where, params)
+		if err != nil {
+			return err
+		}
+		_, err = repo.exec(ctx, `DELETE FROM users WHERE id = @id`, map[string]any{"id": user.ID})
+		return err
+	})
+}
+
+func (r *spannerUserRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]model.User, error) {
+	return r.queryUsers(ctx, selectUsers+" WHERE deleted_at IS NOT NULL AND deleted_at < @before ORDER BY deleted_at, id LIMIT @limit", map[string]any{
+		"before": before,
+		"limit":  int64(limit),
+	})
+}
+
+func (r *spannerUserRepository) AddEvent(ctx context.Context, event *model.OutboxEvent) error {
+	return r.write(ctx, func(repo *spannerUserRepository) error {
+		_, err := repo.exec(ctx, `INSERT INTO outbox_events (id, type, user_id, payload, created_at, attempts, last_error, next_attempt_at, published_at)
+VALUES (@id, @type, @userId, @payload, @createdAt, @attempts, @lastError, @nextAttemptAt, @publishedAt)`, map[string]any{
+			"id":            event.ID,
+			"type":          event.Type,
+			"userId":        event.UserID,
+			"payload":       event.Payload,
+			"createdAt":     event.CreatedAt,
+			"attempts":      event.Attempts,
+			"lastError":     event.LastError,
+			"nextAttemptAt": event.NextAttemptAt,
+			"publishedAt":   nullTime(event.PublishedAt),
+		})
+		return err
+	})
+}
+
+func (r *spannerUserRepository) AddAuditEntry(ctx context.Context, entry *model.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	return r.write(ctx, func(repo *spannerUserRepository) error {
+		_, err := repo.exec(ctx, `INSERT INTO user_audit_entries (id, user_id, action, actor, client_ip, request_id, changes, created_at)
+VALUES (@id, @userId, @action, @actor, @clientIp, @requestId, @changes, @createdAt)`, map[string]any{
+			"id":        entry.ID,
+			"userId":    entry.UserID,
+			"action":    entry.Action,
+			"actor":     entry.Actor,
+			"clientIp":  entry.ClientIP,
+			"requestId": entry.RequestID,
+			"changes":   string(changes),
+			"createdAt": entry.CreatedAt,
+		})
+		return err
+	})
+}
+
+// Transaction runs fn in a read-write transaction of the client, which
+// retries it when Spanner aborts it. Inside a transaction fn simply joins
+// it, as Spanner has no nested transactions.
+func (r *spannerUserRepository) Transaction(ctx context.Context, fn func(repo interfaces.UserRepository) error) error {
+	return r.write(ctx, func(repo *spannerUserRepository) error {
+		return fn(repo)
+	})
+}
+
+func (r *spannerUserRepository) readWrite(ctx context.Context, fn func(repo *spannerUserRepository) error) error {
+	var pending []pendingWrite
+	response, err := r.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		pending = nil
+		return fn(&spannerUserRepository{client: r.client, logger: r.logger, newID: r.newID, txn: txn, pending: &pending})
+	}, transactionOptions(ctx))
+	if err != nil {
+		return err
+	}
+	for _, write := range pending {
+		if write.created {
+			write.user.CreatedAt = response.CommitTs
+		}
+		write.user.UpdatedAt = response.CommitTs
+	}
+	return nil
+}
+
+// ReadOnly runs fn in a read-only transaction at the given staleness and
+// returns its read timestamp. Strong reads run directly and report no
+// timestamp. Bounded staleness is only allowed in single-use transactions,
+// so each query of fn then runs in one of its own and the oldest of their
+// read timestamps is reported.
+func (r *spannerUserRepository) ReadOnly(ctx context.Context, staleness model.Staleness, fn func(repo interfaces.UserRepository) error) (time.Time, error) {
+	if r.txn != nil || r.snapshot != nil || staleness.IsStrong() {
+		return time.Time{}, fn(r)
+	}
+
+	snapshot := &snapshot{client: r.client, bound: spanner.MaxStaleness(staleness.Duration)}
+	if !staleness.Bounded {
+		snapshot.txn = r.client.ReadOnlyTransaction().WithTimestampBound(spanner.ExactStaleness(staleness.Duration))
+		defer snapshot.txn.Close()
+	}
+	if err := fn(&spannerUserRepository{client: r.client, logger: r.logger, newID: r.newID, snapshot: snapshot}); err != nil {
+		return time.Time{}, err
+	}
+	return snapshot.readTimestamp(), nil
+}
+
+// snapshot is a read-only transaction, or for bounded staleness the
+// single-use transactions its queries ran in.
+type snapshot struct {
+	client *spanner.Client
+	bound  spanner.TimestampBound
+	txn    *spanner.ReadOnlyTransaction
+
+	mu      sync.Mutex
+	singles []*spanner.ReadOnlyTransaction
+}
+
+func (s *snapshot) reader() spannerReader {
+	if s.txn != nil {
+		return s.txn
+	}
+	single := s.client.Single().WithTimestampBound(s.bound)
+	s.mu.Lock()
+	s.singles = append(s.singles, single)
+	s.mu.Unlock()
+	return single
+}
+
+// readTimestamp returns the oldest timestamp the snapshot was read at, or
+// the zero time if nothing was read.
+func (s *snapshot) readTimestamp() time.Time {
+	txns := s.singles
+	if s.txn != nil {
+		txns = []*spanner.ReadOnlyTransaction{s.txn}
+	}
+	var oldest time.Time
+	for _, txn := range txns {
+		timestamp, err := txn.Timestamp()
+		if err != nil {
+			continue
+		}
+		if oldest.IsZero() || timestamp.Before(oldest) {
+			oldest = timestamp
+		}
+	}
+	return oldest.UTC()
+}
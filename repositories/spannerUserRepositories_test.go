@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gorm.io/gorm"
+)
+
+// userRows is the result of a query of selectUsers.
+func userRows(users ...model.User) *testutil.StatementResult {
+	column := func(name string, code spannerpb.TypeCode) *spannerpb.StructType_Field {
+		return &spannerpb.StructType_Field{Name: name, Type: &spannerpb.Type{Code: code}}
+	}
+	str := func(value string) *structpb.Value { return structpb.NewStringValue(value) }
+	timestamp := func(value time.Time) *structpb.Value {
+		if value.IsZero() {
+			return structpb.NewNullValue()
+		}
+		return str(value.UTC().Format(time.RFC3339Nano))
+	}
+
+	resultSet := &spannerpb.ResultSet{Metadata: &spannerpb.ResultSetMetadata{RowType: &spannerpb.StructType{Fields: []*spannerpb.StructType_Field{
+		column("id", spannerpb.TypeCode_STRING),
+		column("legacy_id", spannerpb.TypeCode_INT64),
+		column("created_at", spannerpb.TypeCode_TIMESTAMP),
+		column("updated_at", spannerpb.TypeCode_TIMESTAMP),
+		column("deleted_at", spannerpb.TypeCode_TIMESTAMP),
+		column("name", spannerpb.TypeCode_STRING),
+		column("email", spannerpb.TypeCode_STRING),
+		column("address", spannerpb.TypeCode_STRING),
+		column("password", spannerpb.TypeCode_STRING),
+	}}}}
+	for _, user := range users {
+		resultSet.Rows = append(resultSet.Rows, &structpb.ListValue{Values: []*structpb.Value{
+			str(user.ID),
+			structpb.NewNullValue(),
+			timestamp(user.CreatedAt),
+			timestamp(user.UpdatedAt),
+			timestamp(user.DeletedAt.Time),
+			str(user.Name),
+			str(user.Email),
+			str(user.Address),
+			str(user.Password),
+		}})
+	}
+	return &testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: resultSet}
+}
+
+const getUserSQL = selectUsers + " WHERE id = @id AND deleted_at IS NULL LIMIT 1"
+
+func TestSpannerGetUser(t *testing.T) {
+	server, client := mockSpanner(t)
+	require.NoError(t, server.TestSpanner.PutStatementResult(getUserSQL, userRows(commonUser)))
+	repo := NewSpannerUserRepository(client, zap.NewNop(), model.NewID)
+
+	user, err := repo.Get(context.Background(), testID)
+	require.NoError(t, err)
+	assert.Equal(t, commonUser, *user)
+
+	require.NoError(t, server.TestSpanner.PutStatementResult(getUserSQL, userRows()))
+	_, err = repo.Get(context.Background(), testID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestSpannerReadOnlyExactStaleness(t *testing.T) {
+	server, client := mockSpanner(t)
+	require.NoError(t, server.TestSpanner.PutStatementResult(getUserSQL, userRows(commonUser)))
+	repo := NewSpannerUserRepository(client, zap.NewNop(), model.NewID)
+
+	readTimestamp, err := repo.ReadOnly(context.Background(), model.Staleness{Duration: 15 * time.Second}, func(repo interfaces.UserRepository) error {
+		_, err := repo.Get(context.Background(), testID)
+		return err
+	})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), readTimestamp, time.Minute)
+
+	begins := receivedRequests[*spannerpb.BeginTransactionRequest](server)
+	require.Len(t, begins, 1)
+	assert.Equal(t, int64(15), begins[0].GetOptions().GetReadOnly().GetExactStaleness().GetSeconds())
+}
+
+func TestSpannerReadOnlyBoundedStaleness(t *testing.T) {
+	server, client := mockSpanner(t)
+	require.NoError(t, server.TestSpanner.PutStatementResult(getUserSQL, userRows(commonUser)))
+	repo := NewSpannerUserRepository(client, zap.NewNop(), model.NewID)
+
+	_, err := repo.ReadOnly(context.Background(), model.Staleness{Duration: 15 * time.Second, Bounded: true}, func(repo interfaces.UserRepository) error {
+		_, err := repo.Get(context.Background(), testID)
+		return err
+	})
+	require.NoError(t, err)
+
+	queries := receivedRequests[*spannerpb.ExecuteSqlRequest](server)
+	require.Len(t, queries, 1)
+	assert.Equal(t, int64(15), queries[0].GetTransaction().GetSingleUse().GetReadOnly().GetMaxStaleness().GetSeconds())
+}
+
+func TestSpannerTransactionSetsCommitTimestamps(t *testing.T) {
+	server, client := mockSpanner(t)
+	require.NoError(t, server.TestSpanner.PutStatementResult(`INSERT INTO users (id, legacy_id, created_at, updated_at, deleted_at, name, email, address, password)
+VALUES (@id, @legacyId, PENDING_COMMIT_TIMESTAMP(), PENDING_COMMIT_TIMESTAMP(), @deletedAt, @name, @email, @address, @password)`, updateCount(1)))
+	repo := NewSpannerUserRepository(client, zap.NewNop(), model.NewID)
+
+	ctx := model.WithRequestInfo(context.Background(), model.RequestInfo{RequestID: "req-1"})
+	user := model.User{Name: "john"}
+	err := repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		_, err := repo.Create(ctx, &user)
+		return err
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+	assert.Equal(t, user.CreatedAt, user.UpdatedAt)
+
+	statements := receivedRequests[*spannerpb.ExecuteSqlRequest](server)
+	require.Len(t, statements, 1)
+	assert.Equal(t, "request_id=req-1", statements[0].GetRequestOptions().GetRequestTag())
+	assert.Equal(t, "request_id=req-1", statements[0].GetRequestOptions().GetTransactionTag())
+}
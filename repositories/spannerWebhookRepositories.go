@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"gorm.io/gorm"
+)
+
+const (
+	selectSubscriptions = "SELECT id, url, event_types, secret, consecutive_failures, disabled_at, disabled_reason, created_at, updated_at FROM webhook_subscriptions"
+	selectDeliveries    = "SELECT subscription_id, id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at FROM webhook_deliveries"
+)
+
+type spannerWebhookRepository struct {
+	client *spanner.Client
+}
+
+// NewSpannerWebhookRepository returns the webhook subscriptions and
+// deliveries in the database of client. Missing subscriptions are reported
+// as gorm.ErrRecordNotFound, like the other repositories do.
+func NewSpannerWebhookRepository(client *spanner.Client) interfaces.WebhookRepository {
+	return &spannerWebhookRepository{client: client}
+}
+
+func (r *spannerWebhookRepository) CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	now := time.Now().UTC()
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = now
+	}
+	if subscription.UpdatedAt.IsZero() {
+		subscription.UpdatedAt = now
+	}
+	params, err := subscriptionParams(subscription)
+	if err != nil {
+		return err
+	}
+	return readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		_, err := spannerExec(ctx, txn, `INSERT INTO webhook_subscriptions (id, url, event_types, secret, consecutive_failures, disabled_at, disabled_reason, created_at, updated_at)
+VALUES (@id, @url, @eventTypes, @secret, @consecutiveFailures, @disabledAt, @disabledReason, @createdAt, @updatedAt)`, params)
+		return err
+	})
+}
+
+func (r *spannerWebhookRepository) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	return getSubscription(ctx, r.client.Single(), id)
+}
+
+func getSubscription(ctx context.Context, reader spannerReader, id string) (*model.WebhookSubscription, error) {
+	subscriptions, err := spannerQuery(ctx, reader, selectSubscriptions+" WHERE id = @id", map[string]any{"id": id}, scanSubscription)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &subscriptions[0], nil
+}
+
+func (r *spannerWebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return spannerQuery(ctx, r.client.Single(), selectSubscriptions+" ORDER BY created_at", nil, scanSubscription)
+}
+
+func (r *spannerWebhookRepository) SaveSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	subscription.UpdatedAt = time.Now().UTC()
+	params, err := subscriptionParams(subscription)
+	if err != nil {
+		return err
+	}
+	return readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		count, err := spannerExec(ctx, txn, `UPDATE webhook_subscriptions SET url = @url, event_types = @eventTypes, secret = @secret,
+consecutive_failures = @consecutiveFailures, disabled_at = @disabledAt, disabled_reason = @disabledReason, updated_at = @updatedAt
+WHERE id = @id`, params)
+		if err == nil && count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return err
+	})
+}
+
+// DeleteSubscription removes a subscription together with its delivery log.
+func (r *spannerWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	return readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		params := map[string]any{"id": id}
+		if _, err := spannerExec(ctx, txn, "DELETE FROM webhook_deliveries WHERE subscription_id = @id", params); err != nil {
+			return err
+		}
+		count, err := spannerExec(ctx, txn, "DELETE FROM webhook_subscriptions WHERE id = @id", params)
+		if err == nil && count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return err
+	})
+}
+
+func (r *spannerWebhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		var ids []string
+		for _, delivery := range deliveries {
+			if !slices.Contains(ids, delivery.ID) {
+				ids = append(ids, delivery.ID)
+			}
+		}
+		existing, err := spannerQuery(ctx, txn, selectDeliveries+" WHERE id IN UNNEST(@ids)", map[string]any{"ids": ids}, scanDelivery)
+		if err != nil {
+			return err
+		}
+		exists := make(map[[2]string]bool, len(existing))
+		for _, delivery := range existing {
+			exists[[2]string{delivery.SubscriptionID, delivery.ID}] = true
+		}
+
+		for _, delivery := range deliveries {
+			if exists[[2]string{delivery.SubscriptionID, delivery.ID}] {
+				continue
+			}
+			_, err := spannerExec(ctx, txn, `INSERT INTO webhook_deliveries (subscription_id, id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at)
+VALUES (@subscriptionId, @id, @eventType, @payload, @status, @attempts, @nextAttemptAt, @lastStatusCode, @lastError, @createdAt, @deliveredAt)`, map[string]any{
+				"subscriptionId": delivery.SubscriptionID,
+				"id":             delivery.ID,
+				"eventType":      delivery.EventType,
+				"payload":        delivery.Payload,
+				"status":         delivery.Status,
+				"attempts":       delivery.Attempts,
+				"nextAttemptAt":  delivery.NextAttemptAt,
+				"lastStatusCode": delivery.LastStatusCode,
+				"lastError":      delivery.LastError,
+				"createdAt":      delivery.CreatedAt,
+				"deliveredAt":    nullTime(delivery.DeliveredAt),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *spannerWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		var err error
+		deliveries, err = spannerQuery(ctx, txn, selectDeliveries+" WHERE status = @status AND next_attempt_at <= @now ORDER BY next_attempt_at LIMIT @limit", map[string]any{
+			"status": model.DeliveryPending,
+			"now":    now,
+			"limit":  int64(limit),
+		}, scanDelivery)
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			_, err := spannerExec(ctx, txn, "UPDATE webhook_deliveries SET next_attempt_at = @nextAttemptAt WHERE subscription_id = @subscriptionId AND id = @id", map[string]any{
+				"subscriptionId": delivery.SubscriptionID,
+				"id":             delivery.ID,
+				"nextAttemptAt":  now.Add(lease),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *spannerWebhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, disableAfter int64) (bool, error) {
+	disabled := false
+	err := readWrite(ctx, r.client, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		disabled = false
+		_, err := spannerExec(ctx, txn, `UPDATE webhook_deliveries SET status = @status, attempts = @attempts, next_attempt_at = @nextAttemptAt,
+last_status_code = @lastStatusCode, last_error = @lastError, delivered_at = @deliveredAt
+WHERE subscription_id = @subscriptionId AND id = @id`, map[string]any{
+			"subscriptionId": delivery.SubscriptionID,
+			"id":             delivery.ID,
+			"status":         delivery.Status,
+			"attempts":       delivery.Attempts,
+			"nextAttemptAt":  delivery.NextAttemptAt,
+			"lastStatusCode": delivery.LastStatusCode,
+			"lastError":      delivery.LastError,
+			"deliveredAt":    nullTime(delivery.DeliveredAt),
+		})
+		if err != nil {
+			return err
+		}
+
+		subscription, err := getSubscription(ctx, txn, delivery.SubscriptionID)
+		if err != nil {
+			return err
+		}
+		params := map[string]any{"id": subscription.ID, "consecutiveFailures": int64(0)}
+		sql := "UPDATE webhook_subscriptions SET consecutive_failures = @consecutiveFailures WHERE id = @id"
+		if delivery.Status != model.DeliverySucceeded {
+			if !subscription.Enabled() {
+				return nil
+			}
+			failures := subscription.ConsecutiveFailures + 1
+			params["consecutiveFailures"] = failures
+			if failures >= disableAfter {
+				disabled = true
+				sql = "UPDATE webhook_subscriptions SET consecutive_failures = @consecutiveFailures, disabled_at = @disabledAt, disabled_reason = @disabledReason WHERE id = @id"
+				params["disabledAt"] = time.Now().UTC()
+				params["disabledReason"] = fmt.Sprintf("%d deliveries failed in a row", failures)
+			}
+		} else if subscription.ConsecutiveFailures == 0 {
+			return nil
+		}
+		_, err = spannerExec(ctx, txn, sql, params)
+		return err
+	})
+	return disabled, err
+}
+
+func (r *spannerWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	return spannerQuery(ctx, r.client.Single(), selectDeliveries+" WHERE subscription_id = @subscriptionId ORDER BY created_at DESC LIMIT @limit", map[string]any{
+		"subscriptionId": subscriptionID,
+		"limit":          int64(limit),
+	}, scanDelivery)
+}
+
+// subscriptionParams are the parameters of the columns of subscription.
+// Its event types are stored as JSON, as the gorm repository does.
+func subscriptionParams(subscription *model.WebhookSubscription) (map[string]any, error) {
+	eventTypes, err := json.Marshal(subscription.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":                  subscription.ID,
+		"url":                 subscription.URL,
+		"eventTypes":          string(eventTypes),
+		"secret":              subscription.Secret,
+		"consecutiveFailures": subscription.ConsecutiveFailures,
+		"disabledAt":          nullTime(subscription.DisabledAt),
+		"disabledReason":      subscription.DisabledReason,
+		"createdAt":           subscription.CreatedAt,
+		"updatedAt":           subscription.UpdatedAt,
+	}, nil
+}
+
+func scanSubscription(row *spanner.Row) (model.WebhookSubscription, error) {
+	var (
+		subscription                            model.WebhookSubscription
+		url, eventTypes, secret, disabledReason spanner.NullString
+		consecutiveFailures                     spanner.NullInt64
+		disabledAt, createdAt, updatedAt        spanner.NullTime
+	)
+	if err := row.Columns(&subscription.ID, &url, &eventTypes, &secret, &consecutiveFailures, &disabledAt, &disabledReason, &createdAt, &updatedAt); err != nil {
+		return model.WebhookSubscription{}, err
+	}
+	if eventTypes.Valid {
+		if err := json.Unmarshal([]byte(eventTypes.StringVal), &subscription.EventTypes); err != nil {
+			return model.WebhookSubscription{}, err
+		}
+	}
+	subscription.URL = url.StringVal
+	subscription.Secret = secret.StringVal
+	subscription.ConsecutiveFailures = consecutiveFailures.Int64
+	subscription.DisabledAt = timePointer(disabledAt)
+	subscription.DisabledReason = disabledReason.StringVal
+	subscription.CreatedAt = createdAt.Time
+	subscription.UpdatedAt = updatedAt.Time
+	return subscription, nil
+}
+
+func scanDelivery(row *spanner.Row) (model.WebhookDelivery, error) {
+	var (
+		delivery                              model.WebhookDelivery
+		eventType, payload, status, lastError spanner.NullString
+		attempts, lastStatusCode              spanner.NullInt64
+		nextAttemptAt, createdAt, deliveredAt spanner.NullTime
+	)
+	if err := row.Columns(&delivery.SubscriptionID, &delivery.ID, &eventType, &payload, &status, &attempts, &nextAttemptAt, &lastStatusCode, &lastError, &createdAt, &deliveredAt); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	delivery.EventType = eventType.StringVal
+	delivery.Payload = payload.StringVal
+	delivery.Status = status.StringVal
+	delivery.Attempts = attempts.Int64
+	delivery.NextAttemptAt = nextAttemptAt.Time
+	delivery.LastStatusCode = lastStatusCode.Int64
+	delivery.LastError = lastError.StringVal
+	delivery.CreatedAt = createdAt.Time
+	delivery.DeliveredAt = timePointer(deliveredAt)
+	return delivery, nil
+}
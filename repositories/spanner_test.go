@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSpanner starts an in-memory Spanner server that answers the
+// statements registered on it and records the requests it receives.
+func mockSpanner(t *testing.T) (*testutil.MockedSpannerInMemTestServer, *spanner.Client) {
+	server, opts, teardown := testutil.NewMockedSpannerInMemTestServer(t)
+	t.Cleanup(teardown)
+	client, err := spanner.NewClientWithConfig(context.Background(), "projects/p/instances/i/databases/d",
+		spanner.ClientConfig{SessionPoolConfig: spanner.SessionPoolConfig{MinOpened: 1}, DisableNativeMetrics: true}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return server, client
+}
+
+// noRows is the result of a query that matches nothing.
+func noRows() *testutil.StatementResult {
+	return &testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: &spannerpb.ResultSet{
+		Metadata: &spannerpb.ResultSetMetadata{RowType: &spannerpb.StructType{}},
+	}}
+}
+
+func updateCount(count int64) *testutil.StatementResult {
+	return &testutil.StatementResult{Type: testutil.StatementResultUpdateCount, UpdateCount: count}
+}
+
+// receivedRequests returns the requests of type T the server received.
+func receivedRequests[T any](server *testutil.MockedSpannerInMemTestServer) []T {
+	var requests []T
+	for {
+		select {
+		case request := <-server.TestSpanner.ReceivedRequests():
+			if request, ok := request.(T); ok {
+				requests = append(requests, request)
+			}
+		default:
+			return requests
+		}
+	}
+}
+
+func TestRequestTag(t *testing.T) {
+	assert.Empty(t, requestTag(context.Background()), "requests of workers are not tagged")
+
+	ctx := model.WithRequestInfo(context.Background(), model.RequestInfo{RequestID: "0f8fad5b-d9cb-469f-a165-70867728950e"})
+	assert.Equal(t, "request_id=0f8fad5b-d9cb-469f-a165-70867728950e", requestTag(ctx))
+
+	ctx = model.WithRequestInfo(context.Background(), model.RequestInfo{RequestID: strings.Repeat("x", 128)})
+	assert.Len(t, requestTag(ctx), maxRequestTag)
+}
+
+func TestSpannerRepositoriesTagRequests(t *testing.T) {
+	server, client := mockSpanner(t)
+	require.NoError(t, server.TestSpanner.PutStatementResult(`SELECT id, user_id, action, actor, client_ip, request_id, changes, created_at FROM user_audit_entries WHERE user_id = @userId ORDER BY created_at DESC LIMIT @limit`, noRows()))
+	require.NoError(t, server.TestSpanner.PutStatementResult(selectSubscriptions+" WHERE id = @id", noRows()))
+	require.NoError(t, server.TestSpanner.PutStatementResult("UPDATE outbox_events SET published_at = @publishedAt WHERE id = @id", updateCount(1)))
+
+	ctx := model.WithRequestInfo(context.Background(), model.RequestInfo{RequestID: "req-1"})
+	_, err := NewSpannerAuditRepository(client).List(ctx, testID, model.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	_, err = NewSpannerWebhookRepository(client).GetSubscription(ctx, testID)
+	require.Error(t, err)
+	require.NoError(t, NewSpannerOutboxRepository(client).MarkPublished(ctx, testID, time.Now()))
+
+	statements := receivedRequests[*spannerpb.ExecuteSqlRequest](server)
+	require.Len(t, statements, 3)
+	for _, statement := range statements {
+		assert.Equal(t, "request_id=req-1", statement.GetRequestOptions().GetRequestTag(), statement.GetSql())
+	}
+	assert.Equal(t, "request_id=req-1", statements[2].GetRequestOptions().GetTransactionTag())
+}
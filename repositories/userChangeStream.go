@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// The change stream is read with the Spanner client, as its records are
+// nested structs that database/sql cannot decode.
+const readUserChanges = `SELECT ChangeRecord FROM READ_users_changes (
+  start_timestamp => @start,
+  end_timestamp => @end,
+  partition_token => @token,
+  heartbeat_milliseconds => 10000
+)`
+
+// The structs mirror the change record of GoogleSQL change streams; every
+// field has to be present for the client to decode them.
+type changeRecord struct {
+	DataChangeRecord      []*dataChangeRecord      `spanner:"data_change_record"`
+	HeartbeatRecord       []*heartbeatRecord       `spanner:"heartbeat_record"`
+	ChildPartitionsRecord []*childPartitionsRecord `spanner:"child_partitions_record"`
+}
+
+type dataChangeRecord struct {
+	CommitTimestamp                      time.Time     `spanner:"commit_timestamp"`
+	RecordSequence                       string        `spanner:"record_sequence"`
+	ServerTransactionID                  string        `spanner:"server_transaction_id"`
+	IsLastRecordInTransactionInPartition bool          `spanner:"is_last_record_in_transaction_in_partition"`
+	TableName                            string        `spanner:"table_name"`
+	ColumnTypes                          []*columnType `spanner:"column_types"`
+	Mods                                 []*mod        `spanner:"mods"`
+	ModType                              string        `spanner:"mod_type"`
+	ValueCaptureType                     string        `spanner:"value_capture_type"`
+	NumberOfRecordsInTransaction         int64         `spanner:"number_of_records_in_transaction"`
+	NumberOfPartitionsInTransaction      int64         `spanner:"number_of_partitions_in_transaction"`
+	TransactionTag                       string        `spanner:"transaction_tag"`
+	IsSystemTransaction                  bool          `spanner:"is_system_transaction"`
+}
+
+type columnType struct {
+	Name            string           `spanner:"name"`
+	Type            spanner.NullJSON `spanner:"type"`
+	IsPrimaryKey    bool             `spanner:"is_primary_key"`
+	OrdinalPosition int64            `spanner:"ordinal_position"`
+}
+
+type mod struct {
+	Keys      spanner.NullJSON `spanner:"keys"`
+	NewValues spanner.NullJSON `spanner:"new_values"`
+	OldValues spanner.NullJSON `spanner:"old_values"`
+}
+
+type heartbeatRecord struct {
+	Timestamp time.Time `spanner:"timestamp"`
+}
+
+type childPartitionsRecord struct {
+	StartTimestamp  time.Time         `spanner:"start_timestamp"`
+	RecordSequence  string            `spanner:"record_sequence"`
+	ChildPartitions []*childPartition `spanner:"child_partitions"`
+}
+
+type childPartition struct {
+	Token                 string   `spanner:"token"`
+	ParentPartitionTokens []string `spanner:"parent_partition_tokens"`
+}
+
+type userChangeStream struct {
+	client *spanner.Client
+}
+
+func NewUserChangeStream(client *spanner.Client) interfaces.UserChangeSource {
+	return &userChangeStream{client: client}
+}
+
+type streamPartition struct {
+	token spanner.NullString
+	start time.Time
+}
+
+// Changes reads every partition of the stream between since and until.
+// The first query returns the partitions that exist at since; their child
+// partitions are followed until the window is exhausted.
+func (s *userChangeStream) Changes(ctx context.Context, since, until time.Time) ([]model.UserChange, error) {
+	var changes []model.UserChange
+	queue := []streamPartition{{start: since}}
+	seen := map[string]bool{}
+
+	for len(queue) > 0 {
+		partition := queue[0]
+		queue = queue[1:]
+
+		statement := spanner.Statement{SQL: readUserChanges, Params: map[string]any{
+			"start": partition.start,
+			"end":   until,
+			"token": partition.token,
+		}}
+		err := s.client.Single().QueryWithOptions(ctx, statement, queryOptions(ctx)).Do(func(row *spanner.Row) error {
+			var records []*changeRecord
+			if err := row.Columns(&records); err != nil {
+				return err
+			}
+			for _, record := range records {
+				for _, data := range record.DataChangeRecord {
+					changes = append(changes, userChanges(data)...)
+				}
+				for _, children := range record.ChildPartitionsRecord {
+					for _, child := range children.ChildPartitions {
+						if !seen[child.Token] {
+							seen[child.Token] = true
+							queue = append(queue, streamPartition{
+								token: spanner.NullString{StringVal: child.Token, Valid: true},
+								start: children.StartTimestamp,
+							})
+						}
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// start_timestamp is inclusive, but since is the last change already seen
+	filtered := changes[:0]
+	for _, change := range changes {
+		if change.CommitTimestamp.After(since) {
+			filtered = append(filtered, change)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].CommitTimestamp.Before(filtered[j].CommitTimestamp)
+	})
+	return filtered, nil
+}
+
+func userChanges(record *dataChangeRecord) []model.UserChange {
+	changeType := model.ChangeUpdate
+	switch record.ModType {
+	case "INSERT":
+		changeType = model.ChangeCreate
+	case "DELETE":
+		changeType = model.ChangeDelete
+	}
+
+	changes := make([]model.UserChange, 0, len(record.Mods))
+	for _, mod := range record.Mods {
+		keys := jsonObject(mod.Keys)
+		id, _ := keys["id"].(string)
+		changes = append(changes, model.UserChange{
+			Type:            changeType,
+			UserID:          id,
+			CommitTimestamp: record.CommitTimestamp,
+			Old:             jsonObject(mod.OldValues),
+			New:             jsonObject(mod.NewValues),
+		})
+	}
+	return changes
+}
+
+func jsonObject(value spanner.NullJSON) map[string]any {
+	object, _ := value.Value.(map[string]any)
+	if len(object) == 0 {
+		return nil
+	}
+	return object
+}
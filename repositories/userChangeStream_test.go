@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"crudspanner/model"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserChangesFromDataChangeRecord(t *testing.T) {
+	committedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	record := &dataChangeRecord{
+		CommitTimestamp: committedAt,
+		TableName:       "users",
+		ModType:         "UPDATE",
+		Mods: []*mod{{
+			Keys:      spanner.NullJSON{Value: map[string]any{"id": testID}, Valid: true},
+			OldValues: spanner.NullJSON{Value: map[string]any{"name": "John"}, Valid: true},
+			NewValues: spanner.NullJSON{Value: map[string]any{"name": "Johnny"}, Valid: true},
+		}},
+	}
+
+	changes := userChanges(record)
+	assert.Equal(t, []model.UserChange{{
+		Type:            model.ChangeUpdate,
+		UserID:          testID,
+		CommitTimestamp: committedAt,
+		Old:             map[string]any{"name": "John"},
+		New:             map[string]any{"name": "Johnny"},
+	}}, changes)
+
+	record.ModType = "DELETE"
+	record.Mods[0].NewValues = spanner.NullJSON{}
+	changes = userChanges(record)
+	assert.Equal(t, model.ChangeDelete, changes[0].Type)
+	assert.Nil(t, changes[0].New)
+}
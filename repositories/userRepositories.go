@@ -1,40 +1,79 @@
 package repositories
 
 import (
+	"context"
 	"crudspanner/interfaces"
 	"crudspanner/model"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"time"
 
+	"cloud.google.com/go/spanner"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 	"gorm.io/gorm"
 )
 
+const (
+	maxTransactionAttempts = 5
+	transactionBackoff     = 10 * time.Millisecond
+	// serializationFailure is the SQLSTATE of PostgreSQL transactions that
+	// conflicted with another one.
+	serializationFailure = "40001"
+)
+
 type userRepository struct {
-	db *gorm.DB
+	db            *gorm.DB
+	logger        *zap.Logger
+	newID         model.IDGenerator
+	inTransaction bool
 }
 
-func NewUserRepository(db *gorm.DB) interfaces.UserRepository {
-	return &userRepository{db: db}
+// NewUserRepository returns the repository of the users in db. The keys of
+// new users are generated by newID. Spanner databases use the repository of
+// NewSpannerUserRepository instead.
+func NewUserRepository(db *gorm.DB, logger *zap.Logger, newID model.IDGenerator) interfaces.UserRepository {
+	return &userRepository{db: db, logger: logger, newID: newID}
 }
 
-func (r *userRepository) Create(user *model.User) (*model.User, error) {
-	if err := r.db.Create(user).Error; err != nil {
+func (r *userRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	if user.ID == "" {
+		id, err := r.newID()
+		if err != nil {
+			return nil, err
+		}
+		user.ID = id
+	}
+	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *userRepository) Get(id uint) (*model.User, error) {
+// whereID matches a user by key. Numeric IDs are looked up as the legacy
+// IDs of users created before string keys were introduced.
+func whereID(db *gorm.DB, id string) *gorm.DB {
+	if legacyID, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return db.Where("legacy_id = ?", legacyID)
+	}
+	return db.Where("id = ?", id)
+}
+
+func (r *userRepository) Get(ctx context.Context, id string) (*model.User, error) {
 	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
+	if err := whereID(r.db.WithContext(ctx), id).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *userRepository) Update(id uint, user *model.User) (*model.User, error) {
+func (r *userRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
 	var existingUser *model.User
 	var err error
 
-	existingUser, err = r.Get(id)
+	existingUser, err = r.Get(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -42,32 +81,152 @@ func (r *userRepository) Update(id uint, user *model.User) (*model.User, error)
 	existingUser.Email = user.Email
 	existingUser.Address = user.Address
 	existingUser.Password = user.Password
-	if err := r.db.Save(user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(user).Error; err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *userRepository) Delete(id uint) error {
-	_, err := r.Get(id)
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	user, err := r.Get(ctx, id)
 	if err != nil {
 		return err
 	}
-	return r.db.Delete(&model.User{}, id).Error
+	return r.db.WithContext(ctx).Delete(user).Error
+}
+
+func (r *userRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	var users []model.User
+	if err := r.db.WithContext(ctx).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) List(ctx context.Context, page model.Page) ([]model.User, error) {
+	query := r.db.WithContext(ctx).Order("id")
+	if page.After != "" {
+		query = query.Where("id > ?", page.After)
+	}
+	if page.Size > 0 {
+		query = query.Limit(page.Size)
+	}
+	users := []model.User{}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (r *userRepository) GetAll() ([]model.User, error) {
+func (r *userRepository) GetDeleted(ctx context.Context) ([]model.User, error) {
 	var users []model.User
-	if err := r.db.Find(&users).Error; err != nil {
+	if err := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
 }
 
-func (r *userRepository) FindByEmail(email string) (*model.User, error) {
+func (r *userRepository) FindDeleted(ctx context.Context, id string) (*model.User, error) {
 	var user model.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := whereID(r.db.WithContext(ctx).Unscoped(), id).Where("deleted_at IS NOT NULL").First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (r *userRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	result := whereID(r.db.WithContext(ctx).Unscoped().Model(&model.User{}), id).Where("deleted_at IS NOT NULL").Update("deleted_at", nil)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *userRepository) HardDelete(ctx context.Context, id string) error {
+	var user model.User
+	if err := whereID(r.db.WithContext(ctx).Unscoped(), id).First(&user).Error; err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Unscoped().Delete(&user).Error
+}
+
+func (r *userRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]model.User, error) {
+	var users []model.User
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Order("deleted_at").Order("id").Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) AddEvent(ctx context.Context, event *model.OutboxEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *userRepository) AddAuditEntry(ctx context.Context, entry *model.AuditEntry) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// Transaction retries fn with exponential backoff when the database aborts
+// the transaction because of a conflicting one. Inside a transaction fn simply
+// joins it, as Spanner has no nested transactions.
+func (r *userRepository) Transaction(ctx context.Context, fn func(repo interfaces.UserRepository) error) error {
+	if r.inTransaction {
+		return fn(r)
+	}
+
+	backoff := transactionBackoff
+	for attempt := 1; ; attempt++ {
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(&userRepository{db: tx, logger: r.logger, newID: r.newID, inTransaction: true})
+		})
+		if err == nil {
+			return nil
+		}
+		if !isAborted(err) || attempt == maxTransactionAttempts {
+			return err
+		}
+
+		wait := backoff + rand.N(backoff)
+		model.RequestLogger(ctx, r.logger).Warn("Retrying aborted transaction", zap.Int("attempt", attempt), zap.Duration("backoff", wait), zap.Error(err))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// ReadOnly reads strongly and reports no timestamp; staleness only exists
+// on Spanner.
+func (r *userRepository) ReadOnly(ctx context.Context, staleness model.Staleness, fn func(repo interfaces.UserRepository) error) (time.Time, error) {
+	return time.Time{}, fn(r)
+}
+
+// isAborted reports whether a transaction failed because of a conflicting
+// one and can be retried. Spanner databases with the PostgreSQL dialect
+// report aborted transactions as serialization failures, like PostgreSQL.
+func isAborted(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == serializationFailure
+	}
+	return spanner.ErrCode(err) == codes.Aborted
+}
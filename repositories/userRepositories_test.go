@@ -1,18 +1,28 @@
 package repositories
 
 import (
+	"context"
+	"crudspanner/interfaces"
 	"crudspanner/model"
+	"fmt"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
+const testID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
 var commonUser = model.User{
-	Model:    gorm.Model{ID: 1},
+	ID:       testID,
 	Name:     "testName",
 	Email:    "testEmail",
 	Address:  "testAddress",
@@ -51,8 +61,8 @@ func TestCreateUser(t *testing.T) {
 	mock.ExpectCommit()
 
 	// Act: Use the repository to create the user
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.Create(&commonUser)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Create(context.Background(), &commonUser)
 
 	// Assert: Verify the result and mock expectations
 	assert.NoError(t, err)
@@ -67,7 +77,7 @@ func TestCreateUserError(t *testing.T) {
 		db.Close()
 	}()
 
-	repo := NewUserRepository(mockDb)
+	repo := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
 
 	// Arrange: mock the database to simulate an error during Create
 	mock.ExpectBegin()
@@ -76,7 +86,7 @@ func TestCreateUserError(t *testing.T) {
 	mock.ExpectRollback()
 
 	// Act: attempt to create the user
-	result, err := repo.Create(&commonUser)
+	result, err := repo.Create(context.Background(), &commonUser)
 
 	// Assert: check that the error is returned
 	assert.Nil(t, result)
@@ -91,32 +101,50 @@ func TestGetUser(t *testing.T) {
 		db.Close()
 	}()
 
-	id := uint(1)
+	id := testID
 
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
-		AddRow(1, "testName", "testEmail", "testAddress")
+		AddRow(testID, "testName", "testEmail", "testAddress")
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
 		WithArgs(id, 1).
 		WillReturnRows(rows)
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.Get(id)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Get(context.Background(), id)
 
 	assert.NoError(t, err)
 	assert.Equal(t, commonUser.Name, result.Name)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetUserByLegacyID(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	rows := sqlmock.NewRows([]string{"id", "legacy_id", "name", "email", "address"}).
+		AddRow(testID, 42, "testName", "testEmail", "testAddress")
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE legacy_id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(int64(42), 1).
+		WillReturnRows(rows)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Get(context.Background(), "42")
+
+	assert.NoError(t, err)
+	assert.Equal(t, testID, result.ID)
+	assert.Equal(t, int64(42), *result.LegacyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetUserError(t *testing.T) {
 	mockDb, mock := mockDatabase()
 
-	id := uint(1)
+	id := testID
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
 		WithArgs(id, 1).
 		WillReturnError(assert.AnError)
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.Get(id)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Get(context.Background(), id)
 
 	assert.Nil(t, result)
 	assert.Error(t, err)
@@ -126,12 +154,12 @@ func TestGetUserError(t *testing.T) {
 
 func TestGetAllUsers(t *testing.T) {
 	mockDb, mock := mockDatabase()
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).AddRow(1, "testName", "testEmail", "testAddress").AddRow(2, "testName2", "testEmail2", "testAddress2")
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).AddRow(testID, "testName", "testEmail", "testAddress").AddRow(2, "testName2", "testEmail2", "testAddress2")
 
 	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`deleted_at` IS NULL$").WillReturnRows(rows)
 
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.GetAll()
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.GetAll(context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, result, 2)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -142,8 +170,8 @@ func TestGetAllUsersError(t *testing.T) {
 
 	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`deleted_at` IS NULL$").WillReturnError(assert.AnError)
 
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.GetAll()
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.GetAll(context.Background())
 	assert.Nil(t, result)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -153,19 +181,19 @@ func TestUpdateUser(t *testing.T) {
 	mockDb, mock := mockDatabase()
 
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
-		AddRow(1, "testName", "testEmail", "testAddress")
+		AddRow(testID, "testName", "testEmail", "testAddress")
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
-		WithArgs(1, 1).
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
 		WillReturnRows(rows)
 
 	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE `users` ").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	userRepository := NewUserRepository(mockDb)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
 	TestGetUser(t)
-	result, err := userRepository.Update(1, &commonUser)
+	result, err := userRepository.Update(context.Background(), testID, &commonUser)
 	assert.NoError(t, err)
 	assert.Equal(t, commonUser.Name, result.Name)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -174,12 +202,12 @@ func TestUpdateUser(t *testing.T) {
 func TestUpdateUserError(t *testing.T) {
 	mockDb, mock := mockDatabase()
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
-		WithArgs(1, 1).
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
 		WillReturnError(assert.AnError)
 
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.Update(1, &commonUser)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Update(context.Background(), testID, &commonUser)
 	assert.Nil(t, result)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -190,18 +218,18 @@ func TestUpdateUserErrorDB(t *testing.T) {
 	mockDb, mock := mockDatabase()
 
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
-		AddRow(1, "testName", "testEmail", "testAddress")
+		AddRow(testID, "testName", "testEmail", "testAddress")
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
-		WithArgs(1, 1).
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
 		WillReturnRows(rows)
 
 	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE `users` ").WillReturnError(assert.AnError)
 	mock.ExpectRollback()
 
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.Update(1, &commonUser)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Update(context.Background(), testID, &commonUser)
 	assert.Nil(t, result)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -211,17 +239,17 @@ func TestUpdateUserErrorDB(t *testing.T) {
 func TestDeleteUser(t *testing.T) {
 	mockDb, mock := mockDatabase()
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
-		AddRow(1, "testName", "testEmail", "testAddress")
+		AddRow(testID, "testName", "testEmail", "testAddress")
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
-		WithArgs(1, 1).
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
 		WillReturnRows(rows)
 	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE `users` ").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	userRepository := NewUserRepository(mockDb)
-	err := userRepository.Delete(1)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Delete(context.Background(), testID)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -229,12 +257,12 @@ func TestDeleteUser(t *testing.T) {
 func TestDeleteUserError(t *testing.T) {
 	mockDb, mock := mockDatabase()
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
-		WithArgs(1, 1).
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
 		WillReturnError(assert.AnError)
 
-	userRepository := NewUserRepository(mockDb)
-	err := userRepository.Delete(1)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Delete(context.Background(), testID)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 
@@ -244,18 +272,18 @@ func TestDeleteUserErrorDB(t *testing.T) {
 	mockDb, mock := mockDatabase()
 
 	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
-		AddRow(1, "testName", "testEmail", "testAddress")
+		AddRow(testID, "testName", "testEmail", "testAddress")
 
-	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
-		WithArgs(1, 1).
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
 		WillReturnRows(rows)
 
 	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE `users` ").WillReturnError(assert.AnError)
 	mock.ExpectRollback()
 
-	userRepository := NewUserRepository(mockDb)
-	err := userRepository.Delete(1)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Delete(context.Background(), testID)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 
@@ -266,14 +294,14 @@ func TestFindByEmail(t *testing.T) {
 
 	email := "testEmail"
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).AddRow(1, "testName", "testEmail", "testAddress")
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).AddRow(testID, "testName", "testEmail", "testAddress")
 
 	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
 		WithArgs(email, 1).
 		WillReturnRows(rows)
 
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.FindByEmail(email)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.FindByEmail(context.Background(), email)
 	assert.NoError(t, err)
 	assert.Equal(t, commonUser.Name, result.Name)
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -288,9 +316,265 @@ func TestFindByEmailError(t *testing.T) {
 		WithArgs(email, 1).
 		WillReturnError(assert.AnError)
 
-	userRepository := NewUserRepository(mockDb)
-	result, err := userRepository.FindByEmail(email)
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.FindByEmail(context.Background(), email)
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeletedUsers(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address", "deleted_at"}).
+		AddRow(testID, "testName", "testEmail", "testAddress", time.Now())
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE deleted_at IS NOT NULL$").WillReturnRows(rows)
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.GetDeleted(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.True(t, result[0].DeletedAt.Valid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindDeletedUser(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address", "deleted_at"}).
+		AddRow(testID, "testName", "testEmail", "testAddress", time.Now())
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND deleted_at IS NOT NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
+		WillReturnRows(rows)
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.FindDeleted(context.Background(), testID)
+	assert.NoError(t, err)
+	assert.Equal(t, commonUser.Name, result.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUser(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `users` SET `deleted_at`=\\?,`updated_at`=\\? WHERE id = \\? AND deleted_at IS NOT NULL$").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
+		AddRow(testID, "testName", "testEmail", "testAddress")
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
+		WillReturnRows(rows)
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Restore(context.Background(), testID)
+	assert.NoError(t, err)
+	assert.Equal(t, commonUser.Name, result.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUserNotDeleted(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `users` SET `deleted_at`=\\?,`updated_at`=\\? WHERE id = \\? AND deleted_at IS NOT NULL$").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	result, err := userRepository.Restore(context.Background(), testID)
 	assert.Nil(t, result)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHardDeleteUser(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
+		AddRow(testID, "testName", "testEmail", "testAddress")
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
+		WillReturnRows(rows)
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `users` WHERE `users`.`id` = \\?$").WithArgs(testID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.HardDelete(context.Background(), testID)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHardDeleteUserError(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.HardDelete(context.Background(), testID)
 	assert.Error(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestListDeletedBefore(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	before := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE deleted_at IS NOT NULL AND deleted_at < \\? ORDER BY deleted_at,id LIMIT \\?$").
+		WithArgs(before, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testID))
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	users, err := userRepository.ListDeletedBefore(context.Background(), before, 100)
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, testID, users[0].ID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionRetriesAbortedTransactions(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	attempts := 0
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Transaction(context.Background(), func(repo interfaces.UserRepository) error {
+		attempts++
+		if attempts == 1 {
+			return status.Error(codes.Aborted, "transaction was aborted")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionRetriesPostgresSerializationFailures(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	attempts := 0
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Transaction(context.Background(), func(repo interfaces.UserRepository) error {
+		attempts++
+		if attempts == 1 {
+			return fmt.Errorf("update users: %w", &pgconn.PgError{Code: "40001", Message: "could not serialize access"})
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionDoesNotRetryOtherErrors(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts := 0
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Transaction(context.Background(), func(repo interfaces.UserRepository) error {
+		attempts++
+		return gorm.ErrRecordNotFound
+	})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, 1, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionGivesUpAfterMaxAttempts(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	for i := 0; i < maxTransactionAttempts; i++ {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+	}
+
+	attempts := 0
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Transaction(context.Background(), func(repo interfaces.UserRepository) error {
+		attempts++
+		return status.Error(codes.Aborted, "transaction was aborted")
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.Equal(t, maxTransactionAttempts, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNestedTransactionJoinsOuter(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "address"}).
+		AddRow(testID, "testName", "testEmail", "testAddress")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id = \\? AND `users`.`deleted_at` IS NULL ORDER BY `users`.`id` LIMIT \\?$").
+		WithArgs(testID, 1).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	err := userRepository.Transaction(context.Background(), func(repo interfaces.UserRepository) error {
+		return repo.Transaction(context.Background(), func(repo interfaces.UserRepository) error {
+			_, err := repo.Get(context.Background(), testID)
+			return err
+		})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadOnlyReadsDirectly(t *testing.T) {
+	mockDb, mock := mockDatabase()
+
+	mock.ExpectQuery("^SELECT \\* FROM `users`").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	userRepository := NewUserRepository(mockDb, zap.NewNop(), model.NewID)
+	readTimestamp, err := userRepository.ReadOnly(context.Background(), model.Staleness{Duration: 15 * time.Second}, func(repo interfaces.UserRepository) error {
+		_, err := repo.GetAll(context.Background())
+		return err
+	})
+	assert.NoError(t, err)
+	assert.True(t, readTimestamp.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddEvent(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	defer func() {
+		sqlDB, _ := mockDb.DB()
+		sqlDB.Close()
+	}()
+
+	event, err := model.NewUserEvent(model.EventUserDeleted, &commonUser, false)
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `outbox_events`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, NewUserRepository(mockDb, zap.NewNop(), model.NewID).AddEvent(context.Background(), event))
+	assert.NotContains(t, event.Payload, commonUser.Password)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) interfaces.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return r.db.WithContext(ctx).Create(subscription).Error
+}
+
+func (r *webhookRepository) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *webhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	if err := r.db.WithContext(ctx).Order("created_at").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *webhookRepository) SaveSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return r.db.WithContext(ctx).Save(subscription).Error
+}
+
+// DeleteSubscription removes a subscription together with its delivery log.
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&model.WebhookSubscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *webhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		for _, delivery := range deliveries {
+			if !slices.Contains(ids, delivery.ID) {
+				ids = append(ids, delivery.ID)
+			}
+		}
+		var existing []model.WebhookDelivery
+		if err := tx.Select("subscription_id", "id").Where("id IN ?", ids).Find(&existing).Error; err != nil {
+			return err
+		}
+		exists := make(map[[2]string]bool, len(existing))
+		for _, delivery := range existing {
+			exists[[2]string{delivery.SubscriptionID, delivery.ID}] = true
+		}
+
+		var missing []model.WebhookDelivery
+		for _, delivery := range deliveries {
+			if !exists[[2]string{delivery.SubscriptionID, delivery.ID}] {
+				missing = append(missing, delivery)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		return tx.Create(&missing).Error
+	})
+}
+
+func (r *webhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+			Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			err := tx.Model(&model.WebhookDelivery{}).
+				Where("subscription_id = ? AND id = ?", delivery.SubscriptionID, delivery.ID).
+				Update("next_attempt_at", now.Add(lease)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, disableAfter int64) (bool, error) {
+	disabled := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		disabled = false
+		err := tx.Model(&model.WebhookDelivery{}).
+			Where("subscription_id = ? AND id = ?", delivery.SubscriptionID, delivery.ID).
+			Updates(map[string]any{
+				"status":           delivery.Status,
+				"attempts":         delivery.Attempts,
+				"next_attempt_at":  delivery.NextAttemptAt,
+				"last_status_code": delivery.LastStatusCode,
+				"last_error":       delivery.LastError,
+				"delivered_at":     delivery.DeliveredAt,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		var subscription model.WebhookSubscription
+		if err := tx.Where("id = ?", delivery.SubscriptionID).First(&subscription).Error; err != nil {
+			return err
+		}
+		updates := map[string]any{"consecutive_failures": int64(0)}
+		if delivery.Status != model.DeliverySucceeded {
+			if !subscription.Enabled() {
+				return nil
+			}
+			failures := subscription.ConsecutiveFailures + 1
+			updates["consecutive_failures"] = failures
+			if failures >= disableAfter {
+				disabled = true
+				updates["disabled_at"] = time.Now().UTC()
+				updates["disabled_reason"] = fmt.Sprintf("%d deliveries failed in a row", failures)
+			}
+		} else if subscription.ConsecutiveFailures == 0 {
+			return nil
+		}
+		return tx.Model(&subscription).Updates(updates).Error
+	})
+	return disabled, err
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID).
+		Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"crudspanner/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddDeliveriesSkipsExisting(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	defer func() {
+		sqlDB, _ := mockDb.DB()
+		sqlDB.Close()
+	}()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT `subscription_id`,`id` FROM `webhook_deliveries` WHERE id IN \\(\\?\\)").
+		WithArgs("event-1").
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_id", "id"}).AddRow("hook-1", "event-1"))
+	mock.ExpectExec("INSERT INTO `webhook_deliveries`").
+		WithArgs("hook-2", "event-1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := NewWebhookRepository(mockDb).AddDeliveries(context.Background(), []model.WebhookDelivery{
+		{SubscriptionID: "hook-1", ID: "event-1", Status: model.DeliveryPending},
+		{SubscriptionID: "hook-2", ID: "event-1", Status: model.DeliveryPending},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordAttemptDisablesFailingSubscription(t *testing.T) {
+	mockDb, mock := mockDatabase()
+	defer func() {
+		sqlDB, _ := mockDb.DB()
+		sqlDB.Close()
+	}()
+
+	delivery := &model.WebhookDelivery{
+		SubscriptionID: "hook-1", ID: "event-1", Status: model.DeliveryPending,
+		Attempts: 3, NextAttemptAt: time.Now(), LastStatusCode: 500, LastError: "webhook answered 500",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `webhook_deliveries` SET .* WHERE subscription_id = \\? AND id = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT \\* FROM `webhook_subscriptions` WHERE id = \\?").
+		WithArgs("hook-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "consecutive_failures"}).AddRow("hook-1", 19))
+	mock.ExpectExec("UPDATE `webhook_subscriptions` SET `consecutive_failures`=\\?,`disabled_at`=\\?,`disabled_reason`=\\?,`updated_at`=\\? WHERE `id` = \\?").
+		WithArgs(int64(20), sqlmock.AnyArg(), "20 deliveries failed in a row", sqlmock.AnyArg(), "hook-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	disabled, err := NewWebhookRepository(mockDb).RecordAttempt(context.Background(), delivery, 20)
+	assert.NoError(t, err)
+	assert.True(t, disabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
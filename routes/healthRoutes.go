@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"crudspanner/config"
+	"crudspanner/controller"
+	"crudspanner/migrations"
+	"crudspanner/repositories"
+	"crudspanner/services"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HealthRoutes mounts the probes /healthz and /readyz. The readiness check
+// covers the database and its migrations, unless MIGRATE_ON_STARTUP=off
+// leaves the schema to someone else; the in-memory repositories have
+// nothing to check. The returned service is flipped to not ready when the
+// server shuts down.
+func HealthRoutes(router *gin.Engine, logger *zap.Logger, cfg *config.Config, repos repositories.Repositories) (*services.HealthService, error) {
+	healthService := services.NewHealthService(cfg.HTTP.HealthCheckTimeout, logger)
+	if repos.DB != nil {
+		healthService.AddCheck("database", services.DatabaseCheck(repos.DB))
+		if cfg.Database.MigrationMode != migrations.StartupOff {
+			schema, err := migrations.ForDialect(repos.DB.Dialector.Name())
+			if err != nil {
+				return nil, err
+			}
+			healthService.AddCheck("migrations", services.MigrationCheck(migrations.New(repos.DB, schema)))
+		}
+	}
+
+	healthController := controller.NewHealthController(healthService)
+	router.GET("/healthz", healthController.Healthz)
+	router.GET("/readyz", healthController.Readyz)
+	return healthService, nil
+}
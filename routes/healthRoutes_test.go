@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"context"
+	"crudspanner/config"
+	"crudspanner/migrations"
+	"crudspanner/model"
+	"crudspanner/repositories"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func probe(router *gin.Engine, path string) (int, map[string]any) {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]any
+	json.Unmarshal(recorder.Body.Bytes(), &body)
+	return recorder.Code, body
+}
+
+func TestHealthRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	cfg := &config.Config{HTTP: config.HTTPServer{HealthCheckTimeout: time.Second}}
+
+	health, err := HealthRoutes(router, zap.NewNop(), cfg, repositories.NewMemoryRepositories(model.NewID))
+	require.NoError(t, err)
+
+	status, body := probe(router, "/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", body["status"])
+
+	status, body = probe(router, "/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ready", body["status"])
+
+	health.SetShuttingDown()
+	status, body = probe(router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "shutting down", body["status"])
+
+	status, _ = probe(router, "/healthz")
+	assert.Equal(t, http.StatusOK, status, "the process stays live while shutting down")
+}
+
+func TestHealthRoutesCheckMigrations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	cfg := &config.Config{HTTP: config.HTTPServer{HealthCheckTimeout: time.Second}}
+	db := openSQLite(t, ":memory:", &gorm.Config{Logger: logger.Discard})
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	_, err = HealthRoutes(router, zap.NewNop(), cfg, repositories.NewRepositories(db, zap.NewNop(), model.NewID))
+	require.NoError(t, err)
+
+	status, body := probe(router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	checks := body["checks"].(map[string]any)
+	assert.Equal(t, "ok", checks["database"].(map[string]any)["status"])
+	assert.Equal(t, "failed", checks["migrations"].(map[string]any)["status"], "the schema_migrations table does not exist yet")
+
+	schema, err := migrations.SQLite()
+	require.NoError(t, err)
+	_, err = migrations.New(db, schema).Up(context.Background())
+	require.NoError(t, err)
+	status, body = probe(router, "/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	migrationCheck := body["checks"].(map[string]any)["migrations"].(map[string]any)
+	assert.Equal(t, map[string]any{"applied": float64(len(schema)), "pending": float64(0)}, migrationCheck["details"])
+}
+
+func TestHealthRoutesSkipMigrationsWhenOff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	cfg := &config.Config{
+		HTTP:     config.HTTPServer{HealthCheckTimeout: time.Second},
+		Database: config.Database{MigrationMode: migrations.StartupOff},
+	}
+	db := openSQLite(t, ":memory:", &gorm.Config{Logger: logger.Discard})
+
+	_, err := HealthRoutes(router, zap.NewNop(), cfg, repositories.NewRepositories(db, zap.NewNop(), model.NewID))
+	require.NoError(t, err)
+
+	status, body := probe(router, "/readyz")
+	assert.Equal(t, http.StatusOK, status, "pending migrations are not checked with MIGRATE_ON_STARTUP=off")
+	assert.NotContains(t, body["checks"], "migrations")
+}
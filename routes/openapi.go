@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"crudspanner/controller"
+	"crudspanner/middleware"
+	"crudspanner/openapi"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerfiles "github.com/swaggo/files"
+	ginswagger "github.com/swaggo/gin-swagger"
+)
+
+//go:generate go run ../cmd/openapi -o ../docs/openapi.json
+
+// CurrentVersion is the API version described at /openapi.json.
+const CurrentVersion = "v1"
+
+var APIInfo = openapi.Info{
+	Title:       "User API",
+	Description: "Create, read, update and delete users stored in Cloud Spanner.",
+	Version:     "1.0",
+}
+
+// securitySchemes are the ways to authenticate to the API. The service runs
+// behind Identity-Aware Proxy, which checks the token and passes the
+// identity on in a signed header.
+var securitySchemes = map[string]openapi.SecurityScheme{
+	"iap": {
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+		Description: "OpenID Connect ID token accepted by the Identity-Aware Proxy in front of the service",
+	},
+}
+
+// NewAPIRegistry registers every resource of the public API.
+func NewAPIRegistry(userController *controller.UserController, webhookController *controller.WebhookController) *Registry {
+	registry := NewRegistry()
+	registry.Register("v1", UserResource(userController), WebhookResource(webhookController))
+	return registry
+}
+
+// OpenAPIHandler serves the OpenAPI document of an API version, which is
+// rendered once.
+func OpenAPIHandler(registry *Registry, version string) (gin.HandlerFunc, error) {
+	document, err := openapi.Marshal(registry.Document(version, APIInfo))
+	if err != nil {
+		return nil, fmt.Errorf("rendering OpenAPI document: %w", err)
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", document)
+	}, nil
+}
+
+// SwaggerUIHandler serves Swagger UI at /swagger/index.html, showing the
+// document at specURL.
+func SwaggerUIHandler(specURL string) gin.HandlerFunc {
+	return ginswagger.WrapHandler(swaggerfiles.Handler, ginswagger.URL(specURL))
+}
+
+func jsonContent(body any) map[string]any {
+	return map[string]any{"application/json": body}
+}
+
+func errorResponse(status int, description string) openapi.Response {
+	return openapi.Response{Status: status, Description: description, Content: map[string]any{middleware.ProblemContentType: controller.ErrorResponse{}}}
+}
+
+// withRequestTimeout documents the deadline every route runs under.
+func withRequestTimeout(routes []Route) []Route {
+	for i := range routes {
+		routes[i].Parameters = append(routes[i].Parameters, openapi.Parameter{
+			Name: middleware.RequestTimeoutHeader, In: "header", Description: "Deadline for the request, e.g. 5s, capped by the server maximum",
+		})
+		routes[i].Responses = append(routes[i].Responses, errorResponse(http.StatusGatewayTimeout, "Request timed out"))
+	}
+	return routes
+}
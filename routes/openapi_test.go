@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"crudspanner/controller"
+	"crudspanner/openapi"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOpenAPIDocumentCoversServedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := NewAPIRegistry(controller.NewUserController(nil, zap.NewNop()), controller.NewWebhookController(nil, zap.NewNop()))
+	router := gin.New()
+	registry.Mount(router)
+	document := registry.Document(CurrentVersion, APIInfo)
+
+	for _, route := range router.Routes() {
+		path := openapi.TemplatePath(strings.TrimPrefix(route.Path, "/api/"+CurrentVersion))
+		_, ok := documentedOperation(document, path, route.Method)
+		if !ok {
+			// Custom methods are documented under their public path.
+			ok = findCustomMethod(document, path, route.Method)
+		}
+		assert.True(t, ok, "%s %s is not documented", route.Method, route.Path)
+	}
+
+	operations := 0
+	for _, item := range document.Paths {
+		operations += len(*item)
+	}
+	assert.Equal(t, len(router.Routes()), operations, "the document describes routes that are not served")
+}
+
+func documentedOperation(document *openapi.Document, path, method string) (*openapi.OperationObject, bool) {
+	item, ok := document.Paths[path]
+	if !ok {
+		return nil, false
+	}
+	operation, ok := (*item)[strings.ToLower(method)]
+	return operation, ok
+}
+
+func findCustomMethod(document *openapi.Document, path, method string) bool {
+	for documentedPath := range document.Paths {
+		if strings.HasPrefix(documentedPath, path+":") {
+			if _, ok := documentedOperation(document, documentedPath, method); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestCommittedOpenAPIDocumentIsUpToDate(t *testing.T) {
+	registry := NewAPIRegistry(controller.NewUserController(nil, zap.NewNop()), controller.NewWebhookController(nil, zap.NewNop()))
+	generated, err := openapi.Marshal(registry.Document(CurrentVersion, APIInfo))
+	require.NoError(t, err)
+
+	committed, err := os.ReadFile("../docs/openapi.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(generated), string(committed), "docs/openapi.json is out of date, run go generate ./routes")
+}
+
+func TestOpenAPIDocumentDescribesAuthAndHidesPasswords(t *testing.T) {
+	registry := NewAPIRegistry(controller.NewUserController(nil, zap.NewNop()), controller.NewWebhookController(nil, zap.NewNop()))
+	document := registry.Document(CurrentVersion, APIInfo)
+
+	require.Contains(t, document.Components.SecuritySchemes, "iap")
+	assert.Equal(t, []openapi.SecurityRequirement{{"iap": {}}}, document.Security)
+
+	user := document.Components.Schemas["User"]
+	require.NotNil(t, user)
+	assert.Contains(t, user.Properties, "email")
+	assert.NotContains(t, user.Properties, "password")
+	assert.Contains(t, document.Components.Schemas["RegisterUserRequest"].Properties, "password")
+}
+
+func TestOpenAPIHandlerServesDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := NewAPIRegistry(controller.NewUserController(nil, zap.NewNop()), controller.NewWebhookController(nil, zap.NewNop()))
+	router := gin.New()
+	handler, err := OpenAPIHandler(registry, CurrentVersion)
+	require.NoError(t, err)
+	router.GET("/openapi.json", handler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	committed, err := os.ReadFile("../docs/openapi.json")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, string(committed), recorder.Body.String())
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"crudspanner/openapi"
+	"maps"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route is a handler together with its OpenAPI description, so that the
+// served routes and the published document come from the same table.
+type Route struct {
+	openapi.Route
+	Handler gin.HandlerFunc
+}
+
+// Resource is a set of routes mounted relative to /api/<version>/<Name>.
+type Resource struct {
+	Name   string
+	Routes []Route
+}
+
+// Registry collects resources per API version so that several versions
+// can be served side by side.
+type Registry struct {
+	versions   []string
+	resources  map[string][]Resource
+	middleware map[string][]gin.HandlerFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		resources:  make(map[string][]Resource),
+		middleware: make(map[string][]gin.HandlerFunc),
+	}
+}
+
+func (r *Registry) addVersion(version string) {
+	if _, ok := r.resources[version]; !ok {
+		r.versions = append(r.versions, version)
+		r.resources[version] = nil
+	}
+}
+
+// Register adds a resource to an API version such as "v1".
+func (r *Registry) Register(version string, resources ...Resource) {
+	r.addVersion(version)
+	r.resources[version] = append(r.resources[version], resources...)
+}
+
+// Use adds middleware that runs for every resource of an API version.
+func (r *Registry) Use(version string, middleware ...gin.HandlerFunc) {
+	r.addVersion(version)
+	r.middleware[version] = append(r.middleware[version], middleware...)
+}
+
+// Mount mounts every registered version under /api/<version>/<resource>.
+func (r *Registry) Mount(router gin.IRouter) {
+	for _, version := range r.versions {
+		versionGroup := router.Group("/api/"+version, r.middleware[version]...)
+		for _, resource := range r.resources[version] {
+			group := versionGroup.Group("/" + resource.Name)
+			for _, route := range resource.Routes {
+				group.Handle(route.Method, route.Path, route.Handler)
+			}
+		}
+	}
+}
+
+// Document describes every resource of an API version.
+func (r *Registry) Document(version string, info openapi.Info) *openapi.Document {
+	builder := openapi.NewBuilder(info, openapi.Server{URL: "/api/" + version})
+	for _, name := range slices.Sorted(maps.Keys(securitySchemes)) {
+		builder.AddSecurityScheme(name, securitySchemes[name])
+	}
+	for _, resource := range r.resources[version] {
+		for _, route := range resource.Routes {
+			builder.Add("/"+resource.Name, route.Route)
+		}
+	}
+	return builder.Document()
+}
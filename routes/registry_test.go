@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"crudspanner/middleware"
+	"crudspanner/openapi"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func resource(name, body string) Resource {
+	return Resource{
+		Name: name,
+		Routes: []Route{{
+			Route: openapi.Route{Method: http.MethodGet, Path: "/:id", OperationID: "get" + name},
+			Handler: func(c *gin.Context) {
+				c.String(http.StatusOK, body+" "+c.Param("id"))
+			},
+		}},
+	}
+}
+
+func TestRegistryMountsVersionsSideBySide(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	registry := NewRegistry()
+	registry.Register("v1", resource("users", "v1 user"), resource("groups", "v1 group"))
+	registry.Register("v2", resource("users", "v2 user"))
+	registry.Use("v2", func(c *gin.Context) {
+		c.Header("X-Version", "2")
+	})
+	registry.Mount(router)
+
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		xVersion string
+	}{
+		{path: "/api/v1/users/1", status: http.StatusOK, body: "v1 user 1"},
+		{path: "/api/v1/groups/2", status: http.StatusOK, body: "v1 group 2"},
+		{path: "/api/v2/users/3", status: http.StatusOK, body: "v2 user 3", xVersion: "2"},
+		{path: "/api/v2/groups/4", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.body, recorder.Body.String())
+			}
+			assert.Equal(t, tt.xVersion, recorder.Header().Get("X-Version"))
+		})
+	}
+}
+
+func TestRegistryDocumentsOneVersion(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("v1", resource("users", "v1 user"))
+	registry.Register("v2", resource("groups", "v2 group"))
+
+	document := registry.Document("v2", openapi.Info{Title: "test", Version: "2"})
+
+	assert.Equal(t, []openapi.Server{{URL: "/api/v2"}}, document.Servers)
+	assert.Len(t, document.Paths, 1)
+	assert.Contains(t, *document.Paths["/groups/{id}"], "get")
+}
+
+func TestDeprecatedRoutesAdvertiseSuccessor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	router.GET("/users", middleware.Deprecated("/api/v1/users", sunset), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	assert.Equal(t, "true", recorder.Header().Get("Deprecation"))
+	assert.Equal(t, "Tue, 01 Jan 2030 00:00:00 GMT", recorder.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/users>; rel="successor-version"`, recorder.Header().Get("Link"))
+}
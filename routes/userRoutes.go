@@ -1,29 +1,329 @@
 package routes
 
 import (
+	"crudspanner/config"
 	"crudspanner/controller"
+	"crudspanner/interfaces"
+	"crudspanner/middleware"
+	"crudspanner/model"
+	"crudspanner/openapi"
+	"crudspanner/publishers"
 	"crudspanner/repositories"
 	"crudspanner/services"
+	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
-func UserRoutes(router *gin.Engine, logger *zap.Logger, db *gorm.DB) {
+// UserRoutes mounts the API on router and starts the background workers.
+// The router must already use middleware.RequestInfo. The returned
+// function stops the workers and waits for them to finish.
+func UserRoutes(router *gin.Engine, logger *zap.Logger, cfg *config.Config, repos repositories.Repositories) (stop func(), err error) {
 
-	userRepo := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepo)
+	userService := services.NewUserService(repos.Users, logger)
 
-	userController := controller.NewUserController(userService)
+	userController := controller.NewUserController(userService, logger)
+	userController.SetReadStaleness(cfg.ReadStaleness, cfg.ListReadStaleness)
+	userController.SetAuditService(services.NewAuditService(repos.Audit))
 
-	router.GET("/:id", userController.GetUserByID)
+	if repos.Changes != nil {
+		changeFeed := services.NewChangeFeed(repos.Changes, cfg.ChangePollInterval)
+		userController.SetChangeFeed(changeFeed)
+	}
 
-	router.POST("/", userController.RegistrationUser)
+	webhookService := services.NewWebhookService(repos.Webhooks, publishers.NewWebhookClient(10*time.Second), logger)
+	webhookController := controller.NewWebhookController(webhookService, logger)
 
-	router.GET("/users", userController.GetAllUsers)
+	registry := NewAPIRegistry(userController, webhookController)
+	openAPIHandler, err := OpenAPIHandler(registry, CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
 
-	router.PUT("/:id", userController.UpdateUser)
+	webhookWorker := services.NewWebhookWorker(webhookService, cfg.WebhookDeliveryInterval, logger)
+	webhookWorker.Start()
+	stops := []func(){webhookWorker.Stop}
 
-	router.DELETE("/:id", userController.DeleteUser)
+	var publisher interfaces.Publisher = webhookService
+	if cfg.OutboxWebhookURL != "" {
+		// The URL is set by the operator, so it may be an internal address.
+		outboxClient := &http.Client{Timeout: 10 * time.Second}
+		publisher = publishers.Fanout(webhookService, publishers.NewHTTPPublisher(cfg.OutboxWebhookURL, outboxClient))
+	}
+	outboxRelay := services.NewOutboxRelay(repos.Outbox, publisher, cfg.OutboxRelayInterval, logger)
+	outboxRelay.Start()
+	stops = append(stops, outboxRelay.Stop)
+
+	if cfg.OutboxRetention > 0 {
+		outboxPruner := services.NewOutboxPruner(repos.Outbox, cfg.OutboxRetention, cfg.PurgeInterval, logger)
+		outboxPruner.Start()
+		stops = append(stops, outboxPruner.Stop)
+	}
+
+	if cfg.SoftDeleteRetention > 0 {
+		purgeWorker := services.NewPurgeWorker(userService, cfg.SoftDeleteRetention, cfg.PurgeInterval, logger)
+		purgeWorker.Start()
+		stops = append(stops, purgeWorker.Stop)
+	}
+
+	router.Use(middleware.Timeout(cfg.RequestTimeout, cfg.MaxRequestTimeout))
+
+	registry.Mount(router)
+	router.GET("/openapi.json", openAPIHandler)
+	router.GET("/swagger/*any", SwaggerUIHandler("/openapi.json"))
+
+	if cfg.LegacyRoutesEnabled {
+		legacyUserRoutes(router, userController, middleware.Deprecated("/api/v1/users", cfg.LegacyRoutesSunset))
+	}
+
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}, nil
+}
+
+// UserResource serves the user endpoints under /api/<version>/users.
+func UserResource(userController *controller.UserController) Resource {
+	userID := openapi.Parameter{Name: "id", In: "path", Description: "User ID", Type: ""}
+	staleness := openapi.Parameter{
+		Name: "staleness", In: "query", Type: "",
+		Description: "How old the returned data may be: strong, an exact staleness such as 15s, or a bound such as max:15s. At most 1h.",
+	}
+	readTimestamp := map[string]string{controller.ReadTimestampHeader: "Timestamp of the data for reads with exact staleness on Spanner"}
+	listHeaders := map[string]string{
+		controller.ReadTimestampHeader: readTimestamp[controller.ReadTimestampHeader],
+		controller.NextPageTokenHeader: "Token of the next page, missing on the last page",
+	}
+	userBody := jsonContent(model.User{})
+	users := []string{"users"}
+	admin := []string{"admin"}
+
+	return Resource{
+		Name: "users",
+		Routes: withRequestTimeout([]Route{
+			{
+				Handler: userController.GetAllUsers,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "", OperationID: "listUsers", Tags: users,
+					Summary:     "List all users",
+					Description: "Returns the users ordered by ID. With page_size the users are returned a page at a time; pass the X-Next-Page-Token header of the answer as page_token to get the next page. Without page_size all users are returned.",
+					Parameters: []openapi.Parameter{
+						staleness,
+						{Name: "page_size", In: "query", Description: fmt.Sprintf("Number of users per page, at most %d", model.MaxPageSize), Type: 0},
+						{Name: "page_token", In: "query", Description: "Token of the page to return, from the X-Next-Page-Token header of the previous page", Type: ""},
+					},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Headers: listHeaders, Content: jsonContent([]model.User{})},
+						errorResponse(http.StatusBadRequest, "Invalid staleness or page"),
+						errorResponse(http.StatusInternalServerError, "Could not retrieve users"),
+					},
+				},
+			},
+			{
+				Handler: userController.RegistrationUser,
+				Route: openapi.Route{
+					Method: http.MethodPost, Path: "", OperationID: "registerUser", Tags: users,
+					Summary:     "Register a new user",
+					RequestBody: &openapi.RequestBody{Description: "User data", Required: true, Content: jsonContent(controller.RegisterUserRequest{})},
+					Responses: []openapi.Response{
+						{Status: http.StatusCreated, Content: userBody},
+						errorResponse(http.StatusBadRequest, "Invalid input or email already registered"),
+						errorResponse(http.StatusInternalServerError, "Failed to create user"),
+					},
+				},
+			},
+			{
+				Handler: userController.ImportUsers,
+				Route: openapi.Route{
+					Method: http.MethodPost, Path: "/import", OperationID: "importUsers", Tags: users,
+					Summary:     "Import users from CSV",
+					Description: "Creates users from an uploaded CSV file. With dry_run=true every line is validated but nothing is written. With report=csv the rejected lines are returned as a downloadable CSV instead of JSON.",
+					Parameters: []openapi.Parameter{
+						{Name: "dry_run", In: "query", Description: "Validate without creating users", Type: false},
+						{Name: "report", In: "query", Description: "Set to csv to download the error report", Enum: []any{"csv"}},
+					},
+					RequestBody: &openapi.RequestBody{
+						Required: true,
+						Content: map[string]any{"multipart/form-data": &openapi.Schema{
+							Type: "object",
+							Properties: map[string]*openapi.Schema{
+								"file":    openapi.Binary,
+								"mapping": {Type: "string", Description: "JSON object mapping user fields (name, email, address, password) to CSV headers"},
+							},
+						}},
+					},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: map[string]any{"application/json": services.ImportResult{}, "text/csv": openapi.Text}},
+						errorResponse(http.StatusBadRequest, "Invalid file, mapping or header"),
+						errorResponse(http.StatusInternalServerError, "Failed to import users"),
+					},
+				},
+			},
+			{
+				Handler: userController.GetDeletedUsers,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "/deleted", OperationID: "listDeletedUsers", Tags: admin,
+					Summary: "List soft-deleted users",
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent([]model.User{})},
+						errorResponse(http.StatusInternalServerError, "Could not retrieve deleted users"),
+					},
+				},
+			},
+			{
+				Handler: userController.GetUserChanges,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "/changes", OperationID: "listUserChanges", Tags: users,
+					Summary:     "Wait for user changes",
+					Description: "Long-polls for users created, updated or deleted after since. Answers as soon as there are changes or after wait with no changes. Pass next of the answer as since of the following request. Soft deletes are reported as deletes and restores as creates; password hashes are never included.",
+					Parameters: []openapi.Parameter{
+						{Name: "since", In: "query", Description: "Return changes committed after this time (default now)", Type: time.Time{}},
+						{Name: "wait", In: "query", Description: "How long to wait for changes, at most 1m (default 20s)", Type: ""},
+					},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent(services.ChangeBatch{})},
+						errorResponse(http.StatusBadRequest, "Invalid since or wait"),
+						errorResponse(http.StatusGone, "since is older than the change retention period"),
+						errorResponse(http.StatusInternalServerError, "Could not read changes"),
+						errorResponse(http.StatusServiceUnavailable, "Change feed is not available"),
+					},
+				},
+			},
+			{
+				Handler: userController.GetUserByID,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "/:id", OperationID: "getUser", Tags: users,
+					Summary:    "Get user by ID",
+					Parameters: []openapi.Parameter{userID, staleness},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Headers: readTimestamp, Content: userBody},
+						errorResponse(http.StatusBadRequest, "Invalid ID or staleness"),
+						errorResponse(http.StatusNotFound, "User not found"),
+						errorResponse(http.StatusInternalServerError, "Could not retrieve user"),
+					},
+				},
+			},
+			{
+				Handler: userController.GetUserAudit,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "/:id/audit", OperationID: "listUserAuditEntries", Tags: admin,
+					Summary:     "List the audit log of a user",
+					Description: "Returns who changed the user, from which IP and in which request, with the changed fields, newest first. Password changes are recorded only as changed. Entries of permanently deleted users are kept.",
+					Parameters: []openapi.Parameter{
+						userID,
+						{Name: "actor", In: "query", Description: "Only changes made by this actor", Type: ""},
+						{Name: "action", In: "query", Description: "Only changes of this kind", Enum: []any{model.AuditCreate, model.AuditUpdate, model.AuditDelete, model.AuditRestore, model.AuditPermanentDelete}},
+						{Name: "field", In: "query", Description: "Only changes of this field", Enum: []any{"name", "email", "address", "password"}},
+						{Name: "since", In: "query", Description: "Only changes made at or after this time", Type: time.Time{}},
+						{Name: "until", In: "query", Description: "Only changes made before this time", Type: time.Time{}},
+						{Name: "limit", In: "query", Description: "Number of entries (default 50, at most 500)", Type: 0},
+					},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent([]model.AuditEntry{})},
+						errorResponse(http.StatusBadRequest, "Invalid filter"),
+						errorResponse(http.StatusInternalServerError, "Could not retrieve audit log"),
+						errorResponse(http.StatusServiceUnavailable, "Audit log is not available"),
+					},
+				},
+			},
+			{
+				Handler: userController.UpdateUser,
+				Route: openapi.Route{
+					Method: http.MethodPut, Path: "/:id", OperationID: "updateUser", Tags: users,
+					Summary:     "Update user information",
+					Description: "Only non-empty name, email and address fields are applied.",
+					Parameters:  []openapi.Parameter{userID},
+					RequestBody: &openapi.RequestBody{Description: "Updated user data", Required: true, Content: jsonContent(controller.UpdateUserRequest{})},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: userBody},
+						errorResponse(http.StatusBadRequest, "Invalid ID or body"),
+						errorResponse(http.StatusNotFound, "User not found"),
+						errorResponse(http.StatusConflict, "Email already in use"),
+						errorResponse(http.StatusInternalServerError, "Could not update user"),
+					},
+				},
+			},
+			{
+				Handler: userController.PatchUser,
+				Route: openapi.Route{
+					Method: http.MethodPatch, Path: "/:id", OperationID: "patchUser", Tags: users,
+					Summary:     "Change some fields of a user",
+					Description: "Sets the fields present in the body. An empty address clears it; the name and email cannot be cleared.",
+					Parameters:  []openapi.Parameter{userID},
+					RequestBody: &openapi.RequestBody{Description: "Fields to change", Required: true, Content: map[string]any{
+						"application/merge-patch+json": controller.PatchUserRequest{},
+						"application/json":             controller.PatchUserRequest{},
+					}},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: userBody},
+						errorResponse(http.StatusBadRequest, "Invalid ID or body"),
+						errorResponse(http.StatusNotFound, "User not found"),
+						errorResponse(http.StatusConflict, "Email already in use"),
+						errorResponse(http.StatusInternalServerError, "Could not update user"),
+					},
+				},
+			},
+			{
+				Handler: userController.DeleteUser,
+				Route: openapi.Route{
+					Method: http.MethodDelete, Path: "/:id", OperationID: "deleteUser", Tags: users,
+					Summary:     "Delete a user",
+					Description: "Soft-deletes the user, or removes it permanently with hard=true. Permanent removal also applies to users that are already soft-deleted.",
+					Parameters: []openapi.Parameter{
+						userID,
+						{Name: "hard", In: "query", Description: "Permanently remove the user", Type: false},
+					},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent(controller.MessageResponse{})},
+						errorResponse(http.StatusBadRequest, "Invalid ID"),
+						errorResponse(http.StatusNotFound, "User not found"),
+						errorResponse(http.StatusInternalServerError, "Could not delete user"),
+					},
+				},
+			},
+			{
+				Handler: userController.UserAction,
+				Route: openapi.Route{
+					Method: http.MethodPost, Path: "/:id", DocPath: "/:id:restore", OperationID: "restoreUser", Tags: admin,
+					Summary:    "Restore a soft-deleted user",
+					Parameters: []openapi.Parameter{userID},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: userBody},
+						errorResponse(http.StatusBadRequest, "Invalid ID"),
+						errorResponse(http.StatusNotFound, "Deleted user not found"),
+						errorResponse(http.StatusConflict, "Email already in use"),
+						errorResponse(http.StatusInternalServerError, "Could not restore user"),
+					},
+				},
+			},
+		}),
+	}
+}
+
+// legacyUserRoutes keeps the unversioned paths working while clients move
+// to /api/v1/users. They render users with the field names they always had.
+func legacyUserRoutes(router *gin.Engine, userController *controller.UserController, deprecated gin.HandlerFunc) {
+	legacy := router.Group("", deprecated, controller.LegacyResponses)
+
+	legacy.GET("/:id", userController.GetUserByID)
+
+	legacy.POST("/", userController.RegistrationUser)
+
+	legacy.GET("/users", userController.GetAllUsers)
+
+	legacy.PUT("/:id", userController.UpdateUser)
+
+	legacy.DELETE("/:id", userController.DeleteUser)
+
+	legacy.POST("/users/import", userController.ImportUsers)
+
+	legacy.GET("/users/deleted", userController.GetDeletedUsers)
+
+	legacy.POST("/users/:id", userController.UserAction)
+
+	legacy.DELETE("/users/:id", userController.DeleteUser)
 }
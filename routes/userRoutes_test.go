@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"crudspanner/controller"
+	"crudspanner/model"
+	"crudspanner/repositories"
+	"crudspanner/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLegacyRoutesKeepFieldNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repos := repositories.NewMemoryRepositories(model.NewID)
+	userController := controller.NewUserController(services.NewUserService(repos.Users, zap.NewNop()), zap.NewNop())
+	router := gin.New()
+	NewAPIRegistry(userController, nil).Mount(router)
+	legacyUserRoutes(router, userController, func(c *gin.Context) { c.Next() })
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Name":"john","Email":"john@example.com","Password":"secret123"}`)))
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var legacy map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &legacy))
+	assert.ElementsMatch(t, []string{"ID", "CreatedAt", "UpdatedAt", "DeletedAt", "Name", "Email", "Address"}, keys(legacy))
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/users/"+legacy["ID"].(string), nil))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var versioned map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &versioned))
+	assert.Equal(t, "john", versioned["name"])
+	assert.NotContains(t, versioned, "Name")
+}
+
+func TestSwaggerUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/swagger/*any", SwaggerUIHandler("/openapi.json"))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `url: "\/openapi.json"`, "the spec URL is escaped for JavaScript")
+}
+
+func keys(object map[string]any) []string {
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestUserRoutesAnswerNotFoundForMissingUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repos := repositories.NewMemoryRepositories(model.NewID)
+	userController := controller.NewUserController(services.NewUserService(repos.Users, zap.NewNop()), zap.NewNop())
+	router := gin.New()
+	NewAPIRegistry(userController, nil).Mount(router)
+
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/v1/users/missing", ""},
+		{http.MethodPut, "/api/v1/users/missing", `{"name":"john"}`},
+		{http.MethodPatch, "/api/v1/users/missing", `{"name":"john"}`},
+		{http.MethodDelete, "/api/v1/users/missing", ""},
+		{http.MethodDelete, "/api/v1/users/missing?hard=true", ""},
+		{http.MethodPost, "/api/v1/users/missing:restore", ""},
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(request.method, request.path, strings.NewReader(request.body)))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "%s %s: %s", request.method, request.path, recorder.Body.String())
+	}
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"crudspanner/controller"
+	"crudspanner/model"
+	"crudspanner/openapi"
+	"crudspanner/services"
+	"net/http"
+)
+
+// WebhookResource serves the webhook subscriptions under
+// /api/<version>/webhooks.
+func WebhookResource(webhookController *controller.WebhookController) Resource {
+	webhookID := openapi.Parameter{Name: "id", In: "path", Description: "Webhook subscription ID", Type: ""}
+	inputBody := jsonContent(services.WebhookInput{})
+	webhookBody := jsonContent(model.WebhookSubscription{})
+	webhooks := []string{"webhooks"}
+
+	return Resource{
+		Name: "webhooks",
+		Routes: withRequestTimeout([]Route{
+			{
+				Handler: webhookController.GetWebhooks,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "", OperationID: "listWebhooks", Tags: webhooks,
+					Summary: "List webhook subscriptions",
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent([]model.WebhookSubscription{})},
+						errorResponse(http.StatusInternalServerError, "Could not retrieve webhooks"),
+					},
+				},
+			},
+			{
+				Handler: webhookController.CreateWebhook,
+				Route: openapi.Route{
+					Method: http.MethodPost, Path: "", OperationID: "createWebhook", Tags: webhooks,
+					Summary: "Subscribe to user events",
+					Description: "Every delivery is a POST of the event envelope with the headers " +
+						"X-Webhook-ID (the event ID, for deduplication), X-Webhook-Timestamp (Unix seconds) and " +
+						"X-Webhook-Signature: sha256= followed by the hex HMAC-SHA256 of <timestamp>.<body> keyed with the secret. " +
+						"Failed deliveries are retried with exponential backoff; subscriptions whose deliveries keep failing are disabled. " +
+						"The secret is generated if omitted and only returned by this request.",
+					RequestBody: &openapi.RequestBody{Description: "Subscription; eventTypes defaults to all events", Required: true, Content: inputBody},
+					Responses: []openapi.Response{
+						{Status: http.StatusCreated, Content: webhookBody},
+						errorResponse(http.StatusBadRequest, "Invalid URL, event type or secret"),
+					},
+				},
+			},
+			{
+				Handler: webhookController.GetWebhook,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "/:id", OperationID: "getWebhook", Tags: webhooks,
+					Summary:    "Get a webhook subscription",
+					Parameters: []openapi.Parameter{webhookID},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: webhookBody},
+						errorResponse(http.StatusNotFound, "Webhook subscription not found"),
+					},
+				},
+			},
+			{
+				Handler: webhookController.UpdateWebhook,
+				Route: openapi.Route{
+					Method: http.MethodPut, Path: "/:id", OperationID: "updateWebhook", Tags: webhooks,
+					Summary:     "Update a webhook subscription",
+					Description: "Only the fields that are set are changed. A secret rotates the signing secret. enabled=true re-enables a disabled subscription and resets its failure count.",
+					Parameters:  []openapi.Parameter{webhookID},
+					RequestBody: &openapi.RequestBody{Description: "Changed fields", Required: true, Content: inputBody},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: webhookBody},
+						errorResponse(http.StatusBadRequest, "Invalid URL, event type or secret"),
+						errorResponse(http.StatusNotFound, "Webhook subscription not found"),
+					},
+				},
+			},
+			{
+				Handler: webhookController.DeleteWebhook,
+				Route: openapi.Route{
+					Method: http.MethodDelete, Path: "/:id", OperationID: "deleteWebhook", Tags: webhooks,
+					Summary:    "Delete a webhook subscription and its delivery log",
+					Parameters: []openapi.Parameter{webhookID},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent(controller.MessageResponse{})},
+						errorResponse(http.StatusNotFound, "Webhook subscription not found"),
+					},
+				},
+			},
+			{
+				Handler: webhookController.GetWebhookDeliveries,
+				Route: openapi.Route{
+					Method: http.MethodGet, Path: "/:id/deliveries", OperationID: "listWebhookDeliveries", Tags: webhooks,
+					Summary: "List recent deliveries of a webhook subscription",
+					Parameters: []openapi.Parameter{
+						webhookID,
+						{Name: "limit", In: "query", Description: "Number of deliveries, newest first (default 50, at most 500)", Type: 0},
+					},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Content: jsonContent([]model.WebhookDelivery{})},
+						errorResponse(http.StatusBadRequest, "Invalid limit"),
+						errorResponse(http.StatusNotFound, "Webhook subscription not found"),
+					},
+				},
+			},
+		}),
+	}
+}
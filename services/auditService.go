@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 50
+	// MaxAuditLimit is the most entries GetUserAudit returns.
+	MaxAuditLimit = 500
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+var (
+	auditActions = []string{model.AuditCreate, model.AuditUpdate, model.AuditDelete, model.AuditRestore, model.AuditPermanentDelete}
+	auditFields  = []string{"name", "email", "address", "password"}
+)
+
+type AuditService interface {
+	// GetUserAudit returns the audit entries of a user, newest first. The
+	// entries of permanently deleted users are kept and still returned.
+	GetUserAudit(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+type auditService struct {
+	repo interfaces.AuditRepository
+}
+
+func NewAuditService(repo interfaces.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) GetUserAudit(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	if filter.Action != "" && !slices.Contains(auditActions, filter.Action) {
+		return nil, fmt.Errorf("%w: action must be one of %v", ErrInvalidAuditFilter, auditActions)
+	}
+	if filter.Field != "" && !slices.Contains(auditFields, filter.Field) {
+		return nil, fmt.Errorf("%w: field must be one of %v", ErrInvalidAuditFilter, auditFields)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, MaxAuditLimit)
+	return s.repo.List(ctx, userID, filter)
+}
+
+// addAuditEntry records a change of a user, made by the actor of the request
+// in ctx, in the transaction of repo.
+func addAuditEntry(ctx context.Context, repo interfaces.UserRepository, action, userID string, changes []model.FieldChange) error {
+	id, err := model.NewID()
+	if err != nil {
+		return err
+	}
+	if changes == nil {
+		changes = []model.FieldChange{}
+	}
+	info := model.RequestInfoFrom(ctx)
+	return repo.AddAuditEntry(ctx, &model.AuditEntry{
+		ID:        id,
+		UserID:    userID,
+		Action:    action,
+		Actor:     info.Actor,
+		ClientIP:  info.ClientIP,
+		RequestID: info.RequestID,
+		Changes:   changes,
+		CreatedAt: time.Now().UTC(),
+	})
+}
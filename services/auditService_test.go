@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"crudspanner/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type fakeAuditRepository struct {
+	filter model.AuditFilter
+}
+
+func (r *fakeAuditRepository) List(ctx context.Context, userID string, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	r.filter = filter
+	return []model.AuditEntry{{UserID: userID}}, nil
+}
+
+func TestUserService_UpdateUserRecordsAuditEntry(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	existing := &model.User{ID: "user-1", Name: "John", Email: "john@example.com", Address: "Main St", Password: "hash"}
+	mockRepo.On("Get", mock.Anything, "user-1").Return(existing, nil)
+	mockRepo.On("Update", mock.Anything, "user-1", mock.Anything).Return(&model.User{
+		ID: "user-1", Name: "Johnny", Email: "john@example.com", Address: "Main St", Password: "hash",
+	}, nil)
+
+	ctx := model.WithRequestInfo(context.Background(), model.RequestInfo{Actor: "admin@example.com", ClientIP: "10.0.0.1", RequestID: "req-1"})
+	_, err := userService.UpdateUser(ctx, "user-1", &model.User{Name: "Johnny"})
+	require.NoError(t, err)
+
+	require.Len(t, mockRepo.auditEntries, 1)
+	entry := mockRepo.auditEntries[0]
+	assert.Equal(t, model.AuditUpdate, entry.Action)
+	assert.Equal(t, "user-1", entry.UserID)
+	assert.Equal(t, "admin@example.com", entry.Actor)
+	assert.Equal(t, "10.0.0.1", entry.ClientIP)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Equal(t, []model.FieldChange{{Field: "name", Before: "John", After: "Johnny"}}, entry.Changes)
+}
+
+func TestUserService_RegistrationAuditsPasswordAsChanged(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(&model.User{ID: "user-1", Name: "John", Email: "john@example.com", Password: "hash"}, nil)
+
+	_, err := userService.Registration(context.Background(), &model.User{Name: "John", Email: "john@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	require.Len(t, mockRepo.auditEntries, 1)
+	entry := mockRepo.auditEntries[0]
+	assert.Equal(t, model.AuditCreate, entry.Action)
+	assert.Equal(t, model.UnknownActor, entry.Actor)
+	assert.Equal(t, []model.FieldChange{
+		{Field: "name", After: "John"},
+		{Field: "email", After: "john@example.com"},
+		{Field: "password", After: model.PasswordChanged},
+	}, entry.Changes)
+}
+
+func TestUserService_DeleteUserRecordsAuditEntry(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("Get", mock.Anything, "user-1").Return(&model.User{ID: "user-1"}, nil)
+	mockRepo.On("Delete", mock.Anything, "user-1").Return(nil)
+
+	require.NoError(t, userService.DeleteUser(context.Background(), "user-1"))
+	require.Len(t, mockRepo.auditEntries, 1)
+	assert.Equal(t, model.AuditDelete, mockRepo.auditEntries[0].Action)
+	assert.Empty(t, mockRepo.auditEntries[0].Changes)
+}
+
+func TestAuditService_GetUserAudit(t *testing.T) {
+	repo := &fakeAuditRepository{}
+	auditService := NewAuditService(repo)
+
+	entries, err := auditService.GetUserAudit(context.Background(), "user-1", model.AuditFilter{Action: model.AuditUpdate, Field: "email"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, defaultAuditLimit, repo.filter.Limit)
+
+	_, err = auditService.GetUserAudit(context.Background(), "user-1", model.AuditFilter{Action: "rename"})
+	assert.ErrorIs(t, err, ErrInvalidAuditFilter)
+	_, err = auditService.GetUserAudit(context.Background(), "user-1", model.AuditFilter{Field: "deleted_at"})
+	assert.ErrorIs(t, err, ErrInvalidAuditFilter)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"errors"
+	"time"
+)
+
+// ChangeRetention is how long the users_changes change stream keeps
+// changes (see migration 0003).
+const ChangeRetention = 7 * 24 * time.Hour
+
+var ErrChangesExpired = errors.New("since is older than the change retention period")
+
+// ChangeBatch is one answer of the change feed. Next is passed as since to
+// get the changes that follow.
+type ChangeBatch struct {
+	Changes []model.UserChange `json:"changes"`
+	Next    time.Time          `json:"next"`
+}
+
+// ChangeFeed long-polls a change source for user changes.
+type ChangeFeed struct {
+	source       interfaces.UserChangeSource
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+func NewChangeFeed(source interfaces.UserChangeSource, pollInterval time.Duration) *ChangeFeed {
+	return &ChangeFeed{source: source, pollInterval: pollInterval, now: time.Now}
+}
+
+// Wait returns the changes committed after since. If there are none yet it
+// polls the source until some arrive or wait has passed, and then returns
+// an empty batch whose Next lets the caller continue without gaps.
+func (f *ChangeFeed) Wait(ctx context.Context, since time.Time, wait time.Duration) (*ChangeBatch, error) {
+	if since.Before(f.now().Add(-ChangeRetention)) {
+		return nil, ErrChangesExpired
+	}
+
+	deadline := f.now().Add(wait)
+	for {
+		until := f.now()
+		if until.Before(since) {
+			until = since
+		}
+		changes, err := f.source.Changes(ctx, since, until)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 || !until.Before(deadline) {
+			for i := range changes {
+				redactChange(&changes[i])
+			}
+			return &ChangeBatch{Changes: changes, Next: until}, nil
+		}
+
+		since = until
+		timer := time.NewTimer(min(f.pollInterval, deadline.Sub(until)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// redactChange drops password hashes and reports soft deletes and restores
+// as the deletes and creates they are for API users.
+func redactChange(change *model.UserChange) {
+	delete(change.Old, "password")
+	delete(change.New, "password")
+
+	if change.Type != model.ChangeUpdate {
+		return
+	}
+	oldDeleted, oldOK := change.Old["deleted_at"]
+	newDeleted, newOK := change.New["deleted_at"]
+	switch {
+	case newOK && newDeleted != nil && (!oldOK || oldDeleted == nil):
+		change.Type = model.ChangeDelete
+	case oldOK && oldDeleted != nil && newOK && newDeleted == nil:
+		change.Type = model.ChangeCreate
+	}
+}
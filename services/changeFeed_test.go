@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"crudspanner/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChangeSource serves changes from memory like the users_changes stream.
+type fakeChangeSource struct {
+	mu      sync.Mutex
+	changes []model.UserChange
+	calls   int
+}
+
+func (s *fakeChangeSource) add(change model.UserChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, change)
+}
+
+func (s *fakeChangeSource) Changes(ctx context.Context, since, until time.Time) ([]model.UserChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	var changes []model.UserChange
+	for _, change := range s.changes {
+		if change.CommitTimestamp.After(since) && !change.CommitTimestamp.After(until) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func TestChangeFeed_ReturnsPendingChangesImmediately(t *testing.T) {
+	since := time.Now().Add(-time.Minute)
+	source := &fakeChangeSource{}
+	source.add(model.UserChange{
+		Type: model.ChangeCreate, UserID: "user-1", CommitTimestamp: since.Add(time.Second),
+		New: map[string]any{"name": "John", "password": "hash"},
+	})
+	source.add(model.UserChange{Type: model.ChangeCreate, UserID: "user-0", CommitTimestamp: since})
+
+	batch, err := NewChangeFeed(source, time.Millisecond).Wait(context.Background(), since, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 1)
+	assert.Equal(t, "user-1", batch.Changes[0].UserID)
+	assert.Equal(t, map[string]any{"name": "John"}, batch.Changes[0].New)
+	assert.True(t, batch.Next.After(since))
+	assert.Equal(t, 1, source.calls)
+}
+
+func TestChangeFeed_WaitsForChanges(t *testing.T) {
+	source := &fakeChangeSource{}
+	feed := NewChangeFeed(source, 5*time.Millisecond)
+	since := time.Now()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		source.add(model.UserChange{Type: model.ChangeUpdate, UserID: "user-1", CommitTimestamp: time.Now()})
+	}()
+
+	batch, err := feed.Wait(context.Background(), since, time.Second)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 1)
+	assert.Equal(t, "user-1", batch.Changes[0].UserID)
+}
+
+func TestChangeFeed_ReturnsEmptyBatchAfterWait(t *testing.T) {
+	source := &fakeChangeSource{}
+	since := time.Now()
+
+	batch, err := NewChangeFeed(source, 5*time.Millisecond).Wait(context.Background(), since, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Empty(t, batch.Changes)
+	assert.False(t, batch.Next.Before(since.Add(20*time.Millisecond)))
+}
+
+func TestChangeFeed_RejectsExpiredSince(t *testing.T) {
+	_, err := NewChangeFeed(&fakeChangeSource{}, time.Second).Wait(context.Background(), time.Now().Add(-ChangeRetention-time.Hour), 0)
+	assert.ErrorIs(t, err, ErrChangesExpired)
+}
+
+func TestRedactChange_ReportsSoftDeletesAndRestores(t *testing.T) {
+	deleted := model.UserChange{
+		Type: model.ChangeUpdate,
+		Old:  map[string]any{"deleted_at": nil},
+		New:  map[string]any{"deleted_at": "2024-05-01T12:00:00Z"},
+	}
+	redactChange(&deleted)
+	assert.Equal(t, model.ChangeDelete, deleted.Type)
+
+	restored := model.UserChange{
+		Type: model.ChangeUpdate,
+		Old:  map[string]any{"deleted_at": "2024-05-01T12:00:00Z"},
+		New:  map[string]any{"deleted_at": nil},
+	}
+	redactChange(&restored)
+	assert.Equal(t, model.ChangeCreate, restored.Type)
+
+	renamed := model.UserChange{Type: model.ChangeUpdate, Old: map[string]any{"name": "John"}, New: map[string]any{"name": "Johnny"}}
+	redactChange(&renamed)
+	assert.Equal(t, model.ChangeUpdate, renamed.Type)
+}
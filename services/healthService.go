@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	HealthReady        = "ready"
+	HealthNotReady     = "not ready"
+	HealthShuttingDown = "shutting down"
+
+	checkOK     = "ok"
+	checkFailed = "failed"
+
+	checkTimedOut    = "timed out"
+	checkUnavailable = "unavailable"
+)
+
+// healthProblem is a failure a check may show on the probe. The probe is
+// not authenticated, so the text of any other error is only logged.
+type healthProblem string
+
+func (p healthProblem) Error() string {
+	return string(p)
+}
+
+// HealthCheck checks a dependency of the server. The details are reported
+// whether the check passes or not.
+type HealthCheck func(ctx context.Context) (details map[string]any, err error)
+
+// CheckResult is the outcome of a single HealthCheck.
+type CheckResult struct {
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"durationMs"`
+	Details    map[string]any `json:"details,omitempty"`
+}
+
+// HealthReport is the body of /readyz.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready reports whether the server should receive requests.
+func (r HealthReport) Ready() bool {
+	return r.Status == HealthReady
+}
+
+// HealthService decides whether the server is ready by checking its
+// dependencies. It reports not ready once the server is shutting down.
+type HealthService struct {
+	timeout      time.Duration
+	logger       *zap.Logger
+	names        []string
+	checks       map[string]HealthCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(timeout time.Duration, logger *zap.Logger) *HealthService {
+	return &HealthService{timeout: timeout, logger: logger, checks: map[string]HealthCheck{}}
+}
+
+// AddCheck registers a check under name. Checks must be added before the
+// service is used.
+func (s *HealthService) AddCheck(name string, check HealthCheck) {
+	if _, ok := s.checks[name]; !ok {
+		s.names = append(s.names, name)
+		sort.Strings(s.names)
+	}
+	s.checks[name] = check
+}
+
+// SetShuttingDown makes every following report not ready.
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Ready runs all checks concurrently, each under the timeout of the
+// service. The checks are skipped while shutting down.
+func (s *HealthService) Ready(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthReady, Checks: map[string]CheckResult{}}
+	if s.shuttingDown.Load() {
+		report.Status = HealthShuttingDown
+		return report
+	}
+
+	results := make([]CheckResult, len(s.names))
+	var wg sync.WaitGroup
+	for i, name := range s.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.run(ctx, name, s.checks[name])
+		}()
+	}
+	wg.Wait()
+
+	for i, name := range s.names {
+		report.Checks[name] = results[i]
+		if results[i].Status != checkOK {
+			report.Status = HealthNotReady
+		}
+	}
+	return report
+}
+
+func (s *HealthService) run(ctx context.Context, name string, check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check(ctx)
+	result := CheckResult{Status: checkOK, DurationMs: time.Since(start).Milliseconds(), Details: details}
+	if err != nil {
+		result.Status = checkFailed
+		result.Error = publicError(err)
+		s.logger.Warn("Health check failed", zap.String("check", name), zap.Error(err))
+	}
+	return result
+}
+
+// publicError is the text of err that the probe shows.
+func publicError(err error) string {
+	var problem healthProblem
+	switch {
+	case errors.As(err, &problem):
+		return problem.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return checkTimedOut
+	default:
+		return checkUnavailable
+	}
+}
+
+// DatabaseCheck runs SELECT 1 on db.
+func DatabaseCheck(db *gorm.DB) HealthCheck {
+	return func(ctx context.Context) (map[string]any, error) {
+		var one int64
+		err := db.WithContext(ctx).Raw("SELECT 1").Scan(&one).Error
+		return nil, err
+	}
+}
+
+// MigrationCounter counts the applied and pending schema migrations.
+type MigrationCounter interface {
+	Pending(ctx context.Context) (applied int, pending int, err error)
+}
+
+// MigrationCheck reports the number of applied and pending migrations and
+// fails while any are pending.
+func MigrationCheck(migrations MigrationCounter) HealthCheck {
+	return func(ctx context.Context) (map[string]any, error) {
+		applied, pending, err := migrations.Pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{"applied": applied, "pending": pending}
+		if pending > 0 {
+			return details, healthProblem(fmt.Sprintf("%d migrations are pending", pending))
+		}
+		return details, nil
+	}
+}
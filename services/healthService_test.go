@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type fakeMigrations struct {
+	applied, pending int
+}
+
+func (m fakeMigrations) Pending(ctx context.Context) (int, int, error) {
+	return m.applied, m.pending, nil
+}
+
+func TestHealthServiceReady(t *testing.T) {
+	health := NewHealthService(time.Second, zap.NewNop())
+	health.AddCheck("database", func(ctx context.Context) (map[string]any, error) { return nil, nil })
+	health.AddCheck("migrations", MigrationCheck(fakeMigrations{applied: 6}))
+
+	report := health.Ready(context.Background())
+	assert.True(t, report.Ready())
+	assert.Equal(t, HealthReady, report.Status)
+	assert.Equal(t, CheckResult{Status: checkOK, Details: map[string]any{"applied": 6, "pending": 0}}, report.Checks["migrations"])
+}
+
+func TestHealthServiceReportsFailedChecks(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	health := NewHealthService(time.Second, zap.New(core))
+	health.AddCheck("database", func(ctx context.Context) (map[string]any, error) {
+		return nil, errors.New("dial tcp 10.0.0.7:5432: connection refused")
+	})
+	health.AddCheck("migrations", MigrationCheck(fakeMigrations{applied: 4, pending: 2}))
+
+	report := health.Ready(context.Background())
+	assert.False(t, report.Ready())
+	assert.Equal(t, HealthNotReady, report.Status)
+	assert.Equal(t, "unavailable", report.Checks["database"].Error, "the error of the database is not shown")
+	assert.Equal(t, CheckResult{
+		Status:  checkFailed,
+		Error:   "2 migrations are pending",
+		Details: map[string]any{"applied": 4, "pending": 2},
+	}, report.Checks["migrations"])
+
+	logged := logs.FilterField(zap.String("check", "database")).All()
+	require.Len(t, logged, 1)
+	assert.Equal(t, "dial tcp 10.0.0.7:5432: connection refused", logged[0].ContextMap()["error"])
+}
+
+func TestHealthServiceTimesOutChecks(t *testing.T) {
+	health := NewHealthService(10*time.Millisecond, zap.NewNop())
+	health.AddCheck("database", func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	report := health.Ready(context.Background())
+	require.False(t, report.Ready())
+	assert.Equal(t, "timed out", report.Checks["database"].Error)
+}
+
+func TestHealthServiceShuttingDown(t *testing.T) {
+	health := NewHealthService(time.Second, zap.NewNop())
+	health.AddCheck("database", func(ctx context.Context) (map[string]any, error) {
+		t.Error("checks run while shutting down")
+		return nil, nil
+	})
+	health.SetShuttingDown()
+
+	report := health.Ready(context.Background())
+	assert.False(t, report.Ready())
+	assert.Equal(t, HealthShuttingDown, report.Status)
+	assert.Empty(t, report.Checks)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// outboxPruneBatchSize bounds the rows deleted per statement, which keeps
+// each Spanner transaction below its mutation limit.
+const outboxPruneBatchSize = 1000
+
+// OutboxPruner periodically deletes the outbox events that were published
+// longer than the retention period ago, so that the outbox does not grow
+// forever.
+type OutboxPruner struct {
+	*worker
+	outbox    interfaces.OutboxRepository
+	retention time.Duration
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+func NewOutboxPruner(outbox interfaces.OutboxRepository, retention, interval time.Duration, logger *zap.Logger) *OutboxPruner {
+	p := &OutboxPruner{
+		outbox:    outbox,
+		retention: retention,
+		logger:    logger,
+		now:       time.Now,
+	}
+	p.worker = newWorker(interval, p.prune)
+	return p
+}
+
+func (p *OutboxPruner) prune(ctx context.Context) {
+	deleted, err := p.PrunePublished(ctx)
+	if err != nil && ctx.Err() == nil {
+		p.logger.Error("Failed to prune outbox events", zap.Int64("deleted", deleted), zap.Error(err))
+		return
+	}
+	if deleted > 0 {
+		p.logger.Info("Pruned outbox events", zap.Int64("count", deleted), zap.Duration("retention", p.retention))
+	}
+}
+
+// PrunePublished deletes the events published before the retention period
+// in batches and returns how many were deleted.
+func (p *OutboxPruner) PrunePublished(ctx context.Context) (int64, error) {
+	before := p.now().UTC().Add(-p.retention)
+	var deleted int64
+	for {
+		count, err := p.outbox.DeletePublishedBefore(ctx, before, outboxPruneBatchSize)
+		deleted += count
+		if err != nil || count < outboxPruneBatchSize {
+			return deleted, err
+		}
+	}
+}
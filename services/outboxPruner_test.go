@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOutboxPruner_PrunePublishedDeletesInBatches(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	outbox := &fakeOutbox{deletes: []int64{outboxPruneBatchSize, 3}}
+	pruner := NewOutboxPruner(outbox, 7*24*time.Hour, time.Hour, zap.NewNop())
+	pruner.now = func() time.Time { return now }
+
+	deleted, err := pruner.PrunePublished(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(outboxPruneBatchSize+3), deleted)
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []time.Time{before, before}, outbox.deletedBefore)
+}
+
+func TestOutboxPruner_PrunePublishedReportsErrors(t *testing.T) {
+	outbox := &fakeOutbox{deletes: []int64{outboxPruneBatchSize}}
+	pruner := NewOutboxPruner(outbox, time.Hour, time.Hour, zap.NewNop())
+
+	deleted, err := pruner.PrunePublished(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, int64(outboxPruneBatchSize), deleted, "earlier batches are counted")
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// outboxLease is how long a claimed event is hidden from other relays
+	// while it is being delivered.
+	outboxLease      = time.Minute
+	outboxBatchSize  = 100
+	outboxMaxBackoff = time.Hour
+)
+
+// OutboxRelay periodically delivers the pending events of the outbox. An
+// event is marked as published only after the publisher accepted it, so
+// delivery is at least once.
+type OutboxRelay struct {
+	*worker
+	outbox    interfaces.OutboxRepository
+	publisher interfaces.Publisher
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+func NewOutboxRelay(outbox interfaces.OutboxRepository, publisher interfaces.Publisher, interval time.Duration, logger *zap.Logger) *OutboxRelay {
+	r := &OutboxRelay{
+		outbox:    outbox,
+		publisher: publisher,
+		logger:    logger,
+		now:       time.Now,
+	}
+	r.worker = newWorker(interval, r.deliver)
+	return r
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context) {
+	if _, err := r.DeliverPending(ctx); err != nil && ctx.Err() == nil {
+		r.logger.Error("Failed to claim outbox events", zap.Error(err))
+	}
+}
+
+// DeliverPending publishes the events that are due and returns how many
+// were published. Failed events are retried later with exponential backoff.
+func (r *OutboxRelay) DeliverPending(ctx context.Context) (int, error) {
+	events, err := r.outbox.Claim(ctx, r.now().UTC(), outboxLease, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			if ctx.Err() != nil {
+				return published, ctx.Err()
+			}
+			attempts := event.Attempts + 1
+			next := r.now().UTC().Add(outboxBackoff(attempts))
+			r.logger.Warn("Failed to publish outbox event",
+				zap.String("event_id", event.ID), zap.String("type", event.Type),
+				zap.Int64("attempts", attempts), zap.Error(err))
+			if err := r.outbox.MarkFailed(ctx, event.ID, attempts, err.Error(), next); err != nil {
+				r.logger.Error("Failed to record outbox failure", zap.String("event_id", event.ID), zap.Error(err))
+			}
+			continue
+		}
+		// If this fails the event is delivered again once its lease expires.
+		if err := r.outbox.MarkPublished(ctx, event.ID, r.now().UTC()); err != nil {
+			r.logger.Error("Failed to mark outbox event as published", zap.String("event_id", event.ID), zap.Error(err))
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+// outboxBackoff is the delay before the next delivery after attempts
+// failures: 2s, 4s, 8s, ... up to an hour.
+func outboxBackoff(attempts int64) time.Duration {
+	if attempts >= 12 {
+		return outboxMaxBackoff
+	}
+	return min(time.Second<<attempts, outboxMaxBackoff)
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"crudspanner/model"
+	"crudspanner/publishers"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeOutbox serves a fixed list of events and records what the relay does
+// with them. Deletions take their counts from deletes, one per call.
+type fakeOutbox struct {
+	events        []model.OutboxEvent
+	published     []string
+	failed        map[string]time.Time
+	attempts      map[string]int64
+	deletes       []int64
+	deletedBefore []time.Time
+}
+
+func (o *fakeOutbox) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	return o.events, nil
+}
+
+func (o *fakeOutbox) MarkPublished(ctx context.Context, id string, publishedAt time.Time) error {
+	o.published = append(o.published, id)
+	return nil
+}
+
+func (o *fakeOutbox) MarkFailed(ctx context.Context, id string, attempts int64, lastError string, nextAttemptAt time.Time) error {
+	o.failed[id] = nextAttemptAt
+	o.attempts[id] = attempts
+	return nil
+}
+
+func (o *fakeOutbox) DeletePublishedBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	o.deletedBefore = append(o.deletedBefore, before)
+	if len(o.deletes) == 0 {
+		return 0, errors.New("unexpected delete")
+	}
+	deleted := o.deletes[0]
+	o.deletes = o.deletes[1:]
+	return min(deleted, int64(limit)), nil
+}
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	return errors.New("bus unavailable")
+}
+
+func TestOutboxRelay_DeliverPending(t *testing.T) {
+	outbox := &fakeOutbox{events: []model.OutboxEvent{
+		{ID: "event-1", Type: model.EventUserRegistered},
+		{ID: "event-2", Type: model.EventUserUpdated},
+	}}
+	publisher := publishers.NewMemoryPublisher()
+	relay := NewOutboxRelay(outbox, publisher, time.Second, zap.NewNop())
+
+	published, err := relay.DeliverPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{"event-1", "event-2"}, outbox.published)
+	assert.Len(t, publisher.Events(), 2)
+}
+
+func TestOutboxRelay_DeliverPendingRecordsFailures(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := &fakeOutbox{
+		events:   []model.OutboxEvent{{ID: "event-1", Attempts: 2}},
+		failed:   map[string]time.Time{},
+		attempts: map[string]int64{},
+	}
+	relay := NewOutboxRelay(outbox, failingPublisher{}, time.Second, zap.NewNop())
+	relay.now = func() time.Time { return now }
+
+	published, err := relay.DeliverPending(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+	assert.Empty(t, outbox.published)
+	assert.Equal(t, int64(3), outbox.attempts["event-1"])
+	assert.Equal(t, now.Add(8*time.Second), outbox.failed["event-1"])
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, 2*time.Second, outboxBackoff(1))
+	assert.Equal(t, 1024*time.Second, outboxBackoff(10))
+	assert.Equal(t, time.Hour, outboxBackoff(12))
+	assert.Equal(t, time.Hour, outboxBackoff(100))
+}
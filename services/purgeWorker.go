@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PurgeWorker periodically removes users that have been soft-deleted for
+// longer than the retention period.
+type PurgeWorker struct {
+	*worker
+	userService UserService
+	retention   time.Duration
+	logger      *zap.Logger
+}
+
+func NewPurgeWorker(userService UserService, retention, interval time.Duration, logger *zap.Logger) *PurgeWorker {
+	w := &PurgeWorker{
+		userService: userService,
+		retention:   retention,
+		logger:      logger,
+	}
+	w.worker = newWorker(interval, w.purge)
+	return w
+}
+
+func (w *PurgeWorker) purge(ctx context.Context) {
+	purged, err := w.userService.PurgeDeletedUsers(ctx, w.retention)
+	if err != nil && ctx.Err() == nil {
+		w.logger.Error("Failed to purge deleted users", zap.Int64("purged", purged), zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		w.logger.Info("Purged deleted users", zap.Int64("count", purged), zap.Duration("retention", w.retention))
+	}
+}
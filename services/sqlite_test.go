@@ -0,0 +1,19 @@
+//go:build cgo
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openSQLite opens the SQLite database at dsn. The driver wraps the C
+// library of SQLite and needs cgo.
+func openSQLite(t *testing.T, dsn string, config *gorm.Config) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(dsn), config)
+	require.NoError(t, err)
+	return db
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Columns that can be mapped from an uploaded CSV file onto a user.
+const (
+	ImportColumnName     = "name"
+	ImportColumnEmail    = "email"
+	ImportColumnAddress  = "address"
+	ImportColumnPassword = "password"
+)
+
+// ErrInvalidImport matches the errors of uploaded files that are not CSV or
+// lack columns.
+var ErrInvalidImport = errors.New("invalid import file")
+
+var importColumns = []string{ImportColumnName, ImportColumnEmail, ImportColumnAddress, ImportColumnPassword}
+
+type ImportOptions struct {
+	// Mapping maps a user field (see the ImportColumn constants) to the CSV
+	// header holding it. Unmapped fields default to a header of the same name.
+	Mapping map[string]string
+	// DryRun validates every line without writing anything.
+	DryRun bool
+}
+
+type ImportRowError struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+type ImportResult struct {
+	DryRun   bool             `json:"dryRun"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Rejected []ImportRowError `json:"rejected"`
+}
+
+func (s *userService) ImportUsers(ctx context.Context, reader io.Reader, options ImportOptions) (*ImportResult, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, invalidImport(errors.New("csv file is empty"))
+	}
+	if err != nil {
+		return nil, invalidImport(fmt.Errorf("invalid csv header: %w", err))
+	}
+
+	columns, err := resolveImportColumns(header, options.Mapping)
+	if err != nil {
+		return nil, invalidImport(err)
+	}
+
+	result := &ImportResult{DryRun: options.DryRun, Rejected: []ImportRowError{}}
+	seen := make(map[string]int)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		result.Total++
+
+		if err != nil {
+			var line int
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			result.Rejected = append(result.Rejected, ImportRowError{Line: line, Reason: err.Error()})
+			continue
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		user := model.User{
+			Name:     importField(record, columns, ImportColumnName),
+			Email:    importField(record, columns, ImportColumnEmail),
+			Address:  importField(record, columns, ImportColumnAddress),
+			Password: importField(record, columns, ImportColumnPassword),
+		}
+		email := strings.ToLower(user.Email)
+
+		if reason := validateImportedUser(&user, email, seen); reason != "" {
+			result.Rejected = append(result.Rejected, ImportRowError{Line: line, Email: user.Email, Reason: reason})
+			continue
+		}
+
+		if options.DryRun {
+			err = checkEmailFree(ctx, s.repo, email)
+		} else {
+			err = s.importUser(ctx, &user)
+		}
+		switch {
+		case errors.Is(err, ErrEmailTaken):
+			result.Rejected = append(result.Rejected, ImportRowError{Line: line, Email: user.Email, Reason: err.Error()})
+			continue
+		case err != nil && options.DryRun:
+			return nil, fmt.Errorf("checking the email on line %d: %w", line, err)
+		}
+		seen[email] = line
+		result.Valid++
+
+		if err != nil {
+			model.RequestLogger(ctx, s.logger).Warn("Failed to import user", zap.Int("line", line), zap.Error(err))
+			result.Rejected = append(result.Rejected, ImportRowError{Line: line, Email: user.Email, Reason: "could not be stored"})
+			continue
+		}
+		if !options.DryRun {
+			result.Imported++
+		}
+	}
+
+	model.RequestLogger(ctx, s.logger).Info("Imported users", zap.Bool("dry_run", result.DryRun), zap.Int("total", result.Total),
+		zap.Int("valid", result.Valid), zap.Int("imported", result.Imported), zap.Int("rejected", len(result.Rejected)))
+	return result, nil
+}
+
+// importUser creates user like Registration does, checking in the same
+// transaction that the email is free.
+func (s *userService) importUser(ctx context.Context, user *model.User) error {
+	addUser := newUserFromInput(user)
+	return s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		if err := checkEmailFree(ctx, repo, addUser.Email); err != nil {
+			return err
+		}
+		created, err := repo.Create(ctx, &addUser)
+		if err != nil {
+			return err
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditCreate, created.ID, model.DiffUsers(&model.User{}, created)); err != nil {
+			return err
+		}
+		return addUserEvent(ctx, repo, model.EventUserRegistered, created, false)
+	})
+}
+
+// validateImportedUser returns the reason a line is rejected, or an empty
+// string when the user can be imported. Whether the email is taken is only
+// checked when the user is written, in the same transaction.
+func validateImportedUser(user *model.User, email string, seen map[string]int) string {
+	if user.Name == "" || user.Email == "" || user.Password == "" {
+		return "name, email, and password are required"
+	}
+	if !isValidEmail(user.Email) {
+		return "invalid email address"
+	}
+	if firstLine, ok := seen[email]; ok {
+		return fmt.Sprintf("duplicate email, first seen on line %d", firstLine)
+	}
+	return ""
+}
+
+// importError is an error of the uploaded file; it matches ErrInvalidImport.
+type importError struct {
+	err error
+}
+
+func invalidImport(err error) error {
+	return &importError{err: err}
+}
+
+func (e *importError) Error() string {
+	return e.err.Error()
+}
+
+func (e *importError) Unwrap() error {
+	return e.err
+}
+
+func (e *importError) Is(target error) bool {
+	return target == ErrInvalidImport
+}
+
+func resolveImportColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	for field := range mapping {
+		if !isImportColumn(field) {
+			return nil, fmt.Errorf("unknown mapping field %q", field)
+		}
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make(map[string]int, len(importColumns))
+	var missing []string
+	for _, field := range importColumns {
+		name := field
+		if mapped, ok := mapping[field]; ok && strings.TrimSpace(mapped) != "" {
+			name = mapped
+		}
+		i, ok := positions[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if field != ImportColumnAddress {
+				missing = append(missing, name)
+			}
+			continue
+		}
+		columns[field] = i
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("csv header is missing columns: %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+func isImportColumn(field string) bool {
+	for _, column := range importColumns {
+		if column == field {
+			return true
+		}
+	}
+	return false
+}
+
+func importField(record []string, columns map[string]int, field string) string {
+	i, ok := columns[field]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func isValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
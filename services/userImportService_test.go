@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"crudspanner/model"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestUserService_ImportUsers(t *testing.T) {
+	tests := []struct {
+		name             string
+		csv              string
+		options          ImportOptions
+		setupMock        func(mockRepo *MockUserRepository)
+		expectedError    string
+		invalidFile      bool
+		expectedValid    int
+		expectedImported int
+		expectedRejected []ImportRowError
+	}{
+		{
+			name: "dry run reports invalid and duplicate lines without writing",
+			csv: "name,email,address,password\n" +
+				"John,john@example.com,Street 1,secret\n" +
+				"Jane,not-an-email,Street 2,secret\n" +
+				"Johnny,JOHN@example.com,Street 3,secret\n" +
+				"Existing,taken@example.com,Street 4,secret\n" +
+				",missing@example.com,Street 5,secret\n",
+			options: ImportOptions{DryRun: true},
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("FindByEmail", mock.Anything, "taken@example.com").Return(&model.User{Email: "taken@example.com"}, nil)
+			},
+			expectedValid: 1,
+			expectedRejected: []ImportRowError{
+				{Line: 3, Email: "not-an-email", Reason: "invalid email address"},
+				{Line: 4, Email: "JOHN@example.com", Reason: "duplicate email, first seen on line 2"},
+				{Line: 5, Email: "taken@example.com", Reason: "user with this email already exists"},
+				{Line: 6, Email: "missing@example.com", Reason: "name, email, and password are required"},
+			},
+		},
+		{
+			name: "import with custom column mapping",
+			csv: "Full Name,E-mail,Secret\n" +
+				"John,john@example.com,secret\n",
+			options: ImportOptions{Mapping: map[string]string{"name": "Full Name", "email": "E-mail", "password": "Secret"}},
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *model.User) bool {
+					return user.Name == "John" && user.Email == "john@example.com" && user.Password != "secret"
+				})).Return(&model.User{Name: "John", Email: "john@example.com"}, nil)
+			},
+			expectedValid:    1,
+			expectedImported: 1,
+			expectedRejected: []ImportRowError{},
+		},
+		{
+			name: "import rejects taken emails and lines that cannot be stored",
+			csv: "name,email,password\n" +
+				"Taken,taken@example.com,secret\n" +
+				"Broken,broken@example.com,secret\n",
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "taken@example.com").Return(&model.User{Email: "taken@example.com"}, nil)
+				mockRepo.On("FindByEmail", mock.Anything, "broken@example.com").Return(nil, errors.New("connection reset"))
+			},
+			expectedValid: 1,
+			expectedRejected: []ImportRowError{
+				{Line: 2, Email: "taken@example.com", Reason: "user with this email already exists"},
+				{Line: 3, Email: "broken@example.com", Reason: "could not be stored"},
+			},
+		},
+		{
+			name:    "dry run fails when emails cannot be checked",
+			csv:     "name,email,password\nJohn,john@example.com,secret\n",
+			options: ImportOptions{DryRun: true},
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, errors.New("connection reset"))
+			},
+			expectedError: "checking the email on line 2: connection reset",
+		},
+		{
+			name:          "missing mapped column",
+			csv:           "name,email\nJohn,john@example.com\n",
+			setupMock:     func(mockRepo *MockUserRepository) {},
+			expectedError: "csv header is missing columns: password",
+			invalidFile:   true,
+		},
+		{
+			name:          "unknown mapping field",
+			csv:           "name,email,password\n",
+			options:       ImportOptions{Mapping: map[string]string{"phone": "Phone"}},
+			setupMock:     func(mockRepo *MockUserRepository) {},
+			expectedError: `unknown mapping field "phone"`,
+			invalidFile:   true,
+		},
+		{
+			name:          "empty file",
+			csv:           "",
+			setupMock:     func(mockRepo *MockUserRepository) {},
+			expectedError: "csv file is empty",
+			invalidFile:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			userService := NewUserService(mockRepo, zap.NewNop())
+			tt.setupMock(mockRepo)
+
+			result, err := userService.ImportUsers(context.Background(), strings.NewReader(tt.csv), tt.options)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				assert.Equal(t, tt.invalidFile, errors.Is(err, ErrInvalidImport))
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.options.DryRun, result.DryRun)
+				assert.Equal(t, tt.expectedValid, result.Valid)
+				assert.Equal(t, tt.expectedImported, result.Imported)
+				assert.Equal(t, tt.expectedRejected, result.Rejected)
+			}
+
+			mockRepo.AssertExpectations(t)
+			if tt.options.DryRun || tt.expectedImported == 0 {
+				mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -1,99 +1,402 @@
 package services
 
 import (
+	"context"
 	"crudspanner/config"
 	"crudspanner/interfaces"
 	"crudspanner/model"
 	"errors"
+	"io"
 	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize is how many expired users are listed at a time.
+const purgeBatchSize = 100
+
+var (
+	// ErrEmailTaken is returned when a live user already has the email.
+	ErrEmailTaken = errors.New("user with this email already exists")
+	// ErrMissingFields is returned for registrations without a name, email
+	// or password.
+	ErrMissingFields = errors.New("name, email, and password are required")
+	// ErrInvalidPatch is returned for patches that clear the name or email.
+	ErrInvalidPatch = errors.New("name and email cannot be empty")
+	// ErrUserNotFound matches the errors of lookups of users that do not
+	// exist.
+	ErrUserNotFound = errors.New("user not found")
 )
 
 type UserService interface {
-	Registration(user *model.User) (*model.User, error)
-	GetUserById(id uint) (*model.User, error)
-	DeleteUser(id uint) error
-	GetAllUsers() ([]model.User, error)
-	UpdateUser(id uint, user *model.User) (*model.User, error)
+	Registration(ctx context.Context, user *model.User) (*model.User, error)
+	GetUserById(ctx context.Context, id string, staleness model.Staleness) (*model.User, time.Time, error)
+	DeleteUser(ctx context.Context, id string) error
+	GetAllUsers(ctx context.Context, staleness model.Staleness, page model.Page) (*UserPage, time.Time, error)
+	UpdateUser(ctx context.Context, id string, user *model.User) (*model.User, error)
+	PatchUser(ctx context.Context, id string, patch model.UserPatch) (*model.User, error)
+	ImportUsers(ctx context.Context, reader io.Reader, options ImportOptions) (*ImportResult, error)
+	GetDeletedUsers(ctx context.Context) ([]model.User, error)
+	RestoreUser(ctx context.Context, id string) (*model.User, error)
+	PermanentlyDeleteUser(ctx context.Context, id string) error
+	PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// UserPage is a page of users. NextPageToken is empty on the last page.
+type UserPage struct {
+	Users         []model.User
+	NextPageToken string
 }
 
 type userService struct {
-	repo interfaces.UserRepository
+	repo   interfaces.UserRepository
+	logger *zap.Logger
 }
 
-func NewUserService(repo interfaces.UserRepository) UserService {
-	return &userService{repo: repo}
+func NewUserService(repo interfaces.UserRepository, logger *zap.Logger) UserService {
+	return &userService{repo: repo, logger: logger}
 }
 
-func (s *userService) Registration(user *model.User) (*model.User, error) {
+func (s *userService) Registration(ctx context.Context, user *model.User) (*model.User, error) {
 
 	if user.Name == "" || user.Email == "" || user.Password == "" {
-		return nil, errors.New("name, email, and password are required")
+		return nil, ErrMissingFields
 	}
 
-	existingUser, _ := s.repo.FindByEmail(user.Email)
+	addUser := newUserFromInput(user)
+
+	var created *model.User
+	err := s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		if err := checkEmailFree(ctx, repo, addUser.Email); err != nil {
+			return err
+		}
+
+		var err error
+		created, err = repo.Create(ctx, &addUser)
+		if err != nil {
+			return err
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditCreate, created.ID, model.DiffUsers(&model.User{}, created)); err != nil {
+			return err
+		}
+		return addUserEvent(ctx, repo, model.EventUserRegistered, created, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
 
-	if existingUser != nil {
-		return nil, errors.New("user with this email already exists")
+// checkEmailFree returns ErrEmailTaken if a user with email exists. Other
+// errors of the lookup are returned as they are.
+func checkEmailFree(ctx context.Context, repo interfaces.UserRepository, email string) error {
+	_, err := repo.FindByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return ErrEmailTaken
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
 	}
+	return err
+}
 
+func newUserFromInput(user *model.User) model.User {
 	var addUser model.User
 	addUser.Name = strings.TrimSpace(user.Name)
 	addUser.Email = strings.ToLower(strings.TrimSpace(user.Email))
 	addUser.Address = strings.TrimSpace(user.Address)
 	addUser.Password = config.GeneratePassword(user.Password)
-
-	return s.repo.Create(&addUser)
+	return addUser
 }
 
-func (s *userService) GetUserById(id uint) (*model.User, error) {
+// GetUserById also returns the read timestamp, which is zero for strong
+// reads.
+func (s *userService) GetUserById(ctx context.Context, id string, staleness model.Staleness) (*model.User, time.Time, error) {
 
-	user, err := s.repo.Get(id)
+	var user *model.User
+	readTimestamp, err := s.repo.ReadOnly(ctx, staleness, func(repo interfaces.UserRepository) error {
+		var err error
+		user, err = repo.Get(ctx, id)
+		return err
+	})
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, time.Time{}, lookupError("user not found", err)
 	}
 
 	// Redact sensitive information
 	user.Password = ""
-	return user, nil
+	return user, readTimestamp, nil
 }
 
-func (s *userService) UpdateUser(id uint, user *model.User) (*model.User, error) {
-	existingUser, err := s.repo.Get(id)
-	if err != nil {
-		return nil, errors.New("user not found")
+func (s *userService) UpdateUser(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	return s.update(ctx, id, func(existingUser *model.User) {
+		// Update fields selectively
+		if strings.TrimSpace(user.Name) != "" {
+			existingUser.Name = strings.TrimSpace(user.Name)
+		}
+		if strings.TrimSpace(user.Email) != "" {
+			existingUser.Email = strings.ToLower(strings.TrimSpace(user.Email))
+		}
+		if strings.TrimSpace(user.Address) != "" {
+			existingUser.Address = strings.TrimSpace(user.Address)
+		}
+	})
+}
+
+// PatchUser sets the fields of the patch. Unlike UpdateUser it can clear
+// the address; the name and the email cannot be cleared.
+func (s *userService) PatchUser(ctx context.Context, id string, patch model.UserPatch) (*model.User, error) {
+	if (patch.Name != nil && strings.TrimSpace(*patch.Name) == "") || (patch.Email != nil && strings.TrimSpace(*patch.Email) == "") {
+		return nil, ErrInvalidPatch
 	}
+	return s.update(ctx, id, func(existingUser *model.User) {
+		if patch.Name != nil {
+			existingUser.Name = strings.TrimSpace(*patch.Name)
+		}
+		if patch.Email != nil {
+			existingUser.Email = strings.ToLower(strings.TrimSpace(*patch.Email))
+		}
+		if patch.Address != nil {
+			existingUser.Address = strings.TrimSpace(*patch.Address)
+		}
+	})
+}
+
+// update applies change to a user and records the update. A new email is
+// checked to be free in the same transaction.
+func (s *userService) update(ctx context.Context, id string, change func(user *model.User)) (*model.User, error) {
+	var updatedUser *model.User
+	err := s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		existingUser, err := repo.Get(ctx, id)
+		if err != nil {
+			return lookupError("user not found", err)
+		}
+		before := *existingUser
+		change(existingUser)
+		if existingUser.Email != before.Email {
+			if err := checkEmailFree(ctx, repo, existingUser.Email); err != nil {
+				return err
+			}
+		}
 
-	// Update fields selectively
-	if strings.TrimSpace(user.Name) != "" {
-		existingUser.Name = strings.TrimSpace(user.Name)
+		updatedUser, err = repo.Update(ctx, id, existingUser)
+		if err != nil {
+			return err
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditUpdate, updatedUser.ID, model.DiffUsers(&before, updatedUser)); err != nil {
+			return err
+		}
+		return addUserEvent(ctx, repo, model.EventUserUpdated, updatedUser, false)
+	})
+	if err != nil {
+		return nil, err
 	}
-	if strings.TrimSpace(user.Email) != "" {
-		existingUser.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	return updatedUser, nil
+}
+
+func (s *userService) DeleteUser(ctx context.Context, id string) error {
+	return s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		// Check if user exists before deletion
+		user, err := repo.Get(ctx, id)
+		if err != nil {
+			return lookupError("user not found", err)
+		}
+
+		// Proceed with deletion
+		if err := repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditDelete, user.ID, nil); err != nil {
+			return err
+		}
+		return addUserEvent(ctx, repo, model.EventUserDeleted, user, false)
+	})
+}
+
+// GetAllUsers returns a page of users. One user more than the page holds is
+// read to find out whether another page follows.
+func (s *userService) GetAllUsers(ctx context.Context, staleness model.Staleness, page model.Page) (*UserPage, time.Time, error) {
+
+	query := page
+	if query.Size > 0 {
+		query.Size++
 	}
-	if strings.TrimSpace(user.Address) != "" {
-		existingUser.Address = strings.TrimSpace(user.Address)
+	var users []model.User
+	readTimestamp, err := s.repo.ReadOnly(ctx, staleness, func(repo interfaces.UserRepository) error {
+		var err error
+		users, err = repo.List(ctx, query)
+		return err
+	})
+	if err != nil {
+		return nil, time.Time{}, err
 	}
 
-	return s.repo.Update(id, existingUser)
+	result := &UserPage{Users: users}
+	if page.Size > 0 && len(users) > page.Size {
+		result.Users = users[:page.Size]
+		result.NextPageToken = model.PageToken(result.Users[page.Size-1].ID)
+	}
+	for i := range result.Users {
+		result.Users[i].Password = ""
+	}
+	return result, readTimestamp, nil
 }
 
-func (s *userService) DeleteUser(id uint) error {
-	// Check if user exists before deletion
-	_, err := s.repo.Get(id)
+func (s *userService) GetDeletedUsers(ctx context.Context) ([]model.User, error) {
+
+	users, err := s.repo.GetDeleted(ctx)
 	if err != nil {
-		return errors.New("user not found")
+		return nil, err
 	}
 
-	// Proceed with deletion
-	return s.repo.Delete(id)
+	for i := range users {
+		users[i].Password = ""
+	}
+	return users, nil
 }
 
-func (s *userService) GetAllUsers() ([]model.User, error) {
+func (s *userService) RestoreUser(ctx context.Context, id string) (*model.User, error) {
+	var user *model.User
+	err := s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		deletedUser, err := repo.FindDeleted(ctx, id)
+		if err != nil {
+			return lookupError("deleted user not found", err)
+		}
+
+		// The email may have been registered again while the user was deleted
+		if err := checkEmailFree(ctx, repo, deletedUser.Email); err != nil {
+			return err
+		}
 
-	users, err := s.repo.GetAll()
+		user, err = repo.Restore(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditRestore, user.ID, nil); err != nil {
+			return err
+		}
+		return addUserEvent(ctx, repo, model.EventUserRestored, user, false)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	user.Password = ""
+	return user, nil
+}
+
+func (s *userService) PermanentlyDeleteUser(ctx context.Context, id string) error {
+	return s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		user, err := repo.Get(ctx, id)
+		if err != nil {
+			if user, err = repo.FindDeleted(ctx, id); err != nil {
+				return lookupError("user not found", err)
+			}
+		}
+
+		if err := repo.HardDelete(ctx, user.ID); err != nil {
+			return lookupError("user not found", err)
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditPermanentDelete, user.ID, nil); err != nil {
+			return err
+		}
+		return addUserEvent(ctx, repo, model.EventUserDeleted, user, true)
+	})
+}
+
+// PurgeDeletedUsers permanently deletes the users deleted longer than
+// retention ago. Each user is deleted in its own transaction with an audit
+// entry and an event, like PermanentlyDeleteUser. Users restored since they
+// were listed are skipped.
+func (s *userService) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, errors.New("retention must be positive")
+	}
+	before := time.Now().Add(-retention)
+	ctx = model.WithRequestInfo(ctx, model.RequestInfo{Actor: model.PurgeActor})
+
+	var purged int64
+	for {
+		users, err := s.repo.ListDeletedBefore(ctx, before, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, expired := range users {
+			deleted, err := s.purgeUser(ctx, expired.ID, before)
+			if err != nil {
+				return purged, err
+			}
+			if deleted {
+				purged++
+			}
+		}
+		if len(users) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (s *userService) purgeUser(ctx context.Context, id string, before time.Time) (bool, error) {
+	deleted := false
+	err := s.repo.Transaction(ctx, func(repo interfaces.UserRepository) error {
+		deleted = false
+		user, err := repo.FindDeleted(ctx, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !user.DeletedAt.Time.Before(before) {
+			return nil
+		}
+
+		if err := repo.HardDelete(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := addAuditEntry(ctx, repo, model.AuditPermanentDelete, user.ID, nil); err != nil {
+			return err
+		}
+		deleted = true
+		return addUserEvent(ctx, repo, model.EventUserDeleted, user, true)
+	})
+	return deleted, err
+}
+
+// addUserEvent stores an event about user in the outbox of repo, which is
+// committed with the change when repo belongs to a transaction.
+func addUserEvent(ctx context.Context, repo interfaces.UserRepository, eventType string, user *model.User, permanent bool) error {
+	event, err := model.NewUserEvent(eventType, user, permanent)
+	if err != nil {
+		return err
+	}
+	return repo.AddEvent(ctx, event)
+}
+
+// notFoundError reports a failed lookup with a fixed message but keeps the
+// cause, so that the repository still sees aborted transactions and retries
+// them and callers still see deadlines.
+type notFoundError struct {
+	message string
+	cause   error
+}
+
+func lookupError(message string, cause error) error {
+	return &notFoundError{message: message, cause: cause}
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
+
+func (e *notFoundError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches ErrUserNotFound only if the user is missing, not if the lookup
+// failed for another reason.
+func (e *notFoundError) Is(target error) bool {
+	return target == ErrUserNotFound && errors.Is(e.cause, gorm.ErrRecordNotFound)
 }
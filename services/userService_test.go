@@ -1,55 +1,124 @@
 package services
 
 import (
+	"context"
+	"crudspanner/interfaces"
 	"crudspanner/model"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type MockUserRepository struct {
 	mock.Mock
+	// readTimestamp is returned by ReadOnly.
+	readTimestamp time.Time
+	// events holds the events added to the outbox.
+	events []*model.OutboxEvent
+	// auditEntries holds the recorded audit entries.
+	auditEntries []*model.AuditEntry
 }
 
-func (m *MockUserRepository) Create(user *model.User) (*model.User, error) {
-	args := m.Called(user)
+func (m *MockUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	args := m.Called(ctx, user)
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Get(id uint) (*model.User, error) {
-	args := m.Called(id)
+func (m *MockUserRepository) Get(ctx context.Context, id string) (*model.User, error) {
+	args := m.Called(ctx, id)
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetAll() ([]model.User, error) {
-	args := m.Called()
+func (m *MockUserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]model.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(id uint, user *model.User) (*model.User, error) {
-	args := m.Called(id, user)
+func (m *MockUserRepository) List(ctx context.Context, page model.Page) ([]model.User, error) {
+	args := m.Called(ctx, page)
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Update(ctx context.Context, id string, user *model.User) (*model.User, error) {
+	args := m.Called(ctx, id, user)
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Delete(id uint) error {
-	args := m.Called(id)
+func (m *MockUserRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) FindByEmail(email string) (*model.User, error) {
-	args := m.Called(email)
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetDeleted(ctx context.Context) ([]model.User, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) FindDeleted(ctx context.Context, id string) (*model.User, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) Restore(ctx context.Context, id string) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) HardDelete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]model.User, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]model.User), args.Error(1)
+}
+
+// Transaction runs fn against the mock itself, so expectations set on the
+// mock apply inside transactions too.
+func (m *MockUserRepository) AddEvent(ctx context.Context, event *model.OutboxEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MockUserRepository) AddAuditEntry(ctx context.Context, entry *model.AuditEntry) error {
+	m.auditEntries = append(m.auditEntries, entry)
+	return nil
+}
+
+func (m *MockUserRepository) Transaction(ctx context.Context, fn func(repo interfaces.UserRepository) error) error {
+	return fn(m)
+}
+
+func (m *MockUserRepository) ReadOnly(ctx context.Context, staleness model.Staleness, fn func(repo interfaces.UserRepository) error) (time.Time, error) {
+	if err := fn(m); err != nil {
+		return time.Time{}, err
+	}
+	return m.readTimestamp, nil
+}
+
 // func TestUserService_Registration(t *testing.T) {
 // 	mockRepo := new(MockUserRepository)
-// 	userService := NewUserService(mockRepo)
+// 	userService := NewUserService(mockRepo, zap.NewNop())
 
 // 	// Test case: valid registration
 // 	newUser := &model.User{
@@ -60,7 +129,7 @@ func (m *MockUserRepository) FindByEmail(email string) (*model.User, error) {
 // 	}
 
 // 	// Mock that FindByEmail will return nil (no existing user)
-// 	mockRepo.On("FindByEmail", newUser.Email).Return(nil, errors.New("record not found"))
+// 	mockRepo.On("FindByEmail", newUser.Email).Return(nil, gorm.ErrRecordNotFound)
 
 // 	// Mock Create to return the new user
 // 	mockRepo.On("Create", mock.Anything).Return(newUser, nil)
@@ -89,7 +158,7 @@ func (m *MockUserRepository) FindByEmail(email string) (*model.User, error) {
 
 // func TestUserServiceError(t *testing.T) {
 // 	mockRepo := new(MockUserRepository)
-// 	userService := NewUserService(mockRepo)
+// 	userService := NewUserService(mockRepo, zap.NewNop())
 // 	newUser := &model.User{
 // 		Model:    gorm.Model{ID: 1},
 // 		Name:     "John",
@@ -115,12 +184,12 @@ func TestUserService_Registration(t *testing.T) {
 			name: "valid registration",
 			mockFindByEmail: func(mockRepo *MockUserRepository) {
 				// Mock that FindByEmail will return nil (no existing user)
-				mockRepo.On("FindByEmail", "john@example.com").Return(nil, errors.New("record not found"))
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, gorm.ErrRecordNotFound)
 			},
 			mockCreate: func(mockRepo *MockUserRepository) {
 				// Mock Create to return the new user
-				mockRepo.On("Create", mock.Anything).Return(&model.User{
-					Model:    gorm.Model{ID: 1},
+				mockRepo.On("Create", mock.Anything, mock.Anything).Return(&model.User{
+					ID:       "user-1",
 					Name:     "John",
 					Email:    "john@example.com",
 					Password: "password123",
@@ -133,7 +202,7 @@ func TestUserService_Registration(t *testing.T) {
 			},
 			expectedError: "",
 			expectedUserReturn: &model.User{
-				Model:    gorm.Model{ID: 1},
+				ID:       "user-1",
 				Name:     "John",
 				Email:    "john@example.com",
 				Password: "password123",
@@ -157,7 +226,7 @@ func TestUserService_Registration(t *testing.T) {
 			name: "user already exists",
 			mockFindByEmail: func(mockRepo *MockUserRepository) {
 				// Mock FindByEmail to return an existing user
-				mockRepo.On("FindByEmail", "john@example.com").Return(&model.User{
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(&model.User{
 					Name:     "John",
 					Email:    "john@example.com",
 					Password: "password123",
@@ -173,19 +242,45 @@ func TestUserService_Registration(t *testing.T) {
 			},
 			expectedError: "user with this email already exists",
 		},
+		{
+			name: "email is looked up in lower case",
+			mockFindByEmail: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(&model.User{ID: "user-1", Email: "john@example.com"}, nil)
+			},
+			mockCreate: func(mockRepo *MockUserRepository) {},
+			user: &model.User{
+				Name:     "John",
+				Email:    " John@Example.com ",
+				Password: "password123",
+			},
+			expectedError: "user with this email already exists",
+		},
+		{
+			name: "email lookup fails",
+			mockFindByEmail: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, errors.New("connection reset"))
+			},
+			mockCreate: func(mockRepo *MockUserRepository) {},
+			user: &model.User{
+				Name:     "John",
+				Email:    "john@example.com",
+				Password: "password123",
+			},
+			expectedError: "connection reset",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockUserRepository)
-			userService := NewUserService(mockRepo)
+			userService := NewUserService(mockRepo, zap.NewNop())
 
 			// Apply mock setup
 			tt.mockFindByEmail(mockRepo)
 			tt.mockCreate(mockRepo)
 
 			// Call the Registration method
-			result, err := userService.Registration(tt.user)
+			result, err := userService.Registration(context.Background(), tt.user)
 
 			// Check expected error
 			if tt.expectedError != "" {
@@ -202,3 +297,211 @@ func TestUserService_Registration(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_RestoreUser(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMock     func(mockRepo *MockUserRepository)
+		expectedError string
+	}{
+		{
+			name: "restore deleted user",
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindDeleted", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Email: "john@example.com"}, nil)
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, gorm.ErrRecordNotFound)
+				mockRepo.On("Restore", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Email: "john@example.com", Password: "hash"}, nil)
+			},
+		},
+		{
+			name: "user is not deleted",
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindDeleted", mock.Anything, "user-1").Return(nil, errors.New("record not found"))
+			},
+			expectedError: "deleted user not found",
+		},
+		{
+			name: "email registered again",
+			setupMock: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindDeleted", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Email: "john@example.com"}, nil)
+				mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(&model.User{ID: "user-2", Email: "john@example.com"}, nil)
+			},
+			expectedError: "user with this email already exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			userService := NewUserService(mockRepo, zap.NewNop())
+			tt.setupMock(mockRepo)
+
+			result, err := userService.RestoreUser(context.Background(), "user-1")
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				mockRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "user-1", result.ID)
+				assert.Empty(t, result.Password)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserService_PurgeDeletedUsers(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	retention := 24 * time.Hour
+	expired := gorm.DeletedAt{Time: time.Now().Add(-48 * time.Hour), Valid: true}
+	mockRepo.On("ListDeletedBefore", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) >= retention && time.Since(before) < retention+time.Minute
+	}), purgeBatchSize).Return([]model.User{{ID: "user-1"}, {ID: "user-2"}}, nil)
+	mockRepo.On("FindDeleted", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Email: "john@example.com", DeletedAt: expired}, nil)
+	mockRepo.On("HardDelete", mock.Anything, "user-1").Return(nil)
+	// user-2 was restored after it was listed
+	mockRepo.On("FindDeleted", mock.Anything, "user-2").Return(nil, gorm.ErrRecordNotFound)
+
+	purged, err := userService.PurgeDeletedUsers(context.Background(), retention)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	mockRepo.AssertExpectations(t)
+
+	if assert.Len(t, mockRepo.auditEntries, 1) {
+		assert.Equal(t, model.AuditPermanentDelete, mockRepo.auditEntries[0].Action)
+		assert.Equal(t, model.PurgeActor, mockRepo.auditEntries[0].Actor)
+	}
+	if assert.Len(t, mockRepo.events, 1) {
+		assert.Equal(t, model.EventUserDeleted, mockRepo.events[0].Type)
+		assert.Equal(t, "user-1", mockRepo.events[0].UserID)
+	}
+
+	_, err = userService.PurgeDeletedUsers(context.Background(), 0)
+	assert.EqualError(t, err, "retention must be positive")
+}
+
+func TestUserService_RegistrationAddsEvent(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return(&model.User{ID: "user-1", Name: "John", Email: "john@example.com", Password: "hash"}, nil)
+
+	_, err := userService.Registration(context.Background(), &model.User{Name: "John", Email: "john@example.com", Password: "password123"})
+	assert.NoError(t, err)
+
+	if assert.Len(t, mockRepo.events, 1) {
+		event := mockRepo.events[0]
+		assert.Equal(t, model.EventUserRegistered, event.Type)
+		assert.Equal(t, "user-1", event.UserID)
+		assert.NotContains(t, event.Payload, "hash")
+	}
+}
+
+func TestUserService_PermanentlyDeleteUserAddsEvent(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("Get", mock.Anything, "user-1").Return((*model.User)(nil), errors.New("record not found"))
+	mockRepo.On("FindDeleted", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Email: "john@example.com"}, nil)
+	mockRepo.On("HardDelete", mock.Anything, "user-1").Return(nil)
+
+	assert.NoError(t, userService.PermanentlyDeleteUser(context.Background(), "user-1"))
+
+	if assert.Len(t, mockRepo.events, 1) {
+		assert.Equal(t, model.EventUserDeleted, mockRepo.events[0].Type)
+		assert.Contains(t, mockRepo.events[0].Payload, `"permanent":true`)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_PermanentlyDeleteUnknownUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("Get", mock.Anything, "user-1").Return((*model.User)(nil), errors.New("record not found"))
+	mockRepo.On("FindDeleted", mock.Anything, "user-1").Return(nil, errors.New("record not found"))
+
+	assert.EqualError(t, userService.PermanentlyDeleteUser(context.Background(), "user-1"), "user not found")
+	mockRepo.AssertNotCalled(t, "HardDelete", mock.Anything, mock.Anything)
+	assert.Empty(t, mockRepo.events)
+}
+
+func TestUserService_UpdateUserKeepsLookupCause(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("Get", mock.Anything, "user-1").Return((*model.User)(nil), context.DeadlineExceeded)
+
+	result, err := userService.UpdateUser(context.Background(), "user-1", &model.User{Name: "John"})
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "user not found")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUserChecksNewEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("Get", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Email: "john@example.com"}, nil)
+	mockRepo.On("FindByEmail", mock.Anything, "jane@example.com").Return(&model.User{ID: "user-2", Email: "jane@example.com"}, nil)
+
+	result, err := userService.UpdateUser(context.Background(), "user-1", &model.User{Email: "Jane@example.com"})
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_PatchUserKeepingEmailSkipsCheck(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	user := &model.User{ID: "user-1", Name: "John", Email: "john@example.com"}
+	mockRepo.On("Get", mock.Anything, "user-1").Return(user, nil)
+	mockRepo.On("Update", mock.Anything, "user-1", user).Return(user, nil)
+
+	email, name := "JOHN@example.com", "Johnny"
+	result, err := userService.PatchUser(context.Background(), "user-1", model.UserPatch{Name: &name, Email: &email})
+	assert.NoError(t, err)
+	assert.Equal(t, "Johnny", result.Name)
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_GetUserByIdReturnsReadTimestamp(t *testing.T) {
+	readTimestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo := &MockUserRepository{readTimestamp: readTimestamp}
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	mockRepo.On("Get", mock.Anything, "user-1").Return(&model.User{ID: "user-1", Password: "hash"}, nil)
+
+	user, timestamp, err := userService.GetUserById(context.Background(), "user-1", model.Staleness{Duration: 15 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, readTimestamp, timestamp)
+	assert.Empty(t, user.Password)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_GetAllUsersReturnsNextPageToken(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	userService := NewUserService(mockRepo, zap.NewNop())
+
+	users := []model.User{{ID: "a", Password: "hash"}, {ID: "b", Password: "hash"}, {ID: "c", Password: "hash"}}
+	mockRepo.On("List", mock.Anything, model.Page{After: "0", Size: 3}).Return(users, nil)
+	mockRepo.On("List", mock.Anything, model.Page{After: "b", Size: 3}).Return(users[2:], nil)
+
+	page, _, err := userService.GetAllUsers(context.Background(), model.Staleness{}, model.Page{After: "0", Size: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.User{{ID: "a"}, {ID: "b"}}, page.Users)
+	assert.Equal(t, model.PageToken("b"), page.NextPageToken)
+
+	page, _, err = userService.GetAllUsers(context.Background(), model.Staleness{}, model.Page{After: "b", Size: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.User{{ID: "c"}}, page.Users)
+	assert.Empty(t, page.NextPageToken)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"crudspanner/interfaces"
+	"crudspanner/model"
+	"crudspanner/publishers"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// webhookMaxAttempts is how often a delivery is tried before it is
+	// given up.
+	webhookMaxAttempts = 10
+	// webhookDisableAfter is how many deliveries to a subscription may fail
+	// in a row before the subscription is disabled.
+	webhookDisableAfter  = 20
+	webhookBatchSize     = 100
+	defaultDeliveryLimit = 50
+	// MaxDeliveryLimit is the most deliveries ListDeliveries returns.
+	MaxDeliveryLimit = 500
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+// WebhookInput is the body of requests that create or change a webhook
+// subscription. On updates only the fields that are set are changed.
+type WebhookInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	// Secret signs the deliveries. A random secret is generated when a
+	// subscription is created without one; on updates it rotates the secret.
+	Secret string `json:"secret"`
+	// Enabled re-enables or disables a subscription.
+	Enabled *bool `json:"enabled"`
+}
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, input WebhookInput) (*model.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id string, input WebhookInput) (*model.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, id string, limit int) ([]model.WebhookDelivery, error)
+	// Publish queues an outbox event for every subscription that wants it.
+	Publish(ctx context.Context, event model.OutboxEvent) error
+	// DeliverPending sends the deliveries that are due and returns how many
+	// succeeded.
+	DeliverPending(ctx context.Context) (int, error)
+}
+
+type webhookService struct {
+	repo   interfaces.WebhookRepository
+	client *http.Client
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+func NewWebhookService(repo interfaces.WebhookRepository, client *http.Client, logger *zap.Logger) WebhookService {
+	return &webhookService{repo: repo, client: client, logger: logger, now: time.Now}
+}
+
+func (s *webhookService) CreateSubscription(ctx context.Context, input WebhookInput) (*model.WebhookSubscription, error) {
+	if err := validateWebhookInput(input, true); err != nil {
+		return nil, err
+	}
+	id, err := model.NewID()
+	if err != nil {
+		return nil, err
+	}
+	secret := input.Secret
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	subscription := &model.WebhookSubscription{
+		ID:         id,
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		Secret:     secret,
+	}
+	if input.Enabled != nil && !*input.Enabled {
+		disable(subscription, s.now())
+	}
+	if err := s.repo.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	subscription, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, subscriptionLookupError(err)
+	}
+	subscription.Secret = ""
+	return subscription, nil
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	subscriptions, err := s.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// UpdateSubscription changes the fields set in input. Re-enabling a
+// subscription resets its failure count; deliveries that were given up while
+// it was failing are not retried.
+func (s *webhookService) UpdateSubscription(ctx context.Context, id string, input WebhookInput) (*model.WebhookSubscription, error) {
+	if err := validateWebhookInput(input, false); err != nil {
+		return nil, err
+	}
+	subscription, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, subscriptionLookupError(err)
+	}
+
+	if input.URL != "" {
+		subscription.URL = input.URL
+	}
+	if input.EventTypes != nil {
+		subscription.EventTypes = input.EventTypes
+	}
+	if input.Enabled != nil {
+		switch {
+		case *input.Enabled && !subscription.Enabled():
+			subscription.DisabledAt = nil
+			subscription.DisabledReason = ""
+			subscription.ConsecutiveFailures = 0
+		case !*input.Enabled && subscription.Enabled():
+			disable(subscription, s.now())
+		}
+	}
+	rotated := input.Secret != ""
+	if rotated {
+		subscription.Secret = input.Secret
+	}
+
+	if err := s.repo.SaveSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	if !rotated {
+		subscription.Secret = ""
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id string) error {
+	if err := s.repo.DeleteSubscription(ctx, id); err != nil {
+		return subscriptionLookupError(err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the most recent deliveries of a subscription,
+// newest first. A limit of 0 returns the default number of deliveries.
+func (s *webhookService) ListDeliveries(ctx context.Context, id string, limit int) ([]model.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	limit = min(limit, MaxDeliveryLimit)
+	if _, err := s.repo.GetSubscription(ctx, id); err != nil {
+		return nil, subscriptionLookupError(err)
+	}
+	return s.repo.ListDeliveries(ctx, id, limit)
+}
+
+func (s *webhookService) Publish(ctx context.Context, event model.OutboxEvent) error {
+	subscriptions, err := s.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := publishers.EnvelopeBody(event)
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	var deliveries []model.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !subscription.Enabled() || !subscription.Wants(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, model.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			ID:             event.ID,
+			EventType:      event.Type,
+			Payload:        string(body),
+			Status:         model.DeliveryPending,
+			CreatedAt:      now,
+			NextAttemptAt:  now,
+		})
+	}
+	return s.repo.AddDeliveries(ctx, deliveries)
+}
+
+func (s *webhookService) DeliverPending(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ClaimDeliveries(ctx, s.now().UTC(), outboxLease, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := map[string]*model.WebhookSubscription{}
+	delivered := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			if subscription, err = s.repo.GetSubscription(ctx, delivery.SubscriptionID); err != nil {
+				s.logger.Error("Failed to load webhook subscription", zap.String("subscription_id", delivery.SubscriptionID), zap.Error(err))
+				continue
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		if s.deliver(ctx, subscription, delivery) {
+			delivered++
+		}
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+	}
+	return delivered, nil
+}
+
+// deliver makes one attempt to send a delivery and records its outcome.
+func (s *webhookService) deliver(ctx context.Context, subscription *model.WebhookSubscription, delivery *model.WebhookDelivery) bool {
+	if !subscription.Enabled() {
+		// Given up without an attempt. The repository does not count
+		// failures of disabled subscriptions.
+		delivery.Status = model.DeliveryFailed
+		delivery.LastError = "subscription is disabled"
+		if _, err := s.repo.RecordAttempt(ctx, delivery, webhookDisableAfter); err != nil {
+			s.logger.Error("Failed to record webhook delivery", zap.String("delivery_id", delivery.ID), zap.Error(err))
+		}
+		return false
+	}
+
+	now := s.now().UTC()
+	status, err := publishers.SendSignedWebhook(ctx, s.client, subscription.URL, subscription.Secret, delivery.ID, []byte(delivery.Payload), now)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down; the lease expires and the delivery is retried.
+		return false
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode = int64(status)
+	if err == nil {
+		delivery.Status = model.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(outboxBackoff(delivery.Attempts))
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = model.DeliveryFailed
+		}
+	}
+
+	disabled, recordErr := s.repo.RecordAttempt(ctx, delivery, webhookDisableAfter)
+	if recordErr != nil {
+		s.logger.Error("Failed to record webhook delivery", zap.String("delivery_id", delivery.ID), zap.Error(recordErr))
+	}
+	if disabled {
+		disable(subscription, now)
+		s.logger.Warn("Disabled failing webhook subscription", zap.String("subscription_id", subscription.ID), zap.String("url", subscription.URL))
+	}
+	if err != nil {
+		s.logger.Warn("Webhook delivery failed", zap.String("subscription_id", subscription.ID),
+			zap.String("delivery_id", delivery.ID), zap.Int64("attempts", delivery.Attempts), zap.Error(err))
+		return false
+	}
+	return recordErr == nil
+}
+
+// subscriptionLookupError returns ErrSubscriptionNotFound for missing
+// subscriptions and other errors unchanged.
+func subscriptionLookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSubscriptionNotFound
+	}
+	return err
+}
+
+func disable(subscription *model.WebhookSubscription, now time.Time) {
+	at := now.UTC()
+	subscription.DisabledAt = &at
+	subscription.DisabledReason = "disabled manually"
+}
+
+func validateWebhookInput(input WebhookInput, create bool) error {
+	if create || input.URL != "" {
+		target, err := url.Parse(input.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+		}
+	}
+	for _, eventType := range input.EventTypes {
+		if !slices.Contains(model.UserEventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, eventType)
+		}
+	}
+	if input.Secret != "" && len(input.Secret) < 16 {
+		return fmt.Errorf("%w: secret must be at least 16 characters", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"crudspanner/migrations"
+	"crudspanner/model"
+	"crudspanner/publishers"
+	"crudspanner/repositories"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeWebhookRepository keeps subscriptions and deliveries in memory.
+type fakeWebhookRepository struct {
+	subscriptions map[string]*model.WebhookSubscription
+	deliveries    []*model.WebhookDelivery
+}
+
+func newFakeWebhookRepository(subscriptions ...model.WebhookSubscription) *fakeWebhookRepository {
+	repo := &fakeWebhookRepository{subscriptions: map[string]*model.WebhookSubscription{}}
+	for i := range subscriptions {
+		repo.subscriptions[subscriptions[i].ID] = &subscriptions[i]
+	}
+	return repo
+}
+
+func (r *fakeWebhookRepository) CreateSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	copied := *subscription
+	r.subscriptions[subscription.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *subscription
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) ListSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	var subscriptions []model.WebhookSubscription
+	for _, subscription := range r.subscriptions {
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions, nil
+}
+
+func (r *fakeWebhookRepository) SaveSubscription(ctx context.Context, subscription *model.WebhookSubscription) error {
+	return r.CreateSubscription(ctx, subscription)
+}
+
+func (r *fakeWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	if _, ok := r.subscriptions[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.subscriptions, id)
+	return nil
+}
+
+func (r *fakeWebhookRepository) AddDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	for _, delivery := range deliveries {
+		if r.delivery(delivery.SubscriptionID, delivery.ID) == nil {
+			copied := delivery
+			r.deliveries = append(r.deliveries, &copied)
+		}
+	}
+	return nil
+}
+
+func (r *fakeWebhookRepository) delivery(subscriptionID, id string) *model.WebhookDelivery {
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID == subscriptionID && delivery.ID == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+func (r *fakeWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var claimed []model.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == model.DeliveryPending && !delivery.NextAttemptAt.After(now) && len(claimed) < limit {
+			claimed = append(claimed, *delivery)
+			delivery.NextAttemptAt = now.Add(lease)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *fakeWebhookRepository) RecordAttempt(ctx context.Context, delivery *model.WebhookDelivery, disableAfter int64) (bool, error) {
+	*r.delivery(delivery.SubscriptionID, delivery.ID) = *delivery
+	subscription := r.subscriptions[delivery.SubscriptionID]
+	if delivery.Status == model.DeliverySucceeded {
+		subscription.ConsecutiveFailures = 0
+		return false, nil
+	}
+	if !subscription.Enabled() {
+		return false, nil
+	}
+	subscription.ConsecutiveFailures++
+	if subscription.ConsecutiveFailures >= disableAfter {
+		now := time.Now()
+		subscription.DisabledAt = &now
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *fakeWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestWebhookService(repo *fakeWebhookRepository, now time.Time) *webhookService {
+	service := NewWebhookService(repo, http.DefaultClient, zap.NewNop()).(*webhookService)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+func TestWebhookService_CreateSubscription(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	service := NewWebhookService(repo, http.DefaultClient, zap.NewNop())
+
+	subscription, err := service.CreateSubscription(context.Background(), WebhookInput{URL: "https://partner.example/hooks"})
+	require.NoError(t, err)
+	assert.Len(t, subscription.Secret, 64, "a secret is generated")
+	assert.True(t, subscription.Enabled())
+
+	listed, err := service.ListSubscriptions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Empty(t, listed[0].Secret, "the secret is only returned on creation")
+
+	invalid := []WebhookInput{
+		{URL: "partner.example/hooks"},
+		{URL: "ftp://partner.example/hooks"},
+		{URL: "https://partner.example/hooks", EventTypes: []string{"UserRenamed"}},
+		{URL: "https://partner.example/hooks", Secret: "short"},
+	}
+	for _, input := range invalid {
+		_, err := service.CreateSubscription(context.Background(), input)
+		assert.ErrorIs(t, err, ErrInvalidSubscription, "%+v", input)
+	}
+}
+
+func TestWebhookService_UpdateSubscriptionReenables(t *testing.T) {
+	disabledAt := time.Now()
+	repo := newFakeWebhookRepository(model.WebhookSubscription{
+		ID: "hook-1", URL: "https://partner.example/hooks", Secret: testSecret,
+		ConsecutiveFailures: 20, DisabledAt: &disabledAt, DisabledReason: "20 deliveries failed in a row",
+	})
+	service := NewWebhookService(repo, http.DefaultClient, zap.NewNop())
+
+	enabled := true
+	subscription, err := service.UpdateSubscription(context.Background(), "hook-1", WebhookInput{Enabled: &enabled})
+	require.NoError(t, err)
+	assert.True(t, subscription.Enabled())
+	assert.Zero(t, subscription.ConsecutiveFailures)
+	assert.Empty(t, subscription.Secret)
+	assert.Equal(t, testSecret, repo.subscriptions["hook-1"].Secret)
+
+	_, err = service.UpdateSubscription(context.Background(), "hook-2", WebhookInput{Enabled: &enabled})
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+}
+
+func TestWebhookService_PublishQueuesMatchingSubscriptions(t *testing.T) {
+	disabledAt := time.Now()
+	repo := newFakeWebhookRepository(
+		model.WebhookSubscription{ID: "all", URL: "https://a.example"},
+		model.WebhookSubscription{ID: "deletes", URL: "https://b.example", EventTypes: []string{model.EventUserDeleted}},
+		model.WebhookSubscription{ID: "disabled", URL: "https://c.example", DisabledAt: &disabledAt},
+	)
+	service := newTestWebhookService(repo, time.Now())
+
+	event := model.OutboxEvent{ID: "event-1", Type: model.EventUserRegistered, Payload: `{"id":"user-1"}`}
+	require.NoError(t, service.Publish(context.Background(), event))
+	require.NoError(t, service.Publish(context.Background(), event), "publishing again is harmless")
+
+	require.Len(t, repo.deliveries, 1)
+	assert.Equal(t, "all", repo.deliveries[0].SubscriptionID)
+	assert.Equal(t, "event-1", repo.deliveries[0].ID)
+	assert.Equal(t, model.DeliveryPending, repo.deliveries[0].Status)
+}
+
+func TestWebhookService_DeliverPendingSignsDeliveries(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepository(model.WebhookSubscription{ID: "hook-1", URL: server.URL, Secret: testSecret, ConsecutiveFailures: 3})
+	service := newTestWebhookService(repo, now)
+	require.NoError(t, service.Publish(context.Background(), model.OutboxEvent{ID: "event-1", Type: model.EventUserUpdated, Payload: `{}`}))
+
+	delivered, err := service.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	assert.Equal(t, "event-1", header.Get(publishers.WebhookIDHeader))
+	assert.Equal(t, strconv.FormatInt(now.Unix(), 10), header.Get(publishers.WebhookTimestampHeader))
+	assert.Equal(t, publishers.SignWebhook(testSecret, now, body), header.Get(publishers.WebhookSignatureHeader))
+
+	delivery := repo.deliveries[0]
+	assert.Equal(t, model.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, int64(1), delivery.Attempts)
+	assert.Equal(t, int64(http.StatusOK), delivery.LastStatusCode)
+	assert.Zero(t, repo.subscriptions["hook-1"].ConsecutiveFailures)
+}
+
+func TestWebhookService_DeliverPendingRetriesAndDisables(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepository(model.WebhookSubscription{ID: "hook-1", URL: server.URL, Secret: testSecret})
+	service := newTestWebhookService(repo, now)
+	require.NoError(t, service.Publish(context.Background(), model.OutboxEvent{ID: "event-1", Type: model.EventUserUpdated, Payload: `{}`}))
+
+	_, err := service.DeliverPending(context.Background())
+	require.NoError(t, err)
+	delivery := repo.deliveries[0]
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	assert.Equal(t, int64(http.StatusInternalServerError), delivery.LastStatusCode)
+	assert.Equal(t, now.Add(2*time.Second), delivery.NextAttemptAt)
+
+	// The last attempt gives the delivery up.
+	delivery.Attempts = webhookMaxAttempts - 1
+	delivery.NextAttemptAt = now
+	_, err = service.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.DeliveryFailed, delivery.Status)
+
+	// Deliveries to a subscription that keeps failing disable it, and its
+	// pending deliveries are given up without being sent.
+	repo.subscriptions["hook-1"].ConsecutiveFailures = webhookDisableAfter - 1
+	require.NoError(t, service.Publish(context.Background(), model.OutboxEvent{ID: "event-2", Type: model.EventUserUpdated, Payload: `{}`}))
+	require.NoError(t, service.Publish(context.Background(), model.OutboxEvent{ID: "event-3", Type: model.EventUserUpdated, Payload: `{}`}))
+	_, err = service.DeliverPending(context.Background())
+	require.NoError(t, err)
+	assert.False(t, repo.subscriptions["hook-1"].Enabled())
+	assert.Equal(t, int64(1), repo.deliveries[1].Attempts)
+	assert.Equal(t, model.DeliveryFailed, repo.deliveries[2].Status)
+	assert.Zero(t, repo.deliveries[2].Attempts)
+	assert.Equal(t, "subscription is disabled", repo.deliveries[2].LastError)
+	assert.Equal(t, int64(webhookDisableAfter), repo.subscriptions["hook-1"].ConsecutiveFailures, "deliveries that are given up are not counted")
+}
+
+func TestWebhookService_DeliverPendingWithSQLiteRepository(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Discard})
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	schema, err := migrations.SQLite()
+	require.NoError(t, err)
+	_, err = migrations.New(db, schema).Up(context.Background())
+	require.NoError(t, err)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	repo := repositories.NewWebhookRepository(db)
+	service := NewWebhookService(repo, http.DefaultClient, zap.NewNop())
+	subscription, err := service.CreateSubscription(ctx, WebhookInput{URL: server.URL, Secret: testSecret})
+	require.NoError(t, err)
+	stored, err := repo.GetSubscription(ctx, subscription.ID)
+	require.NoError(t, err)
+	stored.ConsecutiveFailures = webhookDisableAfter - 1
+	require.NoError(t, repo.SaveSubscription(ctx, stored))
+
+	for _, id := range []string{"event-1", "event-2"} {
+		require.NoError(t, service.Publish(ctx, model.OutboxEvent{ID: id, Type: model.EventUserUpdated, Payload: `{}`}))
+	}
+	delivered, err := service.DeliverPending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Equal(t, 1, requests, "deliveries to the disabled subscription are not sent")
+
+	stored, err = repo.GetSubscription(ctx, subscription.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Enabled())
+	assert.Equal(t, int64(webhookDisableAfter), stored.ConsecutiveFailures)
+
+	deliveries, err := repo.ListDeliveries(ctx, subscription.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	var statuses []string
+	for _, delivery := range deliveries {
+		statuses = append(statuses, delivery.Status)
+	}
+	assert.ElementsMatch(t, []string{model.DeliveryPending, model.DeliveryFailed}, statuses, "the first delivery is retried and the second given up")
+}
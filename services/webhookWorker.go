@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WebhookWorker periodically sends the webhook deliveries that are due.
+type WebhookWorker struct {
+	*worker
+	webhookService WebhookService
+	logger         *zap.Logger
+}
+
+func NewWebhookWorker(webhookService WebhookService, interval time.Duration, logger *zap.Logger) *WebhookWorker {
+	w := &WebhookWorker{
+		webhookService: webhookService,
+		logger:         logger,
+	}
+	w.worker = newWorker(interval, w.deliver)
+	return w
+}
+
+func (w *WebhookWorker) deliver(ctx context.Context) {
+	if _, err := w.webhookService.DeliverPending(ctx); err != nil && ctx.Err() == nil {
+		w.logger.Error("Failed to claim webhook deliveries", zap.Error(err))
+	}
+}
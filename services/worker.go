@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// worker runs a task right away and then every interval in its own goroutine
+// until it is stopped. The task is given a context that is cancelled by Stop.
+type worker struct {
+	interval time.Duration
+	task     func(ctx context.Context)
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+	started  bool
+	stopped  bool
+}
+
+func newWorker(interval time.Duration, task func(ctx context.Context)) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &worker{
+		interval: interval,
+		task:     task,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the task in the background. It does nothing once the worker is
+// started or stopped.
+func (w *worker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started || w.stopped {
+		return
+	}
+	w.started = true
+	go w.run()
+}
+
+// Stop cancels a running task and waits for the worker to exit.
+func (w *worker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.stopped = true
+	w.cancel()
+	if w.started {
+		<-w.done
+	}
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.task(w.ctx)
+		select {
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}